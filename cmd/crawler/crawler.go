@@ -1,98 +1,682 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/katcipis/crawler/crawler"
 )
 
-var formatters map[string]crawler.Formatter = map[string]crawler.Formatter{
-	"text":     crawler.FormatAsTextSitemap,
-	"graphviz": crawler.FormatAsGraphvizSitemap,
+// formatters creates the available formatters, the ones that
+// need to remember URLs keep them on the given storage.
+func formatters(storage crawler.Storage) map[string]crawler.Formatter {
+	return map[string]crawler.Formatter{
+		"text":              crawler.TextSitemapFormatter{Storage: storage}.Format,
+		"text-with-noindex": crawler.TextSitemapFormatter{IncludeNoIndex: true, Storage: storage}.Format,
+		"graphviz":          crawler.FormatAsGraphvizSitemap,
+		"xml":               crawler.XMLSitemapFormatter{Create: createFile, Storage: storage}.Format,
+		"jsonl":             crawler.FormatAsJSONLines,
+		"broken-links":      crawler.FormatAsBrokenLinksReport,
+		"redirects":         crawler.FormatAsRedirectsReport,
+		"seo-audit":         crawler.FormatAsSEOAuditReport,
+	}
 }
 
-func main() {
+// streamingFormats are the formats that write each result as soon as
+// it is received, the only ones that can be used with checkpoints, since
+// results sent before a checkpoint are not sent again when resuming.
+var streamingFormats = map[string]bool{
+	"graphviz": true,
+	"jsonl":    true,
+}
+
+// linkFormats are the formats that only use link results,
+// page results are only requested for the other ones.
+var linkFormats = map[string]bool{
+	"graphviz": true,
+}
+
+// cliFlags are the parameters given on the command line
+type cliFlags struct {
+	concurrency         uint
+	url                 string
+	format              string
+	timeout             time.Duration
+	reqTimeout          time.Duration
+	userAgent           string
+	ignoreRobots        bool
+	rate                float64
+	burst               uint
+	maxDepth            uint
+	maxPages            uint
+	stripParams         string
+	removeTrailingSlash bool
+	canonical           bool
+	external            bool
+	scope               string
+	allowHosts          string
+	denyHosts           string
+	includePaths        string
+	excludePaths        string
+	include             string
+	exclude             string
+	externalConcurrency uint
+	checkpoint          string
+	checkpointInterval  time.Duration
+	resume              string
+	storageDir          string
+	bloomKeys           uint
+	bloomErrorRate      float64
+	strategy            string
+	prioritize          string
+	sitemaps            bool
+	sitemapURLs         string
+	seedsFile           string
+	retries             uint
+	retryBackoff        time.Duration
+	retryBudget         uint
+	retryStatuses       string
+	headers             headerFlags
+	cookieJar           bool
+	cookiesFile         string
+	basicAuth           string
+	bearerToken         string
+	htmlOnly            bool
+	headProbe           bool
+	maxBodySize         int64
+}
+
+// parseFlags parses the parameters given on the command line
+func parseFlags() cliFlags {
 	const defaultConcurrency = 10
 	const defaultFormat = "text"
 	const defaultRequestTimeout = time.Minute
 	const defaultTimeout = 0
 
-	var concurrency uint
-	var url string
-	var format string
-	var timeout time.Duration
-	var reqTimeout time.Duration
+	f := cliFlags{}
 
 	flag.UintVar(
-		&concurrency,
+		&f.concurrency,
 		"concurrency",
 		defaultConcurrency,
 		"amount of concurrent crawlers",
 	)
 	flag.DurationVar(
-		&timeout,
+		&f.timeout,
 		"timeout",
 		defaultTimeout,
 		"timeout of the entire crawling, 0 if you want it to run until all links are reached",
 	)
 	flag.DurationVar(
-		&reqTimeout,
+		&f.reqTimeout,
 		"request-timeout",
 		defaultRequestTimeout,
 		"timeout to be used on each request made",
 	)
 	flag.StringVar(
-		&url,
+		&f.url,
 		"url",
 		"",
-		"url that will be the entry point of the crawler (obligatory)",
+		"url that will be the entry point of the crawler (obligatory, unless -seeds is used)",
 	)
 	flag.StringVar(
-		&format,
+		&f.seedsFile,
+		"seeds",
+		"",
+		"file with one url to crawl per line, or - to read them from stdin, each url is crawled on its own scope",
+	)
+	flag.StringVar(
+		&f.format,
 		"format",
 		defaultFormat,
 		fmt.Sprintf("format of the output, available formats: %s", availableFormats()),
 	)
 
+	flag.StringVar(
+		&f.userAgent,
+		"user-agent",
+		crawler.DefaultUserAgent,
+		"user agent sent on requests and used to match robots.txt rules",
+	)
+	flag.BoolVar(
+		&f.ignoreRobots,
+		"ignore-robots",
+		false,
+		"crawl without fetching and honoring the robots.txt of the entry point",
+	)
+
+	flag.Float64Var(
+		&f.rate,
+		"rate",
+		0,
+		"maximum requests per second made to each host, 0 means no limit (Crawl-delay from robots.txt is used if slower)",
+	)
+	flag.UintVar(
+		&f.burst,
+		"burst",
+		1,
+		"maximum burst of requests made to each host when -rate is used",
+	)
+
+	flag.UintVar(
+		&f.maxDepth,
+		"max-depth",
+		0,
+		"maximum distance in links from the entry point of crawled urls, 0 means no limit",
+	)
+	flag.UintVar(
+		&f.maxPages,
+		"max-pages",
+		0,
+		"maximum amount of crawled urls, 0 means no limit",
+	)
+
+	flag.StringVar(
+		&f.stripParams,
+		"strip-params",
+		strings.Join(crawler.DefaultStripParams, ","),
+		"comma separated query parameters removed from urls, a trailing * matches any suffix",
+	)
+	flag.BoolVar(
+		&f.removeTrailingSlash,
+		"remove-trailing-slash",
+		false,
+		"consider urls with and without a trailing slash the same url",
+	)
+	flag.BoolVar(
+		&f.canonical,
+		"canonical",
+		false,
+		"honor <link rel=\"canonical\"> when deduplicating pages",
+	)
+
+	flag.StringVar(
+		&f.scope,
+		"scope",
+		"host",
+		"scope of the crawling, host to crawl only the entry point host or domain to include all its subdomains",
+	)
+	flag.StringVar(
+		&f.allowHosts,
+		"allow-hosts",
+		"",
+		"comma separated hosts also crawled, including their subdomains",
+	)
+	flag.StringVar(
+		&f.denyHosts,
+		"deny-hosts",
+		"",
+		"comma separated hosts never crawled, including their subdomains",
+	)
+	flag.StringVar(
+		&f.includePaths,
+		"include-paths",
+		"",
+		"comma separated path prefixes, when given only urls with one of them are crawled",
+	)
+	flag.StringVar(
+		&f.excludePaths,
+		"exclude-paths",
+		"",
+		"comma separated path prefixes of urls that are not crawled",
+	)
+	flag.StringVar(
+		&f.include,
+		"include",
+		"",
+		"regular expression, when given only urls matching it are crawled",
+	)
+	flag.StringVar(
+		&f.exclude,
+		"exclude",
+		"",
+		"regular expression of urls that are not crawled",
+	)
+
+	flag.BoolVar(
+		&f.external,
+		"external",
+		false,
+		"check links to other domains, without crawling them",
+	)
+	flag.UintVar(
+		&f.externalConcurrency,
+		"external-concurrency",
+		defaultConcurrency,
+		"amount of concurrent checkers of links to other domains when -external is used",
+	)
+
+	flag.StringVar(
+		&f.checkpoint,
+		"checkpoint",
+		"",
+		"file where checkpoints are saved, periodically and when the crawling ends or is interrupted, only with the graphviz and jsonl formats",
+	)
+	flag.DurationVar(
+		&f.checkpointInterval,
+		"checkpoint-interval",
+		time.Minute,
+		"interval between checkpoints when -checkpoint or -resume are used",
+	)
+	flag.StringVar(
+		&f.resume,
+		"resume",
+		"",
+		"checkpoint file to resume the crawling from, new checkpoints are saved on it unless -checkpoint is used",
+	)
+
+	flag.StringVar(
+		&f.storageDir,
+		"storage-dir",
+		"",
+		"directory where the urls found are kept, instead of memory, to crawl sites with millions of urls",
+	)
+
+	flag.UintVar(
+		&f.bloomKeys,
+		"bloom-keys",
+		0,
+		"expected amount of urls, when given urls are deduplicated with bloom filters of fixed size, 0 disables them",
+	)
+	flag.Float64Var(
+		&f.bloomErrorRate,
+		"bloom-error-rate",
+		0.001,
+		"false positive rate of the bloom filters when -bloom-keys is used, false positives are urls not crawled",
+	)
+
+	flag.StringVar(
+		&f.strategy,
+		"strategy",
+		"bfs",
+		"order urls are crawled: bfs (breadth first), dfs (depth first) or best-first (shallower paths first)",
+	)
+	flag.StringVar(
+		&f.prioritize,
+		"prioritize",
+		"",
+		"regular expression, urls matching it are crawled first when -strategy best-first is used",
+	)
+	flag.BoolVar(
+		&f.sitemaps,
+		"sitemaps",
+		false,
+		"also crawl the urls on the sitemaps of the robots.txt, or on /sitemap.xml if it has none",
+	)
+	flag.StringVar(
+		&f.sitemapURLs,
+		"sitemap-urls",
+		"",
+		"comma separated sitemap urls to also crawl, implies -sitemaps",
+	)
+	flag.UintVar(
+		&f.retries,
+		"retries",
+		0,
+		"how many times a failed fetch of an url is retried, with exponential backoff",
+	)
+	flag.DurationVar(
+		&f.retryBackoff,
+		"retry-backoff",
+		crawler.DefaultRetryPolicy.InitialBackoff,
+		"how long to wait before the first retry, it doubles on each retry",
+	)
+	flag.UintVar(
+		&f.retryBudget,
+		"retry-budget",
+		0,
+		"maximum amount of retries of the whole crawling, 0 means no limit",
+	)
+	flag.StringVar(
+		&f.retryStatuses,
+		"retry-statuses",
+		joinStatuses(crawler.DefaultRetryPolicy.Statuses),
+		"comma separated status codes that are retried, network errors are also retried",
+	)
+	flag.Var(
+		&f.headers,
+		"header",
+		"header sent on requests to urls in the scope, like \"Name: value\", can be used many times",
+	)
+	flag.BoolVar(
+		&f.cookieJar,
+		"cookie-jar",
+		false,
+		"keep the cookies set by urls in the scope and send them back",
+	)
+	flag.StringVar(
+		&f.cookiesFile,
+		"cookies",
+		"",
+		"cookies.txt file, on the Netscape format, with cookies sent to urls in the scope, implies -cookie-jar",
+	)
+	flag.StringVar(
+		&f.basicAuth,
+		"basic-auth",
+		"",
+		"user:password sent using basic authentication to urls in the scope",
+	)
+	flag.StringVar(
+		&f.bearerToken,
+		"bearer-token",
+		"",
+		"bearer token sent to urls in the scope",
+	)
+	flag.BoolVar(
+		&f.htmlOnly,
+		"html-only",
+		false,
+		"only parse html responses, checking their Content-Type, other ones are reported as leaves",
+	)
+	flag.BoolVar(
+		&f.headProbe,
+		"head-probe",
+		false,
+		"make a HEAD request before fetching each url, so files that are not parsed are not downloaded, implies -html-only",
+	)
+	flag.Int64Var(
+		&f.maxBodySize,
+		"max-body-size",
+		0,
+		"maximum bytes read of each response, larger pages are reported as leaves, 0 means no limit",
+	)
+
 	flag.Parse()
 
-	if url == "" {
-		fmt.Fprint(os.Stderr, "\nurl is an obligatory parameter\n\n")
-		flag.PrintDefaults()
-		os.Exit(1)
+	return f
+}
+
+func main() {
+	f := parseFlags()
+
+	resumed, err := f.loadResume()
+	if err != nil {
+		exit(err)
+	}
+
+	seeds, err := f.seeds()
+	if err != nil {
+		exit(err)
+	}
+
+	if err := f.validate(); err != nil {
+		exit(err)
+	}
+
+	opts, err := f.crawlOptions(resumed)
+	if err != nil {
+		exit(err)
+	}
+
+	storage := crawler.MemoryStorage
+	if f.storageDir != "" {
+		storage = crawler.DiskStorage{Dir: f.storageDir}
+		opts = append(opts, crawler.WithStorage(storage))
+	}
+
+	var bloom *crawler.BloomStorage
+	if f.bloomKeys > 0 {
+		bloom = crawler.NewBloomStorage(storage, f.bloomKeys, f.bloomErrorRate)
+		opts = append(opts, crawler.WithStorage(bloom))
 	}
 
-	err := startCrawler(url, concurrency, timeout, reqTimeout, format)
+	err = startCrawler(seeds, f.seedsFile != "", f.concurrency, f.timeout, f.reqTimeout, f.format, storage, opts)
+	if bloom != nil {
+		printBloomStats(bloom.Stats())
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "\ncrawling failed:%s\n", err)
 		os.Exit(1)
 	}
 }
 
+// usageError is an error on the given parameters,
+// reported together with the usage of all parameters.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+// exit reports the given error on stderr and exits
+func exit(err error) {
+	var usage usageError
+	if errors.As(err, &usage) {
+		fmt.Fprintf(os.Stderr, "\n%s\n\n", err)
+		flag.PrintDefaults()
+	} else {
+		fmt.Fprintf(os.Stderr, "\n%s\n", err)
+	}
+	os.Exit(1)
+}
+
+// loadResume loads the checkpoint to be resumed, if any. Its entrypoint is
+// crawled if no url is given and it is also where the checkpoints are
+// saved if no checkpoint is given.
+func (f *cliFlags) loadResume() (*crawler.Checkpoint, error) {
+	if f.resume == "" {
+		return nil, nil
+	}
+
+	cp, err := crawler.LoadCheckpoint(f.resume)
+	if err != nil {
+		return nil, err
+	}
+	if f.url == "" {
+		f.url = cp.Entrypoint
+	}
+	if f.checkpoint == "" {
+		f.checkpoint = f.resume
+	}
+	return cp, nil
+}
+
+// seeds returns the url and the seeds read from the seeds file
+func (f cliFlags) seeds() ([]string, error) {
+	seeds := []string{}
+	if f.url != "" {
+		seeds = append(seeds, f.url)
+	}
+	if f.seedsFile != "" {
+		read, err := readSeeds(f.seedsFile)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, read...)
+	}
+
+	if len(seeds) == 0 {
+		return nil, usageError{errors.New("url or seeds is an obligatory parameter")}
+	}
+	return seeds, nil
+}
+
+// validate checks the parameters that can not be used together
+func (f cliFlags) validate() error {
+	if f.checkpoint != "" && !streamingFormats[f.format] {
+		return usageError{errors.New("checkpoints can only be used with the graphviz and jsonl formats")}
+	}
+	if f.storageDir != "" {
+		// WHY: Checkpoints are kept in memory while they are saved
+		//      and restored, defeating the purpose of the disk storage.
+		if f.checkpoint != "" {
+			return usageError{errors.New("the storage dir can not be used with checkpoints")}
+		}
+		// WHY: Only the bfs strategy keeps its frontier on the storage,
+		//      the other ones keep it in memory.
+		if f.strategy != "bfs" {
+			return usageError{errors.New("the storage dir can only be used with the bfs strategy")}
+		}
+	}
+	if f.bloomKeys > 0 && f.checkpoint != "" {
+		return usageError{errors.New("bloom filters can not be saved on checkpoints")}
+	}
+	return nil
+}
+
+// crawlOptions creates the options of the crawler, except its storage
+func (f cliFlags) crawlOptions(resumed *crawler.Checkpoint) ([]crawler.Option, error) {
+	scopeRules, err := newScopeRules(f.scope, f.include, f.exclude)
+	if err != nil {
+		return nil, usageError{err}
+	}
+	scopeRules.AllowHosts = splitList(f.allowHosts)
+	scopeRules.DenyHosts = splitList(f.denyHosts)
+	scopeRules.IncludePaths = splitList(f.includePaths)
+	scopeRules.ExcludePaths = splitList(f.excludePaths)
+
+	crawlStrategy, err := newStrategy(f.strategy, f.prioritize)
+	if err != nil {
+		return nil, usageError{err}
+	}
+
+	opts := []crawler.Option{
+		crawler.WithUserAgent(f.userAgent),
+		crawler.WithRateLimit(f.rate, f.burst),
+		crawler.WithMaxDepth(f.maxDepth),
+		crawler.WithMaxPages(f.maxPages),
+		crawler.WithScope(scopeRules),
+		crawler.WithStrategy(crawlStrategy),
+		crawler.WithNormalizer(&crawler.Normalizer{
+			StripParams:         splitList(f.stripParams),
+			RemoveTrailingSlash: f.removeTrailingSlash,
+		}),
+	}
+	// WHY: Page results are a lot of extra results,
+	//      sent only when the formatter uses them.
+	if !linkFormats[f.format] {
+		opts = append(opts, crawler.WithPageResults())
+	}
+	if f.canonical {
+		opts = append(opts, crawler.WithCanonical())
+	}
+	if f.ignoreRobots {
+		opts = append(opts, crawler.WithoutRobots())
+	}
+	if f.external {
+		opts = append(opts, crawler.WithExternalLinks(f.externalConcurrency))
+	}
+
+	fetchOpts, err := f.fetchOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, fetchOpts...)
+
+	statuses, err := parseStatuses(splitList(f.retryStatuses))
+	if err != nil {
+		return nil, usageError{err}
+	}
+	if f.retries > 0 {
+		policy := crawler.DefaultRetryPolicy
+		policy.MaxAttempts = f.retries + 1
+		policy.InitialBackoff = f.retryBackoff
+		policy.Budget = f.retryBudget
+		policy.Statuses = statuses
+		opts = append(opts, crawler.WithRetries(policy))
+	}
+
+	seedSitemaps, err := parseURLs(splitList(f.sitemapURLs))
+	if err != nil {
+		return nil, usageError{err}
+	}
+	if f.sitemaps || len(seedSitemaps) > 0 {
+		opts = append(opts, crawler.WithSitemaps(seedSitemaps...))
+	}
+
+	if f.checkpoint != "" {
+		opts = append(opts, crawler.WithCheckpoints(f.checkpoint, f.checkpointInterval))
+	}
+	if resumed != nil {
+		opts = append(opts, crawler.WithResume(resumed))
+	}
+	return opts, nil
+}
+
+// fetchOptions creates the options of how each url is fetched: the
+// headers, cookies and credentials sent and how its body is read.
+func (f cliFlags) fetchOptions() ([]crawler.Option, error) {
+	opts := []crawler.Option{}
+
+	for _, header := range f.headers {
+		name, value, _ := strings.Cut(header, ":")
+		opts = append(opts, crawler.WithHeader(strings.TrimSpace(name), strings.TrimSpace(value)))
+	}
+	if f.cookiesFile != "" {
+		jar, err := crawler.LoadCookies(f.cookiesFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, crawler.WithCookieJar(jar))
+	} else if f.cookieJar {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, crawler.WithCookieJar(jar))
+	}
+	if f.basicAuth != "" {
+		user, password, _ := strings.Cut(f.basicAuth, ":")
+		opts = append(opts, crawler.WithBasicAuth(user, password))
+	}
+	if f.bearerToken != "" {
+		opts = append(opts, crawler.WithBearerToken(f.bearerToken))
+	}
+	if f.htmlOnly {
+		opts = append(opts, crawler.WithContentTypeCheck())
+	}
+	if f.headProbe {
+		opts = append(opts, crawler.WithHeadProbe())
+	}
+	if f.maxBodySize > 0 {
+		opts = append(opts, crawler.WithMaxBodySize(f.maxBodySize))
+	}
+	return opts, nil
+}
+
+// startCrawler crawls the given entrypoints, when
+// seeds is true the results are tagged with their seed.
 func startCrawler(
-	ep string,
+	eps []string,
+	seeds bool,
 	concurrency uint,
 	timeout time.Duration,
 	reqTimeout time.Duration,
 	format string,
+	storage crawler.Storage,
+	opts []crawler.Option,
 ) error {
-	entrypoint, err := url.Parse(ep)
-	if err != nil {
-		return fmt.Errorf("error[%s] parsing entrypoint URL[%s]", err, ep)
-	}
+	entrypoints := []url.URL{}
+	for _, ep := range eps {
+		entrypoint, err := url.Parse(ep)
+		if err != nil {
+			return fmt.Errorf("error[%s] parsing entrypoint URL[%s]", err, ep)
+		}
 
-	if entrypoint.Scheme == "" {
-		entrypoint.Scheme = "http"
-		entrypoint.Host = entrypoint.Path
-		entrypoint.Path = ""
+		if entrypoint.Scheme == "" {
+			entrypoint.Scheme = "http"
+			entrypoint.Host = entrypoint.Path
+			entrypoint.Path = ""
+		}
+		entrypoints = append(entrypoints, *entrypoint)
 	}
 
-	formatter, err := getFormatter(format)
+	formatter, err := getFormatter(format, storage)
 	if err != nil {
 		return err
 	}
@@ -102,35 +686,276 @@ func startCrawler(
 
 	if timeout > 0 {
 		ctx, cancel = context.WithTimeout(context.Background(), timeout)
-		defer cancel()
 	} else {
-		ctx = context.Background()
+		ctx, cancel = context.WithCancel(context.Background())
 	}
+	defer cancel()
+
+	go cancelOnInterrupt(cancel)
+
+	var res <-chan crawler.Result
+	var errs <-chan error
 
-	res, errs := crawler.Start(ctx, *entrypoint, concurrency, reqTimeout)
+	if seeds {
+		res, errs = crawler.StartSeeds(ctx, entrypoints, concurrency, reqTimeout, opts...)
+	} else {
+		res, errs = crawler.Start(ctx, entrypoints[0], concurrency, reqTimeout, opts...)
+	}
 
-	go drainErrors(errs)
+	failures := make(chan map[string]uint)
+	go func() {
+		failures <- drainErrors(errs)
+	}()
 
-	return formatter(res, os.Stdout)
+	if err := formatter(res, os.Stdout); err != nil {
+		return err
+	}
+
+	printFailuresSummary(<-failures)
+	return nil
 }
 
-func drainErrors(errs <-chan error) {
+// cancelOnInterrupt cancels the crawling on the first interrupt
+// signal, so it can end gracefully writing the results found so far
+// and the final checkpoint. A second interrupt kills the process.
+func cancelOnInterrupt(cancel context.CancelFunc) {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+
+	<-interrupts
+	signal.Stop(interrupts)
+	fmt.Fprintln(os.Stderr, "\ninterrupted, finishing the crawling")
+	cancel()
+}
+
+// drainErrors writes the errors on stderr and returns
+// how many errors of each kind happened, check failureKind.
+func drainErrors(errs <-chan error) map[string]uint {
+	failures := map[string]uint{}
 	for err := range errs {
 		fmt.Fprintln(os.Stderr, err)
+		failures[failureKind(err)] += 1
+	}
+	return failures
+}
+
+func failureKind(err error) string {
+	var fetchErr *crawler.FetchError
+	if errors.As(err, &fetchErr) {
+		if fetchErr.Phase == crawler.StatusPhase {
+			return fmt.Sprintf("%s %d", fetchErr.Phase, fetchErr.StatusCode)
+		}
+		return string(fetchErr.Phase)
+	}
+
+	var skipErr *crawler.SkipError
+	if errors.As(err, &skipErr) {
+		return "skipped, " + skipErr.Reason
+	}
+
+	return "other"
+}
+
+func printFailuresSummary(failures map[string]uint) {
+	if len(failures) == 0 {
+		return
+	}
+
+	kinds := []string{}
+	for kind := range failures {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	fmt.Fprintln(os.Stderr, "\nfailures summary:")
+	for _, kind := range kinds {
+		fmt.Fprintf(os.Stderr, "%s: %d\n", kind, failures[kind])
 	}
 }
 
-func getFormatter(name string) (crawler.Formatter, error) {
-	formatter, ok := formatters[name]
+// printBloomStats writes on stderr the stats of the bloom filters
+// of the visited urls and of the results already written of each seed.
+func printBloomStats(stats []crawler.BloomSetStats) {
+	fmt.Fprintln(os.Stderr, "\ncrawl stats:")
+	for _, s := range stats {
+		fmt.Fprintf(os.Stderr, "%s of %s: %d keys, %.1f%% filled, %.4f%% estimated false positive rate\n",
+			s.Name, s.Entrypoint.String(), s.Keys, s.FillRatio*100, s.FalsePositiveRate*100)
+	}
+}
+
+func getFormatter(name string, storage crawler.Storage) (crawler.Formatter, error) {
+	formatter, ok := formatters(storage)[name]
 	if !ok {
 		return nil, fmt.Errorf("unknown formatter:[%s]", name)
 	}
 	return formatter, nil
 }
 
+// createFile creates the sitemap files of the xml
+// formatter on the current directory.
+func createFile(name string) (io.WriteCloser, error) {
+	return os.Create(name)
+}
+
+func newScopeRules(scope string, include string, exclude string) (*crawler.ScopeRules, error) {
+	rules := &crawler.ScopeRules{}
+
+	switch scope {
+	case "host":
+		rules.Base = crawler.SameHostScope
+	case "domain":
+		rules.Base = crawler.SameDomainScope
+	default:
+		return nil, fmt.Errorf("unknown scope:[%s]", scope)
+	}
+
+	if include != "" {
+		exp, err := regexp.Compile(include)
+		if err != nil {
+			return nil, fmt.Errorf("error[%s] parsing include expression[%s]", err, include)
+		}
+		rules.Include = append(rules.Include, exp)
+	}
+
+	if exclude != "" {
+		exp, err := regexp.Compile(exclude)
+		if err != nil {
+			return nil, fmt.Errorf("error[%s] parsing exclude expression[%s]", err, exclude)
+		}
+		rules.Exclude = append(rules.Exclude, exp)
+	}
+
+	return rules, nil
+}
+
+// headerFlags are the headers given with -header, each
+// one can be given many times, like "Name: value".
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(header string) error {
+	if !strings.Contains(header, ":") {
+		return fmt.Errorf("header[%s] must be like \"Name: value\"", header)
+	}
+	*h = append(*h, header)
+	return nil
+}
+
+// readSeeds reads the seeds from the given file, or from stdin
+// if it is "-". Empty lines and lines starting with # are ignored.
+func readSeeds(path string) ([]string, error) {
+	input := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error[%s] opening seeds file[%s]", err, path)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	seeds := []string{}
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error[%s] reading seeds from[%s]", err, path)
+	}
+	return seeds, nil
+}
+
+// parseStatuses parses the given status codes
+func parseStatuses(statuses []string) ([]int, error) {
+	parsed := []int{}
+	for _, s := range statuses {
+		status, err := strconv.Atoi(s)
+		if err != nil || status < 100 || status > 999 {
+			return nil, fmt.Errorf("invalid status code[%s]", s)
+		}
+		parsed = append(parsed, status)
+	}
+	return parsed, nil
+}
+
+func joinStatuses(statuses []int) string {
+	joined := []string{}
+	for _, status := range statuses {
+		joined = append(joined, strconv.Itoa(status))
+	}
+	return strings.Join(joined, ",")
+}
+
+// parseURLs parses the given absolute URLs
+func parseURLs(urls []string) ([]url.URL, error) {
+	parsed := []url.URL{}
+	for _, u := range urls {
+		p, err := url.Parse(u)
+		if err != nil {
+			return nil, fmt.Errorf("error[%s] parsing URL[%s]", err, u)
+		}
+		if !p.IsAbs() {
+			return nil, fmt.Errorf("URL[%s] must be absolute", u)
+		}
+		parsed = append(parsed, *p)
+	}
+	return parsed, nil
+}
+
+// newStrategy creates the crawling strategy, the best first one crawls
+// urls matching the prioritize expression first, if given, and then the
+// ones with shallower paths.
+func newStrategy(strategy string, prioritize string) (crawler.Strategy, error) {
+	switch strategy {
+	case "bfs":
+		return crawler.BreadthFirst, nil
+	case "dfs":
+		return crawler.DepthFirst, nil
+	case "best-first":
+	default:
+		return nil, fmt.Errorf("unknown strategy:[%s]", strategy)
+	}
+
+	if prioritize == "" {
+		return crawler.BestFirst(crawler.ScoreByPathDepth), nil
+	}
+
+	exp, err := regexp.Compile(prioritize)
+	if err != nil {
+		return nil, fmt.Errorf("error[%s] parsing prioritize expression[%s]", err, prioritize)
+	}
+	byPattern := crawler.ScoreByPattern(exp)
+
+	// WHY: Path depths are never bigger than an URL length, so
+	//      matching URLs always score higher than the other ones.
+	const patternWeight = 1 << 20
+
+	return crawler.BestFirst(func(j crawler.FrontierJob) float64 {
+		return byPattern(j)*patternWeight + crawler.ScoreByPathDepth(j)
+	}), nil
+}
+
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func availableFormats() []string {
 	fmts := []string{}
-	for f := range formatters {
+	for f := range formatters(crawler.MemoryStorage) {
 		fmts = append(fmts, f)
 	}
 	return fmts
@@ -0,0 +1,176 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Credentials authenticate the requests to URLs in the crawling scope
+type Credentials struct {
+	// Username and Password are sent using basic authentication
+	Username string
+	Password string
+	// BearerToken is sent as a bearer token on the Authorization
+	// header, it is ignored if there is an Username.
+	BearerToken string
+}
+
+// entrypointKey is the context key of the entrypoint of the crawling
+// a request is part of, used to check if its URL is in the scope.
+type entrypointKey struct{}
+
+func withEntrypoint(ctx context.Context, entrypoint url.URL) context.Context {
+	return context.WithValue(ctx, entrypointKey{}, entrypoint)
+}
+
+// scopedTransport adds the headers, cookies and credentials of the config
+// only on requests to URLs in the crawling scope. Since it is called for
+// each redirect they are never sent to URLs out of the scope, even when
+// an URL in the scope redirects to one out of it. Credentials are not
+// sent on downgrades from https to http either, check downgraded.
+type scopedTransport struct {
+	cfg  Config
+	next http.RoundTripper
+}
+
+func (t *scopedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entrypoint, ok := req.Context().Value(entrypointKey{}).(url.URL)
+	if !ok || !t.cfg.Scope.Contains(entrypoint, *req.URL) {
+		return t.next.RoundTrip(req)
+	}
+
+	// WHY: A RoundTripper must not modify the given request
+	req = req.Clone(req.Context())
+
+	for name, values := range t.cfg.Headers {
+		req.Header[http.CanonicalHeaderKey(name)] = append([]string{}, values...)
+	}
+
+	creds := t.cfg.Credentials
+	if downgraded(entrypoint, req) {
+		creds = Credentials{}
+	}
+	if creds.Username != "" {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	} else if creds.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+creds.BearerToken)
+	}
+
+	jar := t.cfg.CookieJar
+	if jar != nil {
+		for _, cookie := range jar.Cookies(req.URL) {
+			req.AddCookie(cookie)
+		}
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err == nil && jar != nil {
+		if cookies := res.Cookies(); len(cookies) > 0 {
+			jar.SetCookies(req.URL, cookies)
+		}
+	}
+	return res, err
+}
+
+// downgraded returns true if the given request is made with http
+// while crawling an https entrypoint, or is a redirect from https to
+// http, so the credentials would be sent in the clear.
+func downgraded(entrypoint url.URL, req *http.Request) bool {
+	if req.URL.Scheme != "http" {
+		return false
+	}
+	if entrypoint.Scheme == "https" {
+		return true
+	}
+	return req.Response != nil && req.Response.Request.URL.Scheme == "https"
+}
+
+// LoadCookies creates a cookie jar with the cookies of the given
+// cookies.txt file, on the Netscape format used by curl, wget
+// and browser extensions that export cookies. Expired cookies
+// are ignored.
+func LoadCookies(path string) (http.CookieJar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open cookies file[%s]: %s", path, err)
+	}
+	defer f.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := readCookies(f, jar); err != nil {
+		return nil, fmt.Errorf("unable to read cookies file[%s]: %s", path, err)
+	}
+	return jar, nil
+}
+
+// readCookies reads the cookies on the Netscape format, one per line with
+// the tab separated fields: domain, include subdomains, path, secure,
+// expiration as an unix timestamp, name and value. Lines starting with
+// # are comments, except the #HttpOnly_ prefix of HTTP only cookies.
+func readCookies(r io.Reader, jar http.CookieJar) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(text, "#HttpOnly_")
+		if httpOnly {
+			text = strings.TrimPrefix(text, "#HttpOnly_")
+		}
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("line[%d] has %d fields, want 7", line, len(fields))
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line[%d] has invalid expiration[%s]", line, fields[4])
+		}
+
+		domain := fields[0]
+		secure := strings.EqualFold(fields[3], "TRUE")
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   secure,
+			HttpOnly: httpOnly,
+		}
+		// WHY: Cookies of all subdomains have a Domain attribute,
+		//      the other ones are only sent to the exact host.
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+		// WHY: Zero means a session cookie, which never expires here
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&url.URL{
+			Scheme: scheme,
+			Host:   strings.TrimPrefix(domain, "."),
+			Path:   fields[2],
+		}, []*http.Cookie{cookie})
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,281 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestCrawlingSendsCredentialsOnlyInScope(t *testing.T) {
+	type tcase struct {
+		name  string
+		opts  []crawler.Option
+		allow func(r *http.Request) bool
+	}
+
+	cases := []tcase{
+		{
+			name: "BasicAuth",
+			opts: []crawler.Option{crawler.WithBasicAuth("user", "pass")},
+			allow: func(r *http.Request) bool {
+				user, pass, ok := r.BasicAuth()
+				return ok && user == "user" && pass == "pass"
+			},
+		},
+		{
+			name: "BearerToken",
+			opts: []crawler.Option{crawler.WithBearerToken("token")},
+			allow: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "Bearer token"
+			},
+		},
+		{
+			name: "Header",
+			opts: []crawler.Option{crawler.WithHeader("X-Api-Key", "key")},
+			allow: func(r *http.Request) bool {
+				return r.Header.Get("X-Api-Key") == "key"
+			},
+		},
+		{
+			name: "Cookies",
+			opts: []crawler.Option{crawler.WithCookieJar(newJar(t))},
+			allow: func(r *http.Request) bool {
+				cookie, err := r.Cookie("session")
+				return err == nil && cookie.Value == "logged"
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			leaked := []string{}
+
+			external, teardownExternal := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c.allow(r) {
+					mutex.Lock()
+					leaked = append(leaked, r.URL.Path)
+					mutex.Unlock()
+				}
+			}))
+			defer teardownExternal()
+
+			entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/login" {
+					http.SetCookie(w, &http.Cookie{Name: "session", Value: "logged"})
+					return
+				}
+				if !c.allow(r) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				switch r.URL.Path {
+				case "/":
+					fmt.Fprintf(w, `<a href="/a"></a><a href="/redirect"></a><a href="%s/external"></a>`, external.String())
+				case "/redirect":
+					http.Redirect(w, r, external.String()+"/redirected", http.StatusFound)
+				}
+			}))
+			defer teardown()
+
+			if c.name == "Cookies" {
+				login := entrypoint
+				login.Path = "/login"
+				setCookies(t, c.opts, login)
+			}
+
+			want := []crawler.Result{
+				result(entrypoint, "", "/a"),
+				result(entrypoint, "", "/redirect"),
+				{
+					Parent:   entrypoint,
+					Link:     url.URL{Scheme: external.Scheme, Host: external.Host, Path: "/external"},
+					Kind:     "page",
+					External: true,
+				},
+				{
+					Parent:         url.URL{Scheme: entrypoint.Scheme, Host: entrypoint.Host, Path: "/redirect"},
+					Link:           url.URL{Scheme: external.Scheme, Host: external.Host, Path: "/redirected"},
+					Kind:           "redirect",
+					External:       true,
+					RedirectStatus: http.StatusFound,
+				},
+			}
+
+			opts := append(c.opts, crawler.WithExternalLinks(1), crawler.WithoutRobots())
+			results, errs := crawler.New(opts...).Run(context.Background(), entrypoint)
+			checkResults(t, results, errs, want, 0)
+
+			if len(leaked) > 0 {
+				t.Fatalf("credentials sent out of the scope to %v", leaked)
+			}
+		})
+	}
+}
+
+func TestCrawlingSendsNoCredentialsOnDowngrades(t *testing.T) {
+	var mutex sync.Mutex
+	leaked := []string{}
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, hasAuth := r.BasicAuth()
+		if r.Header.Get(schemeHeader) == "http" {
+			if hasAuth {
+				mutex.Lock()
+				leaked = append(leaked, r.URL.Path)
+				mutex.Unlock()
+			}
+			return
+		}
+		if !hasAuth || user != "user" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, `<a href="http://%s/plain"></a><a href="/redirect"></a>`, r.Host)
+		case "/redirect":
+			http.Redirect(w, r, "http://"+r.Host+"/downgraded", http.StatusFound)
+		}
+	}))
+	defer teardown()
+
+	secure := entrypoint
+	secure.Scheme = "https"
+
+	plain := func(path string) url.URL {
+		return url.URL{Scheme: "http", Host: entrypoint.Host, Path: path}
+	}
+
+	want := []crawler.Result{
+		{Parent: secure, Link: plain("/plain"), Kind: "page"},
+		result(secure, "", "/redirect"),
+		{
+			Parent:         url.URL{Scheme: "https", Host: entrypoint.Host, Path: "/redirect"},
+			Link:           plain("/downgraded"),
+			Kind:           "redirect",
+			RedirectStatus: http.StatusFound,
+		},
+	}
+
+	results, errs := crawler.New(
+		crawler.WithHTTPClient(&http.Client{Transport: &schemeTransport{target: entrypoint}}),
+		crawler.WithBasicAuth("user", "pass"),
+		crawler.WithoutRobots(),
+	).Run(context.Background(), secure)
+	checkResults(t, results, errs, want, 0)
+
+	if len(leaked) > 0 {
+		t.Fatalf("credentials sent on downgrades to http to %v", leaked)
+	}
+}
+
+func TestCrawlingKeepsCookies(t *testing.T) {
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "logged"})
+			fmt.Fprint(w, `<a href="/private"></a>`)
+		case "/private":
+			if _, err := r.Cookie("session"); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `<a href="/secret"></a>`)
+		}
+	}))
+	defer teardown()
+
+	jar, err := cookiejar.New(nil)
+	fatalerr(t, err, "creating cookie jar")
+
+	results, errs := crawler.New(
+		crawler.WithoutRobots(),
+		crawler.WithCookieJar(jar),
+	).Run(context.Background(), entrypoint)
+
+	checkResults(t, results, errs, []crawler.Result{
+		result(entrypoint, "", "/private"),
+		result(entrypoint, "/private", "/secret"),
+	}, 0)
+}
+
+func TestLoadCookies(t *testing.T) {
+	jar, err := crawler.LoadCookies("./testdata/cookies.txt")
+	fatalerr(t, err, "loading cookies")
+
+	cases := map[string][]string{
+		"http://staging.test/":          {"pref=dark", "session=abc"},
+		"http://staging.test/admin":     {"admin=secret", "pref=dark", "session=abc"},
+		"https://staging.test/":         {"pref=dark", "secure=only", "session=abc"},
+		"http://www.staging.test/":      {"pref=dark"},
+		"http://production.test/":       {},
+		"http://staging.test.evil.com/": {},
+	}
+
+	for rawURL, want := range cases {
+		u, err := url.Parse(rawURL)
+		fatalerr(t, err, "parsing url")
+
+		got := []string{}
+		for _, cookie := range jar.Cookies(u) {
+			got = append(got, cookie.String())
+		}
+		sort.Strings(got)
+
+		if fmt.Sprint(want) != fmt.Sprint(got) {
+			t.Errorf("url[%s]: want cookies %v, got %v", rawURL, want, got)
+		}
+	}
+}
+
+func TestLoadCookiesFailures(t *testing.T) {
+	cases := map[string]string{
+		"MissingFields":     "staging.test\tFALSE\t/\tFALSE\t0\tsession\n",
+		"InvalidExpiration": "staging.test\tFALSE\t/\tFALSE\tnever\tsession\tabc\n",
+	}
+
+	for name, contents := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := t.TempDir() + "/cookies.txt"
+			fatalerr(t, os.WriteFile(path, []byte(contents), 0644), "writing cookies")
+
+			if _, err := crawler.LoadCookies(path); err == nil {
+				t.Fatal("expected error loading invalid cookies")
+			}
+		})
+	}
+
+	if _, err := crawler.LoadCookies("./testdata/missing.txt"); err == nil {
+		t.Fatal("expected error loading missing cookies file")
+	}
+}
+
+func newJar(t *testing.T) http.CookieJar {
+	jar, err := cookiejar.New(nil)
+	fatalerr(t, err, "creating cookie jar")
+	return jar
+}
+
+// setCookies logs in on the given URL, keeping the
+// cookies on the jar of the given options.
+func setCookies(t *testing.T, opts []crawler.Option, login url.URL) {
+	t.Helper()
+
+	cfg := crawler.Config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	res, err := (&http.Client{Jar: cfg.CookieJar}).Get(login.String())
+	fatalerr(t, err, "logging in")
+	res.Body.Close()
+}
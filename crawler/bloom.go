@@ -0,0 +1,252 @@
+package crawler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"net/url"
+	"sync"
+)
+
+// ErrBloomFilterRange is returned when ranging the keys of a BloomFilter,
+// which only keeps their hashes. So checkpoints can not be saved when
+// the visited sets are bloom filters.
+var ErrBloomFilterRange = errors.New("bloom filter keys can not be ranged")
+
+// BloomFilter is a VisitedSet with fixed memory, sized from the expected
+// amount of keys and the acceptable false positive rate. A false positive
+// makes a new key be taken as already visited, so an URL may not be
+// crawled or a result may not be sent. There are no false negatives.
+//
+// After the expected amount of keys is added the false positive rate
+// starts to grow above the target error rate, check Stats.
+type BloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes uint64
+	keys   uint
+}
+
+// BloomFilterStats are the stats of a BloomFilter
+type BloomFilterStats struct {
+	// Keys is the amount of keys added to the filter
+	Keys uint
+	// Bits is the size of the filter in bits
+	Bits uint
+	// Hashes is the amount of bits set for each key
+	Hashes uint
+	// FillRatio is the ratio of bits set, from 0 to 1
+	FillRatio float64
+	// FalsePositiveRate is the estimated chance of
+	// a key not on the filter being taken as on it.
+	FalsePositiveRate float64
+}
+
+// NewBloomFilter creates a bloom filter sized to have the given
+// error rate, as its false positive rate, after expectedKeys
+// are added. The error rate must be between 0 and 1.
+func NewBloomFilter(expectedKeys uint, errorRate float64) (*BloomFilter, error) {
+	if expectedKeys == 0 {
+		return nil, errors.New("bloom filter expected keys must be greater than zero")
+	}
+	if errorRate <= 0 || errorRate >= 1 {
+		return nil, fmt.Errorf("bloom filter error rate[%g] must be between 0 and 1", errorRate)
+	}
+
+	// WHY: Optimal size and amount of hashes for the error rate:
+	//      https://en.wikipedia.org/wiki/Bloom_filter#Optimal_number_of_hash_functions
+	n := float64(expectedKeys)
+	size := math.Ceil(-n * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	hashes := math.Max(1, math.Round(size/n*math.Ln2))
+
+	words := (uint64(size) + 63) / 64
+	return &BloomFilter{
+		bits:   make([]uint64, words),
+		size:   words * 64,
+		hashes: uint64(hashes),
+	}, nil
+}
+
+// Add adds the key to the filter, added is false if the
+// key was already on the filter or it is a false positive.
+func (f *BloomFilter) Add(key string) (bool, error) {
+	added := false
+	f.eachBit(key, func(word uint64, mask uint64) {
+		if f.bits[word]&mask == 0 {
+			added = true
+			f.bits[word] |= mask
+		}
+	})
+	if added {
+		f.keys++
+	}
+	return added, nil
+}
+
+// Contains returns true if the key is on the
+// filter, or if it is a false positive.
+func (f *BloomFilter) Contains(key string) (bool, error) {
+	found := true
+	f.eachBit(key, func(word uint64, mask uint64) {
+		if f.bits[word]&mask == 0 {
+			found = false
+		}
+	})
+	return found, nil
+}
+
+// Range always fails with ErrBloomFilterRange
+func (f *BloomFilter) Range(fn func(key string) error) error {
+	return ErrBloomFilterRange
+}
+
+// Close does nothing, the stats of the
+// filter are still available after it.
+func (f *BloomFilter) Close() error {
+	return nil
+}
+
+// Stats returns the stats of the filter, like
+// its fill ratio and its false positive rate.
+func (f *BloomFilter) Stats() BloomFilterStats {
+	set := 0
+	for _, word := range f.bits {
+		set += bits.OnesCount64(word)
+	}
+	fill := float64(set) / float64(f.size)
+
+	return BloomFilterStats{
+		Keys:              f.keys,
+		Bits:              uint(f.size),
+		Hashes:            uint(f.hashes),
+		FillRatio:         fill,
+		FalsePositiveRate: math.Pow(fill, float64(f.hashes)),
+	}
+}
+
+// eachBit calls fn with the word and mask of each bit of the key,
+// using double hashing of the two halves of a 128 bits hash.
+func (f *BloomFilter) eachBit(key string, fn func(word uint64, mask uint64)) {
+	hash := fnv.New128a()
+	hash.Write([]byte(key))
+	sum := hash.Sum(nil)
+
+	// WHY: The last bytes of the key only change the lowest bits
+	//      of a FNV hash, so the halves are mixed to spread them.
+	h1 := mix64(binary.BigEndian.Uint64(sum[:8]) ^ binary.BigEndian.Uint64(sum[8:]))
+	h2 := mix64(h1) | 1
+
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.size
+		fn(bit/64, 1<<(bit%64))
+	}
+}
+
+// mix64 is the finalizer of MurmurHash3
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// BloomStorage is a Storage whose visited sets are bloom filters,
+// check BloomFilter for details. The frontiers are created by Frontiers,
+// or kept in memory if it is nil. Use NewBloomStorage to create one.
+type BloomStorage struct {
+	// Frontiers creates the frontiers, MemoryStorage is used if nil
+	Frontiers Storage
+	// ExpectedKeys is the expected amount of keys on each visited set
+	ExpectedKeys uint
+	// ErrorRate is the target false positive rate of each visited set
+	ErrorRate float64
+
+	mutex sync.Mutex
+	sets  []bloomSet
+}
+
+// BloomSetStats are the stats of a visited set of a BloomStorage
+type BloomSetStats struct {
+	BloomFilterStats
+	// Name tells what the set deduplicates, "visited urls" or "sent
+	// results" for the sets of the crawler, empty for other sets.
+	Name string
+	// Entrypoint is the entrypoint of the crawling the set is
+	// part of, empty for sets not created by the crawler.
+	Entrypoint url.URL
+}
+
+type bloomSet struct {
+	filter     *BloomFilter
+	name       string
+	entrypoint url.URL
+}
+
+// NewBloomStorage creates a new BloomStorage
+func NewBloomStorage(frontiers Storage, expectedKeys uint, errorRate float64) *BloomStorage {
+	return &BloomStorage{
+		Frontiers:    frontiers,
+		ExpectedKeys: expectedKeys,
+		ErrorRate:    errorRate,
+	}
+}
+
+// NewFrontier creates a new empty frontier with Frontiers
+func (s *BloomStorage) NewFrontier() (Frontier, error) {
+	if s.Frontiers == nil {
+		return MemoryStorage.NewFrontier()
+	}
+	return s.Frontiers.NewFrontier()
+}
+
+// NewVisitedSet creates a new empty bloom filter
+func (s *BloomStorage) NewVisitedSet() (VisitedSet, error) {
+	return s.newVisitedSet("", url.URL{})
+}
+
+func (s *BloomStorage) newVisitedSet(name string, entrypoint url.URL) (VisitedSet, error) {
+	filter, err := NewBloomFilter(s.ExpectedKeys, s.ErrorRate)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sets = append(s.sets, bloomSet{filter: filter, name: name, entrypoint: entrypoint})
+	return filter, nil
+}
+
+// Stats returns the stats of all visited sets created by the storage,
+// in the order they were created, with their names and entrypoints.
+// Since the filters are not safe for concurrent use, call it only
+// after the crawling ends.
+func (s *BloomStorage) Stats() []BloomSetStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := []BloomSetStats{}
+	for _, set := range s.sets {
+		stats = append(stats, BloomSetStats{
+			BloomFilterStats: set.filter.Stats(),
+			Name:             set.name,
+			Entrypoint:       set.entrypoint,
+		})
+	}
+	return stats
+}
+
+// newCrawlVisitedSet creates a visited set of the crawling of the given
+// entrypoint with the given storage, named when it is a BloomStorage,
+// so its stats tell which set is which.
+func newCrawlVisitedSet(storage Storage, name string, entrypoint url.URL) (VisitedSet, error) {
+	if bloom, ok := storage.(*BloomStorage); ok {
+		return bloom.newVisitedSet(name, entrypoint)
+	}
+	return storage.NewVisitedSet()
+}
@@ -0,0 +1,127 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestBloomFilter(t *testing.T) {
+	const expectedKeys = 10000
+	const errorRate = 0.01
+
+	filter, err := crawler.NewBloomFilter(expectedKeys, errorRate)
+	fatalerr(t, err, "creating bloom filter")
+
+	added := uint(0)
+	for i := 0; i < expectedKeys; i++ {
+		ok, err := filter.Add(fmt.Sprintf("http://test/%d", i))
+		fatalerr(t, err, "adding key")
+		if ok {
+			added++
+		}
+	}
+
+	for i := 0; i < expectedKeys; i++ {
+		key := fmt.Sprintf("http://test/%d", i)
+		found, err := filter.Contains(key)
+		fatalerr(t, err, "checking key")
+		if !found {
+			t.Fatalf("false negative on key[%s]", key)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < expectedKeys; i++ {
+		found, err := filter.Contains(fmt.Sprintf("http://other/%d", i))
+		fatalerr(t, err, "checking missing key")
+		if found {
+			falsePositives++
+		}
+	}
+
+	// WHY: The real rate varies with the keys, so some slack is given.
+	if rate := float64(falsePositives) / expectedKeys; rate > 3*errorRate {
+		t.Errorf("want false positive rate around %g, got %g", errorRate, rate)
+	}
+
+	stats := filter.Stats()
+	if stats.Keys != added {
+		t.Errorf("want %d keys, got %d", added, stats.Keys)
+	}
+	if math.Abs(stats.FillRatio-0.5) > 0.05 {
+		t.Errorf("want fill ratio around 0.5, got %g", stats.FillRatio)
+	}
+	if math.Abs(stats.FalsePositiveRate-errorRate) > errorRate/2 {
+		t.Errorf("want estimated false positive rate around %g, got %g",
+			errorRate, stats.FalsePositiveRate)
+	}
+
+	if err := filter.Range(func(string) error { return nil }); err != crawler.ErrBloomFilterRange {
+		t.Errorf("want ErrBloomFilterRange ranging keys, got %v", err)
+	}
+}
+
+func TestBloomFilterInvalidParams(t *testing.T) {
+	type tcase struct {
+		name         string
+		expectedKeys uint
+		errorRate    float64
+	}
+
+	cases := []tcase{
+		{name: "NoKeys", expectedKeys: 0, errorRate: 0.01},
+		{name: "ZeroErrorRate", expectedKeys: 10, errorRate: 0},
+		{name: "NegativeErrorRate", expectedKeys: 10, errorRate: -0.1},
+		{name: "ErrorRateOfOne", expectedKeys: 10, errorRate: 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := crawler.NewBloomFilter(c.expectedKeys, c.errorRate); err == nil {
+				t.Fatal("expected error creating bloom filter")
+			}
+		})
+	}
+}
+
+func TestCrawlingWithBloomStorage(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	const wantCrawlingErrs = 3
+
+	storage := crawler.NewBloomStorage(nil, 1000, 0.0001)
+	results, errs := crawler.New(crawler.WithStorage(storage)).Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, fakesiteResults(entrypoint), wantCrawlingErrs)
+
+	stats := storage.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("want stats of visited urls and sent results, got %v", stats)
+	}
+
+	visited, sent := stats[0], stats[1]
+	if visited.Keys == 0 || sent.Keys != uint(len(fakesiteResults(entrypoint))) {
+		t.Fatalf("unexpected keys on stats: %+v", stats)
+	}
+	if visited.Name != "visited urls" || sent.Name != "sent results" {
+		t.Fatalf("unexpected names on stats: %+v", stats)
+	}
+	if visited.Entrypoint != entrypoint || sent.Entrypoint != entrypoint {
+		t.Fatalf("want stats of entrypoint[%s], got %+v", entrypoint.String(), stats)
+	}
+}
+
+func TestCrawlerFailsToRunWithInvalidBloomStorage(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/emptysite")
+	defer teardown()
+
+	const wantErrs = 1
+
+	storage := crawler.NewBloomStorage(nil, 0, 0.01)
+	results, errs := crawler.New(crawler.WithStorage(storage)).Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, []crawler.Result{}, wantErrs)
+}
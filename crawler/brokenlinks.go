@@ -0,0 +1,125 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// FormatAsBrokenLinksReport will drain the given Result channel and
+// write on the given writer a report of all the broken links found,
+// sorted by URL, with the status received and all the pages linking to
+// them, like:
+//
+//	http://example.com/missing: 404 Not Found
+//	  found on http://example.com
+//	  found on http://example.com/about
+//
+// A link is broken when fetching it got a non 2xx status code, the
+// link was unreachable or its redirects could not be followed. Links
+// redirected to a broken URL are reported with their last redirect,
+// like:
+//
+//	http://example.com/old: 301 Moved Permanently to http://example.com/new: 404 Not Found
+//	  found on http://example.com
+//
+// It requires page results, since they have the status of the fetches,
+// links that were not crawled are not part of the report.
+func FormatAsBrokenLinksReport(res <-chan Result, w io.Writer) error {
+	parents := map[string][]string{}
+	seenParents := map[string]bool{}
+	broken := map[string]*Page{}
+
+	addParent := func(link string, parent string) {
+		if parent == "" || seenParents[parent+" "+link] {
+			return
+		}
+		seenParents[parent+" "+link] = true
+		parents[link] = append(parents[link], parent)
+	}
+
+	for r := range res {
+		link := r.Link.String()
+		addParent(link, r.Parent.String())
+
+		if r.Page != nil && isBroken(r.Page) {
+			broken[link] = r.Page
+		}
+	}
+
+	links := []string{}
+	for link := range broken {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+
+	for _, link := range links {
+		report := []string{fmt.Sprintf("%s: %s", link, brokenStatus(broken[link]))}
+		for _, parent := range parents[link] {
+			report = append(report, "  found on "+parent)
+		}
+
+		_, err := w.Write([]byte(strings.Join(report, "\n") + "\n"))
+		if err != nil {
+			return fmt.Errorf("broken links formatter: failed to write result: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func isBroken(p *Page) bool {
+	if redirectsFailed(p.Err) {
+		return true
+	}
+	// WHY: Redirected pages have the status of their first redirect,
+	//      they are only broken if the URL they were redirected to is.
+	if len(p.Redirects) > 0 && p.Err == nil {
+		return false
+	}
+	status := lastStatus(p)
+	if status == 0 {
+		return p.Err != nil
+	}
+	return status < 200 || status >= 300
+}
+
+func brokenStatus(p *Page) string {
+	for _, err := range []error{ErrRedirectLoop, ErrTooManyRedirects} {
+		if errors.Is(p.Err, err) {
+			return err.Error()
+		}
+	}
+
+	status := lastStatus(p)
+	msg := fmt.Sprintf("%d %s", status, http.StatusText(status))
+	if status == 0 {
+		cause := p.Err
+		var fetchErr *FetchError
+		if errors.As(cause, &fetchErr) && fetchErr.Err != nil {
+			cause = fetchErr.Err
+		}
+		msg = fmt.Sprintf("unreachable: %s", cause)
+	}
+
+	if len(p.Redirects) > 0 {
+		last := p.Redirects[len(p.Redirects)-1]
+		return fmt.Sprintf("%d %s to %s: %s", last.StatusCode,
+			http.StatusText(last.StatusCode), last.Location.String(), msg)
+	}
+	return msg
+}
+
+// lastStatus returns the status code of the last hop of the fetch of
+// the given page, which for redirected pages that failed is the status
+// of the URL they were redirected to.
+func lastStatus(p *Page) int {
+	var fetchErr *FetchError
+	if len(p.Redirects) > 0 && errors.As(p.Err, &fetchErr) {
+		return fetchErr.StatusCode
+	}
+	return p.StatusCode
+}
@@ -0,0 +1,124 @@
+package crawler_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestBrokenLinksReportFormatter(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	page := func(parent string, path string, p crawler.Page) crawler.Result {
+		r := result(entrypoint, parent, path)
+		r.Page = &p
+		return r
+	}
+	notFound := crawler.Page{StatusCode: 404, Err: errors.New("not found")}
+	unreachable := crawler.Page{Err: &crawler.FetchError{
+		Phase: crawler.TransportPhase,
+		Err:   errors.New("connection refused"),
+	}}
+
+	cases := []FormatterTestCase{
+		{
+			name:    "empty",
+			results: []crawler.Result{},
+			want:    "",
+		},
+		{
+			name: "noBrokenLinks",
+			results: []crawler.Result{
+				page("", "", crawler.Page{StatusCode: 200}),
+				result(entrypoint, "", "/a"),
+				page("", "/a", crawler.Page{StatusCode: 204}),
+				result(entrypoint, "/a", "/notcrawled"),
+			},
+			want: "",
+		},
+		{
+			name: "brokenLinks",
+			results: []crawler.Result{
+				page("", "", crawler.Page{StatusCode: 200}),
+				result(entrypoint, "", "/missing"),
+				result(entrypoint, "", "/a"),
+				result(entrypoint, "", "/down"),
+				page("", "/missing", notFound),
+				page("", "/a", crawler.Page{StatusCode: 200}),
+				result(entrypoint, "/a", "/missing"),
+				result(entrypoint, "/a", "/missing"),
+				result(entrypoint, "/a", "/error"),
+				page("", "/down", unreachable),
+				page("/a", "/error", crawler.Page{StatusCode: 500}),
+				result(entrypoint, "/error", "/a"),
+			},
+			want: "http://test/down: unreachable: connection refused\n" +
+				"  found on http://test\n" +
+				"http://test/error: 500 Internal Server Error\n" +
+				"  found on http://test/a\n" +
+				"http://test/missing: 404 Not Found\n" +
+				"  found on http://test\n" +
+				"  found on http://test/a\n",
+		},
+		{
+			name: "redirects",
+			results: []crawler.Result{
+				page("", "", crawler.Page{StatusCode: 200}),
+				result(entrypoint, "", "/old"),
+				result(entrypoint, "", "/loop"),
+				page("", "/old", crawler.Page{StatusCode: 301, Redirects: []crawler.Redirect{{}}}),
+				page("", "/loop", crawler.Page{
+					StatusCode: 302,
+					Err:        crawler.ErrRedirectLoop,
+					Redirects:  []crawler.Redirect{{}, {}},
+				}),
+			},
+			want: "http://test/loop: redirect loop\n" +
+				"  found on http://test\n",
+		},
+		{
+			name: "redirectsToBrokenLinks",
+			results: []crawler.Result{
+				page("", "", crawler.Page{StatusCode: 200}),
+				result(entrypoint, "", "/old"),
+				page("", "/old", crawler.Page{
+					StatusCode: 301,
+					Err: &crawler.FetchError{
+						Phase:      crawler.StatusPhase,
+						StatusCode: 404,
+					},
+					Redirects: []crawler.Redirect{{
+						URL:        url.URL{Scheme: "http", Host: "test", Path: "/old"},
+						StatusCode: 301,
+						Location:   url.URL{Scheme: "http", Host: "test", Path: "/new"},
+					}},
+				}),
+				page("/old", "/new", notFound),
+			},
+			want: "http://test/new: 404 Not Found\n" +
+				"  found on http://test/old\n" +
+				"http://test/old: 301 Moved Permanently to http://test/new: 404 Not Found\n" +
+				"  found on http://test\n",
+		},
+	}
+
+	for _, c := range cases {
+		testFormatter(t, c, crawler.FormatAsBrokenLinksReport)
+	}
+}
+
+func TestBrokenLinksReportFormatterFailsOnWriteError(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	broken := result(entrypoint, "", "/missing")
+	broken.Page = &crawler.Page{StatusCode: 404}
+
+	res := make(chan crawler.Result, 1)
+	res <- broken
+	close(res)
+
+	err := crawler.FormatAsBrokenLinksReport(res, &explodingWriter{failOnCall: 1})
+	if err == nil {
+		t.Fatal("expected error on failed write")
+	}
+}
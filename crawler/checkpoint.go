@@ -0,0 +1,293 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint is the state of a crawling, with everything needed to
+// resume it: the URLs still to be crawled (the frontier), the URLs
+// already visited and the results already emitted.
+type Checkpoint struct {
+	// Entrypoint is the normalized entrypoint of the crawling
+	Entrypoint string `json:"entrypoint"`
+	// Frontier are the URLs that are still going to be crawled
+	Frontier []FrontierJob `json:"frontier"`
+	// Visited are the URLs that were already crawled or scheduled
+	Visited []string `json:"visited"`
+	// Emitted are the results already sent, as given by Result.String
+	Emitted []string `json:"emitted"`
+	// Scheduled is how many URLs were scheduled, used by WithMaxPages
+	Scheduled uint `json:"scheduled"`
+}
+
+// LoadCheckpoint loads a checkpoint from the given file
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read checkpoint[%s]: %s", path, err)
+	}
+
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("unable to parse checkpoint[%s]: %s", path, err)
+	}
+	return cp, nil
+}
+
+// SaveCheckpoint saves the checkpoint on the given file. The checkpoint
+// is written on a temporary file that then replaces the given file,
+// so a failure never leaves a corrupted checkpoint behind.
+func SaveCheckpoint(path string, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("unable to encode checkpoint[%s]: %s", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create checkpoint[%s]: %s", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write checkpoint[%s]: %s", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("unable to write checkpoint[%s]: %s", path, err)
+	}
+	return nil
+}
+
+// crawlState is all the state of the scheduler that is
+// saved on checkpoints and restored when resuming.
+type crawlState struct {
+	entrypoint url.URL
+	urls       Frontier
+	checks     Frontier
+	// inflight are the jobs taken from the frontiers without results
+	// yet, they are saved on checkpoints so they are crawled on resume.
+	inflight  map[string]job
+	visited   VisitedSet
+	emitted   VisitedSet
+	scheduled uint
+}
+
+func newCrawlState(entrypoint url.URL, cfg Config) (*crawlState, error) {
+	s, err := openCrawlState(entrypoint, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.urls.Push(newFrontierJob(job{url: entrypoint})); err != nil {
+		s.close()
+		return nil, err
+	}
+	if _, err := s.visited.Add(entrypoint.String()); err != nil {
+		s.close()
+		return nil, err
+	}
+	s.scheduled = 1
+	return s, nil
+}
+
+// restoreCrawlState restores the state saved on the checkpoint,
+// which must be from a crawling of the given entrypoint.
+func restoreCrawlState(entrypoint url.URL, cfg Config, cp *Checkpoint) (*crawlState, error) {
+	if cp.Entrypoint != entrypoint.String() {
+		return nil, fmt.Errorf(
+			"checkpoint entrypoint[%s] differs from entrypoint[%s]",
+			cp.Entrypoint,
+			entrypoint.String())
+	}
+
+	s, err := openCrawlState(entrypoint, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.restore(cp); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// openCrawlState creates the frontiers and visited sets of the state,
+// the frontier of URLs to be crawled is created by the strategy.
+func openCrawlState(entrypoint url.URL, cfg Config) (*crawlState, error) {
+	s := &crawlState{
+		entrypoint: entrypoint,
+		inflight:   map[string]job{},
+	}
+
+	storage := cfg.Storage
+	var err error
+	if s.urls, err = cfg.Strategy(storage); err != nil {
+		return nil, err
+	}
+	if s.checks, err = storage.NewFrontier(); err != nil {
+		s.close()
+		return nil, err
+	}
+	if s.visited, err = newCrawlVisitedSet(storage, "visited urls", entrypoint); err != nil {
+		s.close()
+		return nil, err
+	}
+	if s.emitted, err = newCrawlVisitedSet(storage, "sent results", entrypoint); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *crawlState) restore(cp *Checkpoint) error {
+	s.scheduled = cp.Scheduled
+
+	for _, u := range cp.Visited {
+		if _, err := s.visited.Add(u); err != nil {
+			return err
+		}
+	}
+	for _, r := range cp.Emitted {
+		if _, err := s.emitted.Add(r); err != nil {
+			return err
+		}
+	}
+
+	for _, j := range cp.Frontier {
+		if _, err := newJob(j); err != nil {
+			return err
+		}
+		frontier := s.urls
+		if j.External {
+			frontier = s.checks
+		}
+		if err := frontier.Push(j); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newJob(j FrontierJob) (job, error) {
+	u, err := url.Parse(j.URL)
+	if err != nil {
+		return job{}, fmt.Errorf("invalid url[%s] on frontier: %s", j.URL, err)
+	}
+	parent, err := url.Parse(j.Parent)
+	if err != nil {
+		return job{}, fmt.Errorf("invalid parent url[%s] on frontier: %s", j.Parent, err)
+	}
+
+	return job{
+		url:   *u,
+		depth: j.Depth,
+		from: Result{
+			Parent:      *parent,
+			Link:        *u,
+			Kind:        j.Kind,
+			External:    j.External,
+			FromSitemap: j.FromSitemap,
+		},
+	}, nil
+}
+
+func newFrontierJob(j job) FrontierJob {
+	return FrontierJob{
+		URL:         j.url.String(),
+		Depth:       j.depth,
+		Parent:      j.from.Parent.String(),
+		Kind:        j.from.Kind,
+		External:    j.from.External,
+		FromSitemap: j.from.FromSitemap,
+	}
+}
+
+// next takes the next job from the frontier, keeping it in flight,
+// it returns nil if the frontier is empty.
+func (s *crawlState) next(frontier Frontier) (*job, error) {
+	fj, ok, err := frontier.Pop()
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	j, err := newJob(fj)
+	if err != nil {
+		return nil, err
+	}
+	s.inflight[j.url.String()] = j
+	return &j, nil
+}
+
+func (s *crawlState) checkpoint() (*Checkpoint, error) {
+	cp := &Checkpoint{
+		Entrypoint: s.entrypoint.String(),
+		Frontier:   []FrontierJob{},
+		Visited:    []string{},
+		Emitted:    []string{},
+		Scheduled:  s.scheduled,
+	}
+
+	addJob := func(j FrontierJob) error {
+		cp.Frontier = append(cp.Frontier, j)
+		return nil
+	}
+	if err := s.urls.Range(addJob); err != nil {
+		return nil, err
+	}
+	if err := s.checks.Range(addJob); err != nil {
+		return nil, err
+	}
+	for _, j := range s.inflight {
+		addJob(newFrontierJob(j))
+	}
+
+	err := s.visited.Range(func(u string) error {
+		cp.Visited = append(cp.Visited, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.emitted.Range(func(r string) error {
+		cp.Emitted = append(cp.Emitted, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+// pending returns true if there are jobs to be crawled, or
+// checked, when external links are checked, on the frontiers.
+func (s *crawlState) pending(checks bool) bool {
+	return s.urls.Len() > 0 || (checks && s.checks.Len() > 0)
+}
+
+// close closes all frontiers and visited sets of the state,
+// returning the first error found.
+func (s *crawlState) close() error {
+	var err error
+	for _, closer := range []interface{ Close() error }{s.urls, s.checks, s.visited, s.emitted} {
+		if closer == nil {
+			continue
+		}
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package crawler_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestCheckpointSaveAndLoad(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "checkpoint.json")
+	want := &crawler.Checkpoint{
+		Entrypoint: "http://test",
+		Frontier: []crawler.FrontierJob{
+			{URL: "http://test/a", Depth: 1, Parent: "http://test", Kind: "page"},
+			{URL: "http://other/b", Depth: 2, Parent: "http://test/c", Kind: "page", External: true},
+		},
+		Visited:   []string{"http://test", "http://test/a", "http://other/b"},
+		Emitted:   []string{"http://test->http://test/a"},
+		Scheduled: 2,
+	}
+
+	fatalerr(t, crawler.SaveCheckpoint(path, want), "saving checkpoint")
+
+	got, err := crawler.LoadCheckpoint(path)
+	fatalerr(t, err, "loading checkpoint")
+
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want[%+v] != got[%+v]", want, got)
+	}
+
+	files, err := os.ReadDir(dir)
+	fatalerr(t, err, "reading checkpoint dir")
+	if len(files) != 1 {
+		t.Fatalf("want only the checkpoint file, got %d files", len(files))
+	}
+}
+
+func TestLoadCheckpointFailsOnInvalidFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "checkpoint.json")
+	if _, err := crawler.LoadCheckpoint(path); err == nil {
+		t.Fatal("expected error loading missing checkpoint")
+	}
+
+	fatalerr(t, os.WriteFile(path, []byte("{"), 0644), "writing checkpoint")
+	if _, err := crawler.LoadCheckpoint(path); err == nil {
+		t.Fatal("expected error loading invalid checkpoint")
+	}
+}
+
+func TestCrawlingResumesFromCheckpoint(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "checkpoint.json")
+	blocking := int32(1)
+	blocked := make(chan struct{})
+	var once sync.Once
+
+	files := http.FileServer(http.Dir("./testdata/fakesite"))
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&blocking) == 1 && r.URL.Path == "/final.html" {
+			once.Do(func() { close(blocked) })
+			<-r.Context().Done()
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results, errs := crawler.New(
+		crawler.WithCheckpoints(path, 0),
+	).Run(ctx, entrypoint)
+
+	go func() {
+		<-blocked
+		cancel()
+	}()
+
+	got := collectResults(results, errs)
+
+	// WHY: /final.html is only found after the entrypoint
+	//      links are sent, so the crawling must be partial.
+	if len(got) == 0 || len(got) == len(fakesiteResults(entrypoint)) {
+		t.Fatalf("want a partial crawling, got results: %v", got)
+	}
+
+	atomic.StoreInt32(&blocking, 0)
+
+	cp, err := crawler.LoadCheckpoint(path)
+	fatalerr(t, err, "loading checkpoint")
+
+	results, errs = crawler.New(
+		crawler.WithResume(cp),
+	).Run(context.Background(), entrypoint)
+
+	for _, res := range collectResults(results, errs) {
+		if containsResult(got, res) {
+			t.Errorf("result[%s] emitted again after resuming", res)
+		}
+		got = append(got, res)
+	}
+
+	want := fakesiteResults(entrypoint)
+	for _, res := range got {
+		want = removeResult(t, want, res)
+	}
+	if len(want) > 0 {
+		t.Errorf("missing wanted results: %+v", want)
+	}
+}
+
+func TestCrawlerFailsToResumeCheckpointFromOtherEntrypoint(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	const wantErrs = 1
+
+	results, errs := crawler.New(crawler.WithResume(&crawler.Checkpoint{
+		Entrypoint: "http://other",
+	})).Run(context.Background(), entrypoint)
+
+	checkResults(t, results, errs, []crawler.Result{}, wantErrs)
+}
+
+func collectResults(results <-chan crawler.Result, errs <-chan error) []crawler.Result {
+	go func() {
+		for range errs {
+		}
+	}()
+
+	got := []crawler.Result{}
+	for res := range results {
+		got = append(got, res)
+	}
+	return got
+}
+
+func containsResult(results []crawler.Result, res crawler.Result) bool {
+	for _, r := range results {
+		if r == res {
+			return true
+		}
+	}
+	return false
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := os.MkdirTemp("", "crawler-test")
+	fatalerr(t, err, "creating temp dir")
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// ErrBodyTooLarge is the cause of the failure to parse a sitemap
+// larger than the max body size. Pages larger than it are not
+// failures, they are just not parsed.
+var ErrBodyTooLarge = errors.New("body larger than the max body size")
+
+// htmlTypes are the media types of the responses
+// parsed when the content type is checked.
+var htmlTypes = map[string]bool{
+	"text/html":             true,
+	"application/xhtml+xml": true,
+}
+
+// parseable returns false if the given response must not be parsed,
+// because it is larger than the max body size, according to its
+// Content-Length, or because its content type is not HTML, when the
+// content type is checked.
+func parseable(cfg Config, res *http.Response) bool {
+	if cfg.MaxBodySize > 0 && res.ContentLength > cfg.MaxBodySize {
+		return false
+	}
+	return !cfg.CheckContentType || isHTML(res.Header.Get("Content-Type"))
+}
+
+// isHTML returns true if the given Content-Type is HTML or if it is
+// missing or invalid, since then the content type is unknown.
+func isHTML(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return htmlTypes[mediaType]
+}
+
+// probe makes a HEAD request to the given URL, returning a leaf page if
+// its response shows that it must not be parsed, so its body is never
+// downloaded. Otherwise nil is returned and the URL must be fetched.
+//
+// Since some servers do not handle HEAD requests properly failures are
+// ignored, unless the server asks the crawler to slow down.
+func probe(
+	ctx context.Context,
+	c *http.Client,
+	cfg Config,
+	u url.URL,
+) (*Page, error) {
+	probed := &Page{}
+
+	res, closeRes, err := fetch(ctx, c, cfg, http.MethodHead, u, probed)
+	defer closeRes()
+
+	var throttled *throttledError
+	if errors.As(err, &throttled) {
+		return probed, err
+	}
+	if err != nil || res == nil || parseable(cfg, res) {
+		return nil, nil
+	}
+
+	probed.Leaf = true
+	return probed, nil
+}
+
+// bodyReader reads a response body counting how many bytes were read.
+// When there is a max body size it reads at most one byte more than it,
+// so it is known that the body is larger than the max body size.
+type bodyReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+func newBodyReader(cfg Config, res *http.Response) *bodyReader {
+	return &bodyReader{r: res.Body, max: cfg.MaxBodySize}
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	if b.max > 0 {
+		left := b.max + 1 - b.read
+		if left <= 0 {
+			return 0, io.EOF
+		}
+		if int64(len(p)) > left {
+			p = p[:left]
+		}
+	}
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+// exceeded returns true if the body is larger than the max body size
+func (b *bodyReader) exceeded() bool {
+	return b.max > 0 && b.read > b.max
+}
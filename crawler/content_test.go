@@ -0,0 +1,216 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestCrawlingContentTypes(t *testing.T) {
+	type tcase struct {
+		name       string
+		opts       []crawler.Option
+		want       []string
+		notFetched []string
+	}
+
+	linked := []string{"/doc.pdf", "/notype", "/big", "/chunked"}
+	parents := map[string]string{
+		"/frompdf":     "/doc.pdf",
+		"/fromnotype":  "/notype",
+		"/frombig":     "/big",
+		"/fromchunked": "/chunked",
+	}
+
+	cases := []tcase{
+		{
+			name: "ParsesEverythingByDefault",
+			want: append(linked, "/frompdf", "/fromnotype", "/frombig", "/fromchunked"),
+		},
+		{
+			name: "ContentTypeCheck",
+			opts: []crawler.Option{crawler.WithContentTypeCheck()},
+			want: append(linked, "/fromnotype", "/frombig", "/fromchunked"),
+		},
+		{
+			name:       "HeadProbe",
+			opts:       []crawler.Option{crawler.WithHeadProbe()},
+			want:       append(linked, "/fromnotype", "/frombig", "/fromchunked"),
+			notFetched: []string{"GET /doc.pdf"},
+		},
+		{
+			name: "MaxBodySize",
+			opts: []crawler.Option{crawler.WithMaxBodySize(1000)},
+			want: append(linked, "/frompdf", "/fromnotype"),
+		},
+		{
+			name:       "HeadProbeWithMaxBodySize",
+			opts:       []crawler.Option{crawler.WithHeadProbe(), crawler.WithMaxBodySize(1000)},
+			want:       append(linked, "/fromnotype"),
+			notFetched: []string{"GET /doc.pdf", "GET /big"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entrypoint, requests, teardown := setupContentServer(t)
+			defer teardown()
+
+			want := []crawler.Result{}
+			for _, path := range c.want {
+				want = append(want, result(entrypoint, parents[path], path))
+			}
+
+			opts := append(c.opts, crawler.WithoutRobots())
+			results, errs := crawler.New(opts...).Run(context.Background(), entrypoint)
+			checkResults(t, results, errs, want, 0)
+
+			for _, request := range c.notFetched {
+				if requests()[request] > 0 {
+					t.Errorf("want no request %q, got %v", request, requests())
+				}
+			}
+		})
+	}
+}
+
+func TestCrawlingLeafPageResults(t *testing.T) {
+	entrypoint, _, teardown := setupContentServer(t)
+	defer teardown()
+
+	results, errs := crawler.New(
+		crawler.WithoutRobots(),
+		crawler.WithPageResults(),
+		crawler.WithHeadProbe(),
+		crawler.WithMaxBodySize(1000),
+	).Run(context.Background(), entrypoint)
+
+	pages := map[string]crawler.Page{}
+	for _, res := range collectResults(results, errs) {
+		if res.Page != nil {
+			pages[res.Link.Path] = *res.Page
+		}
+	}
+
+	// WHY: Without a Content-Length the size is how much of the body
+	//      was read, one byte more than the max body size.
+	wantLeaves := map[string]crawler.Page{
+		"/doc.pdf": {ContentType: "application/pdf", Size: int64(len(pdfBody))},
+		"/big":     {ContentType: "text/html", Size: int64(len(bigBody()))},
+		"/chunked": {ContentType: "text/html", Size: 1001, Truncated: true},
+	}
+	for path, want := range wantLeaves {
+		got := pages[path]
+		if !got.Leaf || got.ContentType != want.ContentType ||
+			got.Size != want.Size || got.Truncated != want.Truncated {
+			t.Errorf("page[%s]: want leaf with type %q, size %d and truncated %t, got %+v",
+				path, want.ContentType, want.Size, want.Truncated, got)
+		}
+	}
+
+	for _, path := range []string{"", "/notype"} {
+		if pages[path].Leaf {
+			t.Errorf("page[%s]: want parsed page, got leaf", path)
+		}
+	}
+}
+
+func TestCrawlingWithSitemapLargerThanMaxBodySize(t *testing.T) {
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprint(w, `<urlset><url><loc>/a</loc></url>`+strings.Repeat(" ", 1000)+`</urlset>`)
+		case "/":
+			fmt.Fprint(w, `<a href="/b"></a>`)
+		}
+	}))
+	defer teardown()
+
+	sitemap := entrypoint
+	sitemap.Path = "/sitemap.xml"
+
+	results, errs := crawler.New(
+		crawler.WithoutRobots(),
+		crawler.WithSitemaps(sitemap),
+		crawler.WithMaxBodySize(100),
+	).Run(context.Background(), entrypoint)
+	go func() {
+		for range results {
+		}
+	}()
+
+	gotErrs := 0
+	for err := range errs {
+		gotErrs++
+		if !errors.Is(err, crawler.ErrBodyTooLarge) {
+			t.Errorf("want ErrBodyTooLarge, got %v", err)
+		}
+	}
+	if gotErrs != 1 {
+		t.Fatalf("want 1 error, got %d", gotErrs)
+	}
+}
+
+const pdfBody = `%PDF-1.4 <a href="/frompdf"></a>`
+
+func bigBody() string {
+	return `<a href="/frombig"></a>` + strings.Repeat(" ", 2000)
+}
+
+// setupContentServer creates a server whose entrypoint links to a PDF
+// with a link on it, a page without Content-Type and two pages larger
+// than 1000 bytes, one with Content-Length and one without it.
+// Besides the teardown it returns a function that returns how many
+// requests were made with each method and path, like "HEAD /doc.pdf".
+func setupContentServer(t *testing.T) (url.URL, func() map[string]int, func()) {
+	var mutex sync.Mutex
+	requests := map[string]int{}
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mutex.Unlock()
+
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/doc.pdf"></a><a href="/notype"></a><a href="/big"></a><a href="/chunked"></a>`)
+		case "/doc.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("Content-Length", strconv.Itoa(len(pdfBody)))
+			fmt.Fprint(w, pdfBody)
+		case "/notype":
+			// WHY: Without it the server sniffs the content type
+			w.Header()["Content-Type"] = nil
+			fmt.Fprint(w, `<a href="/fromnotype"></a>`)
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Length", strconv.Itoa(len(bigBody())))
+			fmt.Fprint(w, bigBody())
+		case "/chunked":
+			w.Header().Set("Content-Type", "text/html")
+			// WHY: Flushing before writing the body sends
+			//      the response without a Content-Length.
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, strings.Replace(bigBody(), "/frombig", "/fromchunked", 1))
+		}
+	}))
+
+	return entrypoint, func() map[string]int {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		copied := map[string]int{}
+		for request, count := range requests {
+			copied[request] = count
+		}
+		return copied
+	}, teardown
+}
@@ -6,8 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
-	"path"
+	"sync"
 	"time"
 
 	"github.com/katcipis/crawler/parser"
@@ -19,12 +20,106 @@ type Result struct {
 	Link url.URL
 	// Parent is the URL used to reach the Link URL
 	Parent url.URL
+	// Kind is the kind of the link found on Parent
+	Kind parser.LinkKind
+	// NoFollow is true when the link has rel="nofollow" or Parent
+	// has a nofollow robots directive. These links are not followed.
+	NoFollow bool
+	// External is true when Link is out of the crawling scope.
+	// External links are only sent when the WithExternalLinks option
+	// is used, they are checked but never crawled.
+	External bool
+	// FromSitemap is true when Link was discovered on a sitemap, then
+	// Parent is the sitemap URL, instead of being found on a page.
+	// Sitemaps are only used with the WithSitemaps option.
+	FromSitemap bool
+	// RedirectStatus is the status code of redirect results, whose
+	// Kind is parser.RedirectLink and Parent was redirected to Link.
+	// The page result of the URL a redirect chain ended on is also
+	// the result of its last redirect, which is not sent again.
+	RedirectStatus int
+	// Seed is the seed whose crawling found the result,
+	// it is only set when crawling with RunSeeds.
+	Seed url.URL
+	// Page is only set on page results, which are not links but
+	// information about the crawled Link URL. Page results are only
+	// sent when the WithPageResults option is used.
+	Page *Page
+}
+
+// Page has information about a crawled URL
+type Page struct {
+	// Depth is the distance in links from the entrypoint,
+	// which has a depth of zero.
+	Depth uint
+	// StatusCode is the status code of the response,
+	// zero if no response was received.
+	StatusCode int
+	// ContentType is the Content-Type header of the response
+	ContentType string
+	// Latency is the time spent fetching the page, from asking for a
+	// connection until its body was read, not counting rate limiting.
+	Latency time.Duration
+	// Err is the error that made the crawling of the page fail,
+	// nil if the page was crawled successfully.
+	Err error
+	// NoIndex is true when the page asked to not be indexed,
+	// using a robots meta tag or a X-Robots-Tag header.
+	NoIndex bool
+	// NoFollow is true when the page asked for its links to not be
+	// followed, using a robots meta tag or a X-Robots-Tag header.
+	NoFollow bool
+	// Canonical is the URL from <link rel="canonical">, if any.
+	Canonical url.URL
+	// LastModified is the time from the Last-Modified header,
+	// zero if the header is missing or invalid.
+	LastModified time.Time
+	// Redirects is the redirect chain followed when fetching the
+	// page, empty if it was not redirected. The page result of the
+	// URL it was redirected to, if it was crawled, is sent apart.
+	Redirects []Redirect
+	// Size is the size of the body in bytes, from the Content-Length
+	// header or from how much of it was read, zero if it is unknown.
+	Size int64
+	// Leaf is true when the body was not parsed, because it is not
+	// HTML or it is larger than the max body size, so the page has
+	// no links. Check WithContentTypeCheck and WithMaxBodySize.
+	Leaf bool
+	// Truncated is true when reading the body stopped at the max body
+	// size, because the Content-Length was missing or wrong. Without
+	// a Content-Length the Size is how much of the body was read.
+	Truncated bool
+	// Title is the text of the <title> of the page
+	Title string
+	// Description is the content of the <meta name="description">
+	Description string
+	// H1s are the texts of all the <h1> headings of the page
+	H1s []string
+	// Lang is the lang attribute of the <html> element
+	Lang string
+	// Alternates are the translations of the page, from the
+	// <link rel="alternate" hreflang> elements, with absolute URLs.
+	Alternates []parser.Alternate
+	// WordCount is the amount of words of the text of the page
+	WordCount int
 }
 
 func (r Result) String() string {
 	return fmt.Sprintf("%s->%s", r.Parent.String(), r.Link.String())
 }
 
+// Crawler is a concurrent crawler, use New to create one.
+type Crawler struct {
+	cfg Config
+}
+
+// New creates a new Crawler configured by the given options.
+// Without any option the crawler uses DefaultConcurrency crawlers,
+// DefaultRequestTimeout on each request and DefaultUserAgent.
+func New(opts ...Option) *Crawler {
+	return &Crawler{cfg: newConfig(opts)}
+}
+
 // Start will start N concurrent crawlers and return a channel
 // where all results from the crawling can be received.
 //
@@ -33,8 +128,116 @@ func (r Result) String() string {
 // timeout of each request made. It is an error to pass
 // 0 as the concurrency parameter.
 //
-// The crawler will only follow links from the same domain
-// of the provided entry point URL.
+// Start is a shortcut to New(opts...).Run(ctx, entrypoint) where the
+// concurrency and timeout parameters take priority over the options.
+// Check Run for details on how the crawling works.
+func Start(
+	ctx context.Context,
+	entrypoint url.URL,
+	concurrency uint,
+	timeout time.Duration,
+	opts ...Option,
+) (<-chan Result, <-chan error) {
+	opts = append(opts, WithConcurrency(concurrency), WithRequestTimeout(timeout))
+	return New(opts...).Run(ctx, entrypoint)
+}
+
+// StartSeeds is like Start, but crawls all the given seeds.
+// It is a shortcut to New(opts...).RunSeeds(ctx, seeds), check
+// RunSeeds for details on how the seeds are crawled.
+func StartSeeds(
+	ctx context.Context,
+	seeds []url.URL,
+	concurrency uint,
+	timeout time.Duration,
+	opts ...Option,
+) (<-chan Result, <-chan error) {
+	opts = append(opts, WithConcurrency(concurrency), WithRequestTimeout(timeout))
+	return New(opts...).RunSeeds(ctx, seeds)
+}
+
+// Run will start the configured concurrent crawlers on the
+// given entrypoint and return a channel where all results from
+// the crawling can be received.
+//
+// The crawler will only follow links inside the crawling scope, by
+// default links with the same host of the provided entry point URL.
+// Use the WithScope option to change it.
+//
+// Before crawling the robots.txt of the entry point host is fetched
+// and URLs disallowed by it are not crawled, not even when redirected
+// to, each skipped URL is reported on the errors channel as a *SkipError.
+// If the robots.txt is unreachable nothing is crawled. The robots.txt of
+// other hosts inside the scope are fetched when their first link is found,
+// if they are unreachable the links of the host are skipped.
+// Use the WithoutRobots option to disable this.
+//
+// Headers, cookies and credentials can be sent with the WithHeader,
+// WithCookieJar, WithBasicAuth and WithBearerToken options, to crawl
+// private sites. They are only sent on requests to URLs in the crawling
+// scope, never to external links or redirects out of the scope.
+//
+// Requests can be rate limited per host with the WithRateLimit option,
+// and any Crawl-delay found on the robots.txt is always respected.
+// When a server answers with 429 or 503 and a Retry-After header
+// the host is backed off and the request is retried. Other transient
+// failures are retried with the WithRetries option.
+//
+// Only page links are sent as results and followed by default, which
+// kinds of links are followed or just sent as results can be changed
+// with the WithFollowedLinkKinds and WithReportedLinkKinds options.
+//
+// Links with rel="nofollow" and links from pages with a nofollow
+// robots directive are sent as results, but are not followed,
+// unless the WithoutRobots option is used.
+//
+// With the WithPageResults option a page result is sent for each
+// crawled URL, before the results with the links found on it. Page
+// results of HTML pages have their metadata, like their title, meta
+// description and headings, check Page for details.
+//
+// Every response is parsed searching for links by default. With the
+// WithContentTypeCheck option only HTML responses are parsed and the
+// other ones are leaf pages, with their content type and size. With the
+// WithHeadProbe option their bodies are not even downloaded and the
+// WithMaxBodySize option limits how many bytes are read of each response.
+//
+// Links out of the crawling scope are discarded by default. With the
+// WithExternalLinks option they are sent as results and checked,
+// by their own concurrent checkers, but they are never crawled.
+//
+// Redirects are followed and each hop is sent as a result of the
+// parser.RedirectLink kind, with its status code. The links found on a
+// redirected page are sent as links of the URL it was redirected to,
+// which is deduplicated like any other URL, so a page reached by many
+// redirects is crawled once. Redirects are only followed while they are
+// inside the crawling scope, redirects out of it are handled like links
+// out of the scope. Redirect loops are reported as a *FetchError on
+// the RedirectPhase.
+//
+// All URLs are normalized before being deduplicated, check Normalizer
+// for details. Use the WithNormalizer option to customize it.
+//
+// The crawling can be bounded with the WithMaxDepth and WithMaxPages
+// options. The depth of an URL is its distance in links from the
+// entrypoint, which has depth zero.
+//
+// With the WithSitemaps option the URLs found on the sitemaps of the
+// site are also crawled, so pages that no other page links to are found.
+// A sitemap that can not be fetched or parsed is reported on the errors
+// channel as a *FetchError.
+//
+// URLs are crawled breadth first, use the WithStrategy option to change it.
+//
+// The URLs waiting to be crawled and the ones already visited are kept
+// in memory, use the WithStorage option to keep them on disk instead.
+//
+// With the WithCheckpoints option the state of the crawling is saved
+// on checkpoints, so it can be resumed with the WithResume option.
+//
+// When the context is cancelled no more URLs are crawled, the ones
+// being crawled are waited for but their results are discarded, so
+// they are crawled again when resuming.
 //
 // Both the result and the errors channels must be drained.
 // If the caller reads only from the results channel the crawlers
@@ -42,112 +245,615 @@ func (r Result) String() string {
 //
 // All channels will be closed by the crawler when there is no more
 // URLs to crawl or the provided context expires.
-func Start(
-	ctx context.Context,
-	entrypoint url.URL,
-	concurrency uint,
-	timeout time.Duration,
-) (<-chan Result, <-chan error) {
+func (c *Crawler) Run(ctx context.Context, entrypoint url.URL) (<-chan Result, <-chan error) {
+	res := make(chan Result)
+	errs := make(chan error)
+
+	if err := c.cfg.validate(); err != nil {
+		go failRun(res, errs, err)
+		return res, errs
+	}
+
+	go func() {
+		defer close(res)
+		defer close(errs)
+
+		w := startWorkers(ctx, c.cfg, errs)
+		defer w.stop()
+
+		scheduler(ctx, res, errs, entrypoint, c.cfg, w)
+	}()
+
+	return res, errs
+}
 
+// RunSeeds crawls each of the given seeds as Run crawls its entrypoint,
+// each seed with its own crawling scope, deduplication and limits, as if
+// they were crawled by different runs. But all seeds share the same
+// concurrent crawlers and rate limits, so no more than the configured
+// concurrency of requests are made for all of them.
+//
+// The results of all seeds are sent on the same channel,
+// with Result.Seed set to the seed they were found from.
+//
+// The sitemaps given to WithSitemaps are read only once, by the first
+// seed on their host or, if there is none, by the first seed.
+//
+// Checkpoints are only supported when crawling a single seed.
+func (c *Crawler) RunSeeds(ctx context.Context, seeds []url.URL) (<-chan Result, <-chan error) {
 	res := make(chan Result)
 	errs := make(chan error)
 
-	if concurrency == 0 {
-		go func() {
-			errs <- errors.New("concurrency level must be greater than zero")
-			close(errs)
-			close(res)
-		}()
+	if err := c.cfg.validate(); err != nil {
+		go failRun(res, errs, err)
+		return res, errs
+	}
+
+	if len(seeds) == 0 {
+		go failRun(res, errs, errors.New("at least one seed must be given"))
 		return res, errs
 	}
 
-	go scheduler(ctx, res, errs, entrypoint, concurrency, timeout)
+	if len(seeds) > 1 && (c.cfg.CheckpointPath != "" || c.cfg.Resume != nil) {
+		go failRun(res, errs, errors.New("checkpoints can not be used when crawling many seeds"))
+		return res, errs
+	}
+
+	go func() {
+		defer close(res)
+		defer close(errs)
+
+		w := startWorkers(ctx, c.cfg, errs)
+		defer w.stop()
+
+		sitemaps := seedsSitemapURLs(c.cfg.SitemapURLs, seeds)
+
+		var wg sync.WaitGroup
+		for i, seed := range seeds {
+			cfg := c.cfg
+			cfg.SitemapURLs = sitemaps[i]
+
+			wg.Add(1)
+			go func(seed url.URL) {
+				defer wg.Done()
+
+				seedRes := make(chan Result)
+				go func() {
+					defer close(seedRes)
+					scheduler(ctx, seedRes, errs, seed, cfg, w)
+				}()
+
+				normalized := c.cfg.normalize(seed)
+				for r := range seedRes {
+					r.Seed = normalized
+					res <- r
+				}
+			}(seed)
+		}
+		wg.Wait()
+	}()
 
 	return res, errs
 }
 
+func failRun(res chan<- Result, errs chan<- error, err error) {
+	errs <- err
+	close(errs)
+	close(res)
+}
+
+// job is a URL to be crawled, its distance from the entrypoint
+// and the result that lead to the URL, which is empty for the entrypoint.
+// The result of the job is sent on the results channel of its scheduler,
+// which crawls the entrypoint.
+// The failed counts how many times fetching the URL already failed.
+// If robotsTxt is true the URL is a robots.txt to be fetched and kept
+// on the robots rules of the scheduler, which are nil when ignored.
+type job struct {
+	url        url.URL
+	depth      uint
+	from       Result
+	failed     uint
+	robotsTxt  bool
+	entrypoint url.URL
+	robots     *hostsRobots
+	results    chan<- crawlResult
+}
+
+// crawlResult are the results of crawling a job,
+// page.Err is set if the crawling failed. If retry is
+// true the failure was not reported and may be retried.
+type crawlResult struct {
+	job     job
+	page    *Page
+	results []Result
+	retry   bool
+}
+
+// heldResult is a result held until the robots.txt of its host is
+// fetched, with the depth where it was found and the URL of its parent
+// job, which is kept in flight until all its results are released.
+type heldResult struct {
+	res    Result
+	depth  uint
+	parent string
+}
+
+// workers are the concurrent crawlers and external links checkers,
+// shared by the schedulers of all seeds being crawled.
+type workers struct {
+	client    *http.Client
+	limiter   *hostLimiter
+	jobs      chan job
+	checkJobs chan job
+}
+
+func startWorkers(ctx context.Context, cfg Config, errs chan<- error) *workers {
+	limiter := newHostLimiter(cfg.rateInterval(), cfg.RateBurst)
+	w := &workers{
+		client:    newClient(cfg, limiter),
+		limiter:   limiter,
+		jobs:      make(chan job),
+		checkJobs: make(chan job),
+	}
+
+	for i := uint(0); i < cfg.Concurrency; i++ {
+		go crawler(ctx, cfg, w.client, w.limiter, w.jobs, errs)
+	}
+
+	if cfg.ExternalLinks {
+		for i := uint(0); i < cfg.ExternalConcurrency; i++ {
+			go checker(ctx, cfg, w.client, w.checkJobs, errs)
+		}
+	}
+
+	return w
+}
+
+// stop stops the workers, it must be called
+// only after all schedulers are done.
+func (w *workers) stop() {
+	close(w.jobs)
+	close(w.checkJobs)
+}
+
 func scheduler(
 	ctx context.Context,
 	filtered chan<- Result,
 	errs chan<- error,
 	entrypoint url.URL,
-	concurrency uint,
-	timeout time.Duration,
+	cfg Config,
+	w *workers,
 ) {
-	defer close(filtered)
-	defer close(errs)
+	entrypoint = cfg.normalize(entrypoint)
+	ctx = withEntrypoint(ctx, entrypoint)
+	limiter := w.limiter
+	client := w.client
+
+	var robots parser.Robots
+	var hostRobots *hostsRobots
+	var filterByRobots *robotsFilter
+	if !cfg.IgnoreRobots {
+		var err error
+		robots, err = fetchRobots(ctx, client, cfg, entrypoint)
+		if err != nil {
+			errs <- err
+			return
+		}
+		if !robots.Allowed(cfg.UserAgent, entrypoint) {
+			errs <- &SkipError{Link: entrypoint, Reason: robotsDisallowed}
+			return
+		}
+		hostRobots = newHostsRobots(client, cfg, limiter, entrypoint, robots, errs)
+		filterByRobots = newRobotsFilter(cfg, hostRobots, errs)
+		if delay, ok := robots.CrawlDelay(cfg.UserAgent); ok {
+			limiter.setHostInterval(entrypoint.Host, delay, 1)
+		}
+	}
+
+	crawlResults := make(chan crawlResult)
+
+	state, err := openState(entrypoint, cfg)
+	if err != nil {
+		errs <- err
+		return
+	}
+	defer func() {
+		if err := state.close(); err != nil {
+			errs <- err
+		}
+	}()
+
+	if cfg.Sitemaps && cfg.Resume == nil {
+		if err := seedSitemaps(state, entrypoint, cfg, robots); err != nil {
+			errs <- err
+			return
+		}
+	}
+
+	var checkpoints <-chan time.Time
+	if cfg.CheckpointPath != "" {
+		defer saveCheckpoint(cfg, state, errs)
+		if cfg.CheckpointInterval > 0 {
+			ticker := time.NewTicker(cfg.CheckpointInterval)
+			defer ticker.Stop()
+			checkpoints = ticker.C
+		}
+	}
+
+	done := ctx.Done()
+	canceled := false
+	crawling := uint(0)
+	checking := uint(0)
+	retried := uint(0)
+	retries := &retryQueue{}
+	defer retries.stop()
+	filterByUniqueness := newUniquenessFilter(state.visited)
+	filterResByUniqueness := newResUniquenessFilter(state.emitted)
+
+	// WHY: Failing to store the state of the crawling stops it
+	//      the same way a cancellation does, so the jobs already
+	//      sent are still waited for.
+	stop := func(err error) {
+		errs <- err
+		canceled = true
+		done = nil
+	}
+
+	// WHY: The robots.txt of new hosts are fetched by the crawlers,
+	//      so the scheduler is never blocked by them. Meanwhile the
+	//      results of each host are held and their parent jobs are
+	//      kept in flight, so they are crawled again when resuming.
+	robotsJobs := []job{}
+	held := map[string][]heldResult{}
+	holds := map[string]int{}
+
+	holdByRobots := func(results []Result, depth uint, parent string) []Result {
+		if filterByRobots == nil {
+			return results
+		}
+		allowed, hold, fetch := filterByRobots.filter(results)
+		for _, u := range fetch {
+			robotsJobs = append(robotsJobs, job{url: u, robotsTxt: true})
+		}
+		for _, res := range hold {
+			origin := robotsOrigin(res.Link)
+			held[origin] = append(held[origin], heldResult{
+				res:    res,
+				depth:  depth,
+				parent: parent,
+			})
+			holds[parent] += 1
+		}
+		return allowed
+	}
+
+	// emit sends the given results, found at the given depth,
+	// and pushes the ones that must be followed to the frontiers.
+	emit := func(results []Result, depth uint) error {
+		results, err := filterResByUniqueness(results)
+		if err != nil {
+			return err
+		}
+
+		for _, res := range results {
+			filtered <- res
+		}
 
-	crawlResults := make(chan []Result)
-	defer close(crawlResults)
+		if cfg.MaxDepth > 0 && depth > cfg.MaxDepth {
+			return nil
+		}
 
-	jobs := make(chan url.URL)
-	defer close(jobs)
+		followed := filterByKinds(results, cfg.FollowKinds)
+		if !cfg.IgnoreRobots {
+			followed = filterNoFollow(followed)
+		}
+		// WHY: URLs redirected to out of the scope were not
+		//      fetched, so they are checked like external links.
+		for _, res := range results {
+			if res.Kind == parser.RedirectLink && res.External {
+				followed = append(followed, res)
+			}
+		}
 
-	for i := uint(0); i < concurrency; i++ {
-		go crawler(ctx, jobs, timeout, crawlResults, errs)
+		followed, err = filterByUniqueness(followed)
+		if err != nil {
+			return err
+		}
+
+		for _, res := range followed {
+			next := newFrontierJob(job{
+				url:   res.Link,
+				depth: depth,
+				from:  res,
+			})
+			if res.External {
+				if !checkable(res.Link) {
+					continue
+				}
+				if err := state.checks.Push(next); err != nil {
+					return err
+				}
+				continue
+			}
+			if cfg.MaxPages > 0 && state.scheduled >= cfg.MaxPages {
+				continue
+			}
+			if err := state.urls.Push(next); err != nil {
+				return err
+			}
+			state.scheduled += 1
+		}
+		return nil
 	}
 
-	pendingURLs := []url.URL{entrypoint}
-	pendingJobs := 0
-	filterByUniqueness := newUniquenessFilter(entrypoint)
-	filterResByUniqueness := newResUniquenessFilter()
+	// release filters again the results held
+	// until the robots.txt of the given origin was fetched.
+	release := func(origin string) error {
+		filterByRobots.fetched(origin)
+		released := held[origin]
+		delete(held, origin)
 
-	for len(pendingURLs) > 0 || pendingJobs > 0 {
+		for _, h := range released {
+			holds[h.parent] -= 1
+			allowed := holdByRobots([]Result{h.res}, h.depth, h.parent)
+			if holds[h.parent] == 0 {
+				delete(holds, h.parent)
+				delete(state.inflight, h.parent)
+			}
+			if err := emit(allowed, h.depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var nextURL, nextCheck *job
 
-		var j chan<- url.URL
-		var pendingURL url.URL
+	for (!canceled && (state.pending(cfg.ExternalLinks) || len(robotsJobs) > 0 ||
+		nextURL != nil || nextCheck != nil || retries.len() > 0)) ||
+		crawling+checking > 0 {
 
-		if len(pendingURLs) > 0 {
-			j = jobs
-			pendingURL = pendingURLs[0]
+		// WHY: Jobs are only taken from the frontiers when there is
+		//      an idle crawler or checker, so the jobs found meanwhile
+		//      are taken into account by the crawling strategy.
+		//      Robots.txt to be fetched come first, since links are
+		//      held by them, then jobs waiting to be retried.
+		if !canceled && nextURL == nil && crawling < cfg.Concurrency {
+			if len(robotsJobs) > 0 {
+				robotsJob := robotsJobs[0]
+				robotsJobs = robotsJobs[1:]
+				nextURL = &robotsJob
+			} else if retry, ok := retries.pop(time.Now()); ok {
+				nextURL = &retry
+			} else if nextURL, err = state.next(state.urls); err != nil {
+				stop(err)
+			}
+		}
+		if !canceled && nextCheck == nil && cfg.ExternalLinks && checking < cfg.ExternalConcurrency {
+			if nextCheck, err = state.next(state.checks); err != nil {
+				stop(err)
+			}
+		}
+
+		var j chan<- job
+		var pendingURL job
+
+		if !canceled && nextURL != nil {
+			j = w.jobs
+			pendingURL = *nextURL
+			pendingURL.entrypoint = entrypoint
+			pendingURL.robots = hostRobots
+			pendingURL.results = crawlResults
+		}
+
+		var retryWake <-chan time.Time
+		if !canceled && nextURL == nil && crawling < cfg.Concurrency {
+			retryWake = retries.wake(time.Now())
+		}
+
+		var c chan<- job
+		var pendingCheck job
+
+		if !canceled && nextCheck != nil {
+			c = w.checkJobs
+			pendingCheck = *nextCheck
+			pendingCheck.entrypoint = entrypoint
+			pendingCheck.results = crawlResults
 		}
 
 		select {
 		case j <- pendingURL:
 			{
-				pendingURLs = pendingURLs[1:]
-				pendingJobs += 1
+				nextURL = nil
+				crawling += 1
+			}
+		case c <- pendingCheck:
+			{
+				nextCheck = nil
+				checking += 1
+			}
+		case <-retryWake:
+		case <-checkpoints:
+			saveCheckpoint(cfg, state, errs)
+		case <-done:
+			{
+				// WHY: The jobs already sent are still waited for,
+				//      since the crawlers always send their results,
+				//      but they are kept in flight so they are saved
+				//      on the checkpoint and crawled again on resume.
+				canceled = true
+				done = nil
 			}
 		case r := <-crawlResults:
 			{
-				results := filterBySameDomain(r)
+				if r.job.from.External {
+					checking -= 1
+				} else {
+					crawling -= 1
+				}
+				if canceled {
+					continue
+				}
+				if r.job.robotsTxt {
+					if err := release(robotsOrigin(r.job.url)); err != nil {
+						stop(err)
+					}
+					continue
+				}
+
+				// WHY: Jobs waiting to be retried are kept in flight,
+				//      so they are crawled again when resuming.
+				if r.retry {
+					if cfg.Retry.Budget == 0 || retried < cfg.Retry.Budget {
+						retried += 1
+						r.job.failed += 1
+						retries.push(r.job, time.Now().Add(cfg.Retry.backoff(r.job.failed)))
+						continue
+					}
+					errs <- r.page.Err
+				}
+
+				if r.page != nil {
+					r.page.Canonical = cfg.normalize(r.page.Canonical)
+					for i, redirect := range r.page.Redirects {
+						r.page.Redirects[i].URL = cfg.normalize(redirect.URL)
+						r.page.Redirects[i].Location = cfg.normalize(redirect.Location)
+					}
+				}
+
+				results := r.results
+				sitemap := r.job.from.Kind == parser.SitemapLink
+				if sitemap {
+					var sitemaps []Result
+					results, sitemaps = partitionSitemaps(results)
+					if err := scheduleSitemaps(state, sitemaps, r.job.depth); err != nil {
+						stop(err)
+						continue
+					}
+				}
+
+				var fresh bool
+				results = normalizeLinks(results, cfg)
+				results, fresh, err = filterByRedirect(r, results, filterByUniqueness)
+				if err != nil {
+					stop(err)
+					continue
+				}
+				if cfg.HonorCanonical {
+					results, err = filterByCanonical(r, results, filterByUniqueness)
+					if err != nil {
+						stop(err)
+						continue
+					}
+				}
+				results, external := partitionByScope(results, entrypoint, cfg.Scope)
 				results = filterSelfReferences(results)
-				results = filterResByUniqueness(results)
-				pendingJobs -= 1
+				results = filterByKinds(results, cfg.FollowKinds, cfg.ReportKinds)
+				results = filterByLinkFilters(results, cfg.LinkFilters)
+				depth := r.job.depth + 1
+				parent := r.job.url.String()
+				results = holdByRobots(results, depth, parent)
+				redirects, externalRedirects := partitionByScope(redirectResults(r), entrypoint, cfg.Scope)
+				redirects = holdByRobots(redirects, depth, parent)
+				if holds[parent] == 0 {
+					delete(state.inflight, parent)
+				}
+				if cfg.ExternalLinks {
+					external = filterByKinds(external, cfg.FollowKinds, cfg.ReportKinds)
+					external = filterByLinkFilters(external, cfg.LinkFilters)
+					results = append(results, external...)
+					redirects = append(redirects, externalRedirects...)
+				}
+				results = append(results, redirects...)
 
-				for _, res := range results {
-					filtered <- res
+				if cfg.PageResults && !sitemap {
+					page, redirected := redirectedPageResults(r, fresh)
+					filtered <- page
+					if redirected != nil {
+						// WHY: The page result of the URL redirected to is
+						//      the result of the last redirect, so it is
+						//      marked as emitted to not be sent twice.
+						if _, err := filterResByUniqueness([]Result{*redirected}); err != nil {
+							stop(err)
+							continue
+						}
+						filtered <- *redirected
+					}
 				}
 
-				pendingURLs = append(
-					pendingURLs,
-					filterByUniqueness(extractLinks(results))...)
+				if err := emit(results, depth); err != nil {
+					stop(err)
+				}
 			}
 		}
 
 	}
 }
 
+func openState(entrypoint url.URL, cfg Config) (*crawlState, error) {
+	if cfg.Resume != nil {
+		return restoreCrawlState(entrypoint, cfg, cfg.Resume)
+	}
+	return newCrawlState(entrypoint, cfg)
+}
+
+func saveCheckpoint(cfg Config, state *crawlState, errs chan<- error) {
+	cp, err := state.checkpoint()
+	if err == nil {
+		err = SaveCheckpoint(cfg.CheckpointPath, cp)
+	}
+	if err != nil {
+		errs <- err
+	}
+}
+
 // crawler will write one set (possibly empty) of results for each
-// job it reads from the jobs channel. Even on errors a empty results will
-// be written, so the caller can trust that after writing N jobs it can
-// expect N results. The errs channel will be used a side band of informational
-// errors about the crawling process and should be drained.
+// job it reads from the jobs channel, on the results channel of the job.
+// Even on errors a empty results will be written, so the caller can trust
+// that after writing N jobs it can expect N results. The errs channel will
+// be used a side band of informational errors about the crawling process
+// and should be drained.
 func crawler(
 	ctx context.Context,
-	jobs <-chan url.URL,
-	timeout time.Duration,
-	res chan<- []Result,
+	cfg Config,
+	client *http.Client,
+	limiter *hostLimiter,
+	jobs <-chan job,
 	errs chan<- error,
 ) {
-	client := &http.Client{Timeout: timeout}
+	for j := range jobs {
+		reqCtx := withEntrypoint(ctx, j.entrypoint)
+		if j.robotsTxt {
+			j.robots.fetch(reqCtx, j.url)
+			j.results <- crawlResult{job: j}
+			continue
+		}
+		if j.robots != nil {
+			reqCtx = withRobots(reqCtx, j.robots)
+		}
 
-	for url := range jobs {
-		nextLinks, err := getLinks(ctx, client, url)
+		fetch := getLinks
+		if j.from.Kind == parser.SitemapLink {
+			fetch = getSitemapLinks
+		}
+		page, nextLinks, err := getLinksPolitely(reqCtx, client, limiter, cfg, j.url, fetch)
 
 		if err != nil {
-			errs <- err
-			res <- nil
+			// WHY: Failures of the URL the job was redirected to
+			//      already have the URL redirected to it as parent.
+			var fetchErr *FetchError
+			if errors.As(err, &fetchErr) && fetchErr.URL == j.url {
+				fetchErr.Parent = j.from.Parent
+			}
+			// WHY: Jobs failed after the cancellation are discarded
+			//      by the scheduler, so they are not failures.
+			retry := ctx.Err() == nil && cfg.Retry.retryable(j.failed, err)
+			if ctx.Err() == nil && !retry {
+				errs <- err
+			}
+			page.Err = err
+			j.results <- crawlResult{job: j, page: page, retry: retry}
 			continue
 		}
 
@@ -155,107 +861,437 @@ func crawler(
 
 		for i, link := range nextLinks {
 			results[i] = Result{
-				Parent: url,
-				Link:   link,
+				Parent:      j.url,
+				Link:        link.URL,
+				Kind:        link.Kind,
+				NoFollow:    page.NoFollow || hasRel(link, "nofollow"),
+				FromSitemap: j.from.Kind == parser.SitemapLink,
 			}
 		}
 
-		res <- results
+		j.results <- crawlResult{job: j, page: page, results: results}
 	}
 }
 
-func getLinks(ctx context.Context, c *http.Client, u url.URL) ([]url.URL, error) {
-	req, err := http.NewRequest("GET", u.String(), nil)
+// pageResult creates the page result of a crawled job
+func pageResult(r crawlResult) Result {
+	page := r.page
+	page.Depth = r.job.depth
+
+	kind := r.job.from.Kind
+	if kind == "" {
+		kind = parser.PageLink
+	}
+
+	return Result{
+		Parent:      r.job.from.Parent,
+		Link:        r.job.url,
+		Kind:        kind,
+		External:    r.job.from.External,
+		FromSitemap: r.job.from.FromSitemap,
+		Page:        page,
+	}
+}
+
+func hasRel(link parser.Link, rel string) bool {
+	for _, r := range link.Rel {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// newClient copies the given client (or a default one if nil)
+// wrapping its transport so all requests respect the rate limits.
+func newClient(cfg Config, limiter *hostLimiter) *http.Client {
+	client := http.Client{}
+	if cfg.HTTPClient != nil {
+		client = *cfg.HTTPClient
+	}
+
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	client.CheckRedirect = newCheckRedirect(cfg, client.CheckRedirect)
+	client.Transport = &limitedTransport{
+		limiter: limiter,
+		next: &scopedTransport{
+			cfg:  cfg,
+			next: next,
+		},
+	}
+	return &client
+}
+
+// newRequest creates a request for the given URL, applying
+// the request timeout, user agent and request hook from the config.
+// The returned cancel function must always be called.
+func newRequest(
+	ctx context.Context,
+	cfg Config,
+	method string,
+	u url.URL,
+) (*http.Request, context.CancelFunc, error) {
+	req, err := http.NewRequest(method, u.String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable create GET request for url[%s]: %s", u.String(), err)
+		return nil, func() {}, err
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if cfg.RequestTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.RequestTimeout)
 	}
 
 	req = req.WithContext(ctx)
-	res, err := c.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("unable to GET url[%s]: %s", u.String(), err)
+	req.Header.Set("User-Agent", cfg.UserAgent)
+
+	if cfg.Hooks.OnRequest != nil {
+		cfg.Hooks.OnRequest(req)
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf(
-			"error status code[%d] on GET url[%s]",
-			res.StatusCode,
-			u.String())
+	return req, cancel, nil
+}
+
+// throttledError indicates that the server asked the crawler
+// to slow down and retry the request after some time.
+type throttledError struct {
+	retryAfter time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("throttled, retry after[%s]", e.retryAfter)
+}
+
+// linksFetcher fetches the given URL and returns the links found on it.
+// The returned page is never nil, even on errors it has the information
+// about the fetch.
+type linksFetcher func(context.Context, *http.Client, Config, url.URL) (*Page, []parser.Link, error)
+
+// getLinksPolitely backs off the host and retries getting the links
+// when the server asks the crawler to slow down.
+func getLinksPolitely(
+	ctx context.Context,
+	c *http.Client,
+	limiter *hostLimiter,
+	cfg Config,
+	u url.URL,
+	fetch linksFetcher,
+) (*Page, []parser.Link, error) {
+	for attempt := 1; ; attempt++ {
+		page, links, err := fetch(ctx, c, cfg, u)
+
+		var throttled *throttledError
+		if !errors.As(err, &throttled) {
+			return page, links, err
+		}
+
+		retryAfter := throttled.retryAfter
+		if retryAfter > maxRetryAfter {
+			retryAfter = maxRetryAfter
+		}
+		limiter.backoff(u.Host, time.Now().Add(retryAfter))
+
+		if attempt >= maxThrottledAttempts {
+			return page, nil, err
+		}
+	}
+}
+
+// getLinks fetches and parses the given URL. The returned page is
+// never nil, even on errors it has the information about the fetch.
+func getLinks(
+	ctx context.Context,
+	c *http.Client,
+	cfg Config,
+	u url.URL,
+) (*Page, []parser.Link, error) {
+	if cfg.HeadProbe {
+		probed, err := probe(ctx, c, cfg, u)
+		if probed != nil {
+			return probed, nil, err
+		}
+	}
+
+	crawled := &Page{}
+
+	res, closeRes, err := get(ctx, c, cfg, u, crawled)
+	defer closeRes()
+	if err != nil || res == nil {
+		return crawled, nil, err
 	}
 
 	//WHY: The web is a fierce jungle, it seems better to not trust
-	//     HTTP headers and just try to parse the body searching for links
-	links, err := parser.ExtractLinks(res.Body)
+	//     HTTP headers and just try to parse the body searching for links,
+	//     unless the content type check is enabled.
+	if !parseable(cfg, res) {
+		crawled.Leaf = true
+		return crawled, nil, nil
+	}
+
+	body := newBodyReader(cfg, res)
+	page, err := parser.ParsePage(body)
+	if res.ContentLength < 0 {
+		crawled.Size = body.read
+	}
+	if body.exceeded() {
+		crawled.Leaf = true
+		crawled.Truncated = true
+		return crawled, nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf(
-			"error parsing response body from GET url[%s]: %s",
-			u.String(),
-			err)
+		return crawled, nil, &FetchError{
+			URL:        u,
+			Method:     http.MethodGet,
+			Phase:      ParsePhase,
+			StatusCode: res.StatusCode,
+			Err:        err,
+		}
 	}
 
-	absLinks := make([]url.URL, len(links))
+	// WHY: The base must be the URL that was actually fetched, after
+	//      following redirects, like /dir being redirected to /dir/
+	base := *res.Request.URL
+	if page.Base != nil {
+		base = *base.ResolveReference(page.Base)
+	}
+
+	for i, link := range page.Links {
+		page.Links[i].URL = makeLinkAbsolute(base, link.URL)
+	}
 
-	for i, link := range links {
-		absLinks[i] = makeLinkAbsolute(u, link)
+	robots := page.Robots
+	for _, tag := range res.Header["X-Robots-Tag"] {
+		robots = robots.Merge(parser.ParseRobotsTag(cfg.UserAgent, tag))
 	}
 
-	return absLinks, nil
+	crawled.NoIndex = robots.NoIndex
+	crawled.NoFollow = robots.NoFollow
+
+	if page.Canonical != nil {
+		crawled.Canonical = makeLinkAbsolute(base, *page.Canonical)
+	}
+
+	crawled.Title = page.Title
+	crawled.Description = page.Description
+	crawled.H1s = page.H1s
+	crawled.Lang = page.Lang
+	crawled.WordCount = page.WordCount
+	for _, alternate := range page.Alternates {
+		alternate.URL = makeLinkAbsolute(base, alternate.URL)
+		crawled.Alternates = append(crawled.Alternates, alternate)
+	}
+
+	if lastModified, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		crawled.LastModified = lastModified
+	}
+
+	return crawled, page.Links, nil
+}
+
+// get makes a GET request to the given URL, setting the information
+// about the fetch on the given page. The response is only returned
+// if its status code is 200, it is nil without an error if the URL
+// was redirected out of the crawling scope. The returned function
+// must always be called, to release the resources of the request.
+func get(
+	ctx context.Context,
+	c *http.Client,
+	cfg Config,
+	u url.URL,
+	crawled *Page,
+) (*http.Response, func(), error) {
+	return fetch(ctx, c, cfg, http.MethodGet, u, crawled)
 }
 
-func makeLinkAbsolute(parent url.URL, link url.URL) url.URL {
-	if link.Host == "" {
-		link.Host = parent.Host
+// fetch works like get, but making a request with the given method.
+func fetch(
+	ctx context.Context,
+	c *http.Client,
+	cfg Config,
+	method string,
+	u url.URL,
+	crawled *Page,
+) (*http.Response, func(), error) {
+	ctx = withRedirects(ctx, &crawled.Redirects)
+	req, cancel, err := newRequest(ctx, cfg, method, u)
+	if err != nil {
+		return nil, cancel, &FetchError{URL: u, Method: method, Phase: RequestPhase, Err: err}
+	}
+
+	req, done := traceLatency(req, crawled)
+
+	res, err := c.Do(req)
+
+	// WHY: Failures after following redirects are failures
+	//      of the URL redirected to, found on the last redirect.
+	failed, parent := u, url.URL{}
+	if n := len(crawled.Redirects); n > 0 && !redirectsFailed(err) {
+		failed, parent = crawled.Redirects[n-1].Location, crawled.Redirects[n-1].URL
+	}
+
+	if err != nil {
+		phase := TransportPhase
+		if redirectsFailed(err) {
+			phase = RedirectPhase
+		}
+		return nil, func() {
+			done()
+			cancel()
+		}, &FetchError{URL: failed, Parent: parent, Method: method, Phase: phase, Err: err}
 	}
-	if link.Scheme == "" {
-		link.Scheme = parent.Scheme
+
+	closeRes := func() {
+		res.Body.Close()
+		done()
+		cancel()
 	}
-	if link.Path == "" {
-		return link
+
+	crawled.StatusCode = res.StatusCode
+	crawled.ContentType = res.Header.Get("Content-Type")
+	if res.ContentLength >= 0 {
+		crawled.Size = res.ContentLength
 	}
-	if link.Path[0] != '/' {
-		link.Path = path.Clean(parent.Path + "/" + link.Path)
+
+	if cfg.Hooks.OnResponse != nil {
+		cfg.Hooks.OnResponse(res)
 	}
-	if link.Path == "/" {
-		link.Path = ""
+
+	// WHY: Redirects out of the crawling scope are not followed,
+	//      there is nothing to crawl but it is not a failure.
+	if len(crawled.Redirects) > 0 && isRedirect(res.StatusCode) {
+		return nil, closeRes, nil
 	}
-	return link
+
+	if res.StatusCode == http.StatusTooManyRequests ||
+		res.StatusCode == http.StatusServiceUnavailable {
+		if retryAfter, ok := parseRetryAfter(res.Header, time.Now()); ok {
+			return nil, closeRes, &FetchError{
+				URL:        failed,
+				Parent:     parent,
+				Method:     method,
+				Phase:      StatusPhase,
+				StatusCode: res.StatusCode,
+				Err:        &throttledError{retryAfter: retryAfter},
+			}
+		}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, closeRes, &FetchError{
+			URL:        failed,
+			Parent:     parent,
+			Method:     method,
+			Phase:      StatusPhase,
+			StatusCode: res.StatusCode,
+		}
+	}
+
+	return res, closeRes, nil
 }
 
-func newResUniquenessFilter() func([]Result) []Result {
-	seen := map[string]bool{}
+// traceLatency traces the latency of the given request, which is set
+// on the given page when the returned done function is called.
+func traceLatency(req *http.Request, page *Page) (*http.Request, func()) {
+	var start time.Time
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// WHY: Connections are only asked for after the rate
+			//      limiter wait, so it is not part of the latency.
+			if start.IsZero() {
+				start = time.Now()
+			}
+		},
+	}))
+	return req, func() {
+		if !start.IsZero() {
+			page.Latency = time.Since(start)
+		}
+	}
+}
 
-	return func(results []Result) []Result {
+// makeLinkAbsolute resolves the link against the base URL
+// following RFC 3986 reference resolution.
+func makeLinkAbsolute(base url.URL, link url.URL) url.URL {
+	abs := *base.ResolveReference(&link)
+	// WHY: Fragments are never sent to servers, so a fragment-only
+	//      reference is the same document as its base.
+	abs.Fragment = ""
+	if abs.Path == "/" {
+		abs.Path = ""
+		abs.RawPath = ""
+	}
+	return abs
+}
+
+func newResUniquenessFilter(seen VisitedSet) func([]Result) ([]Result, error) {
+	return func(results []Result) ([]Result, error) {
 		filtered := []Result{}
 		for _, res := range results {
-			restr := res.String()
-			if !seen[restr] {
+			added, err := seen.Add(res.String())
+			if err != nil {
+				return nil, err
+			}
+			if added {
 				filtered = append(filtered, res)
-				seen[restr] = true
 			}
+		}
+		return filtered, nil
+	}
+}
 
+func newUniquenessFilter(seen VisitedSet) func([]Result) ([]Result, error) {
+	return func(results []Result) ([]Result, error) {
+		filtered := []Result{}
+		for _, res := range results {
+			added, err := seen.Add(res.Link.String())
+			if err != nil {
+				return nil, err
+			}
+			if added {
+				filtered = append(filtered, res)
+			}
 		}
-		return filtered
+		return filtered, nil
 	}
 }
 
-func newUniquenessFilter(entrypoint url.URL) func([]url.URL) []url.URL {
-	seen := map[string]bool{
-		entrypoint.String(): true,
+func normalizeLinks(results []Result, cfg Config) []Result {
+	for i, res := range results {
+		results[i].Link = cfg.normalize(res.Link)
 	}
+	return results
+}
 
-	return func(urls []url.URL) []url.URL {
-		filtered := []url.URL{}
-		for _, u := range urls {
-			ustr := u.String()
-			if !seen[ustr] {
-				filtered = append(filtered, u)
-				seen[ustr] = true
-			}
+// filterByCanonical sends the results of a page with a canonical URL as
+// results of the canonical URL, marking it as seen so it is not crawled.
+// If the canonical URL was already seen the results are discarded.
+func filterByCanonical(
+	r crawlResult,
+	results []Result,
+	filterByUniqueness func([]Result) ([]Result, error),
+) ([]Result, error) {
+	if r.page == nil || r.page.Canonical.String() == "" {
+		return results, nil
+	}
 
-		}
-		return filtered
+	canonical := r.page.Canonical
+	redirected := redirectedURL(r)
+	if canonical.String() == redirected.String() || canonical.Host != redirected.Host {
+		return results, nil
+	}
+
+	unique, err := filterByUniqueness([]Result{{Link: canonical}})
+	if err != nil || len(unique) == 0 {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Parent = canonical
 	}
+	return results, nil
 }
 
 func filterSelfReferences(results []Result) []Result {
@@ -270,11 +1306,33 @@ func filterSelfReferences(results []Result) []Result {
 	return filtered
 }
 
-func filterBySameDomain(results []Result) []Result {
+// partitionByScope splits the results on the ones linking
+// inside the crawling scope and the external ones.
+func partitionByScope(results []Result, entrypoint url.URL, scope Scope) ([]Result, []Result) {
+	internal := []Result{}
+	external := []Result{}
+
+	for _, res := range results {
+		if scope.Contains(entrypoint, res.Link) {
+			internal = append(internal, res)
+			continue
+		}
+		res.External = true
+		external = append(external, res)
+	}
+
+	return internal, external
+}
+
+func filterByLinkFilters(results []Result, filters []LinkFilter) []Result {
+	if len(filters) == 0 {
+		return results
+	}
+
 	filtered := []Result{}
 
 	for _, res := range results {
-		if res.Link.Host == res.Parent.Host {
+		if acceptedByAll(res, filters) {
 			filtered = append(filtered, res)
 		}
 	}
@@ -282,10 +1340,46 @@ func filterBySameDomain(results []Result) []Result {
 	return filtered
 }
 
-func extractLinks(results []Result) []url.URL {
-	links := make([]url.URL, len(results))
-	for i, res := range results {
-		links[i] = res.Link
+func acceptedByAll(res Result, filters []LinkFilter) bool {
+	for _, filter := range filters {
+		if !filter(res) {
+			return false
+		}
 	}
-	return links
+	return true
+}
+
+func filterByKinds(results []Result, kinds ...[]parser.LinkKind) []Result {
+	filtered := []Result{}
+
+	for _, res := range results {
+		if hasKind(res.Kind, kinds...) {
+			filtered = append(filtered, res)
+		}
+	}
+
+	return filtered
+}
+
+func hasKind(kind parser.LinkKind, kinds ...[]parser.LinkKind) bool {
+	for _, ks := range kinds {
+		for _, k := range ks {
+			if k == kind {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func filterNoFollow(results []Result) []Result {
+	filtered := []Result{}
+
+	for _, res := range results {
+		if !res.NoFollow {
+			filtered = append(filtered, res)
+		}
+	}
+
+	return filtered
 }
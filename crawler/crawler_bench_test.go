@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"runtime"
 	"testing"
 	"time"
 
@@ -60,3 +61,63 @@ func BenchmarkCrawler(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkVisitedSets(b *testing.B) {
+
+	const keys = 100000
+	const errorRate = 0.001
+
+	sets := []struct {
+		name   string
+		newSet func() (crawler.VisitedSet, error)
+	}{
+		{
+			name:   "Map",
+			newSet: crawler.MemoryStorage.NewVisitedSet,
+		},
+		{
+			name: "Bloom",
+			newSet: func() (crawler.VisitedSet, error) {
+				return crawler.NewBloomFilter(keys, errorRate)
+			},
+		},
+	}
+
+	for _, s := range sets {
+
+		b.Run(s.name, func(b *testing.B) {
+			b.ReportAllocs()
+			heap := int64(0)
+
+			for i := 0; i < b.N; i++ {
+				before := heapAlloc()
+
+				set, err := s.newSet()
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				// WHY: The keys are created as the crawler does,
+				//      so the memory they retain is measured too.
+				for k := 0; k < keys; k++ {
+					if _, err := set.Add(fmt.Sprintf("https://example.com/page/%d", k)); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				heap += heapAlloc() - before
+				runtime.KeepAlive(set)
+				set.Close()
+			}
+
+			b.ReportMetric(float64(heap)/float64(b.N)/keys, "heap-bytes/key")
+		})
+	}
+}
+
+func heapAlloc() int64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return int64(stats.HeapAlloc)
+}
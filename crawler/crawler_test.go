@@ -2,38 +2,27 @@ package crawler_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/katcipis/crawler/crawler"
+	"github.com/katcipis/crawler/parser"
 )
 
 func TestCrawlingMultipleLinks(t *testing.T) {
 	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
 	defer teardown()
 
-	want := []crawler.Result{
-		result(entrypoint, "", "/info.html"),
-		result(entrypoint, "", "/nesting/info.html"),
-		result(entrypoint, "", "/dir"),
-		result(entrypoint, "", "/wontExist.html"),
-		result(entrypoint, "", "/wont/exist/page.html"),
-		result(entrypoint, "", "/wont/exist2"),
-		result(entrypoint, "/info.html", "/cycle.html"),
-		result(entrypoint, "/info.html", "/final.html"),
-		result(entrypoint, "/cycle.html", "/info.html"),
-		result(entrypoint, "/cycle.html", "/final.html"),
-		result(entrypoint, "/nesting/info.html", "/cycle.html"),
-		result(entrypoint, "/nesting/info.html", "/final.html"),
-		result(entrypoint, "/dir", "/dir/page1.html"),
-		result(entrypoint, "/dir", "/dir/page2.html"),
-		result(entrypoint, "/dir", "/dir/page3.txt"),
-		result(entrypoint, "/dir/page1.html", ""),
-	}
+	want := fakesiteResults(entrypoint)
 
 	const maxConcurrency = 10
 	const wantCrawlingErrs = 3
@@ -133,6 +122,742 @@ func TestCrawlingRespectsCancellation(t *testing.T) {
 	)
 }
 
+func TestCrawlingRespectsRobots(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/robotssite")
+	defer teardown()
+
+	const concurrency = 5
+	const timeout = time.Minute
+
+	t.Run("AllowedUserAgent", func(t *testing.T) {
+		const wantSkippedURLs = 2
+
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			[]crawler.Result{
+				result(entrypoint, "", "/public.html"),
+				result(entrypoint, "", "/private/public.html"),
+			},
+			wantSkippedURLs,
+		)
+	})
+
+	t.Run("DisallowedUserAgent", func(t *testing.T) {
+		const wantSkippedURLs = 1
+
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			[]crawler.Result{},
+			wantSkippedURLs,
+			crawler.WithUserAgent("otherbot/2.0"),
+		)
+	})
+
+	t.Run("IgnoringRobots", func(t *testing.T) {
+		const wantCrawlingErrs = 0
+
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			[]crawler.Result{
+				result(entrypoint, "", "/public.html"),
+				result(entrypoint, "", "/private/public.html"),
+				result(entrypoint, "", "/private/secret.html"),
+				result(entrypoint, "", "/private"),
+				result(entrypoint, "/private/public.html", "/private/secret.html"),
+				result(entrypoint, "/private/", "/private/public.html"),
+				result(entrypoint, "/private/", "/private/secret.html"),
+				redirectResult(entrypoint, "/private", "/private/", http.StatusMovedPermanently),
+			},
+			wantCrawlingErrs,
+			crawler.WithoutRobots(),
+		)
+	})
+}
+
+func TestCrawlingParsesOnlyTheBeginningOfHugeRobots(t *testing.T) {
+	const robotsSize = 600 * 1024
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\n")
+			fmt.Fprint(w, strings.Repeat("# comment\n", robotsSize/10))
+			fmt.Fprint(w, "Disallow: /\n")
+		case "/":
+			fmt.Fprint(w, `<a href="/a"></a>`)
+		}
+	}))
+	defer teardown()
+
+	results, errs := crawler.New().Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, []crawler.Result{result(entrypoint, "", "/a")}, 0)
+}
+
+func TestCrawlingRespectsRobotsOfEachScheme(t *testing.T) {
+	const wantSkippedURLs = 1
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			if r.Header.Get(schemeHeader) == "https" {
+				fmt.Fprint(w, "User-agent: *\nDisallow: /\n")
+			}
+		case "/":
+			fmt.Fprintf(w, `<a href="https://%s/secure"></a>`, r.Host)
+		}
+	}))
+	defer teardown()
+
+	client := &http.Client{Transport: &schemeTransport{target: entrypoint}}
+	results, errs := crawler.New(crawler.WithHTTPClient(client)).Run(
+		context.Background(),
+		entrypoint,
+	)
+	checkResults(t, results, errs, []crawler.Result{}, wantSkippedURLs)
+}
+
+func TestCrawlingLinkKinds(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/assetsite")
+	defer teardown()
+
+	const concurrency = 5
+	const timeout = time.Minute
+
+	asset := func(parent string, link string) crawler.Result {
+		r := result(entrypoint, parent, link)
+		r.Kind = parser.AssetLink
+		return r
+	}
+
+	t.Run("FollowPages", func(t *testing.T) {
+		const wantCrawlingErrs = 0
+
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			[]crawler.Result{
+				result(entrypoint, "", "/next.html"),
+				result(entrypoint, "", "/frame.html"),
+			},
+			wantCrawlingErrs,
+		)
+	})
+
+	t.Run("FollowPagesReportAssets", func(t *testing.T) {
+		const wantCrawlingErrs = 0
+
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			[]crawler.Result{
+				result(entrypoint, "", "/next.html"),
+				result(entrypoint, "", "/frame.html"),
+				asset("", "/style.css"),
+				asset("", "/script.js"),
+				asset("", "/image.png"),
+				asset("/next.html", "/image.png"),
+				asset("/next.html", "/other.png"),
+			},
+			wantCrawlingErrs,
+			crawler.WithReportedLinkKinds(parser.AssetLink),
+		)
+	})
+
+	t.Run("FollowAssets", func(t *testing.T) {
+		const wantCrawlingErrs = 0
+
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			[]crawler.Result{
+				asset("", "/style.css"),
+				asset("", "/script.js"),
+				asset("", "/image.png"),
+			},
+			wantCrawlingErrs,
+			crawler.WithFollowedLinkKinds(parser.AssetLink),
+		)
+	})
+}
+
+func TestCrawlingResolvesLinksAgainstBase(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/basesite")
+	defer teardown()
+
+	const concurrency = 5
+	const wantCrawlingErrs = 0
+	const timeout = time.Minute
+
+	withQuery := func(r crawler.Result, query string) crawler.Result {
+		r.Link.RawQuery = query
+		return r
+	}
+	withParentQuery := func(r crawler.Result, query string) crawler.Result {
+		r.Parent.RawQuery = query
+		return r
+	}
+
+	testCrawler(
+		t,
+		context.Background(),
+		entrypoint,
+		concurrency,
+		timeout,
+		[]crawler.Result{
+			result(entrypoint, "", "/docs/intro.html"),
+			result(entrypoint, "", "/about.html"),
+			withQuery(result(entrypoint, "/docs/intro.html", "/docs/intro.html"), "page=2"),
+			withParentQuery(result(entrypoint, "/docs/intro.html", "/docs/intro.html"), "page=2"),
+		},
+		wantCrawlingErrs,
+	)
+}
+
+func TestCrawlingRespectsNoFollow(t *testing.T) {
+	entrypoint, teardown := setupNoFollowServer(t)
+	defer teardown()
+
+	const concurrency = 5
+	const wantCrawlingErrs = 0
+	const timeout = time.Minute
+
+	nofollow := func(r crawler.Result) crawler.Result {
+		r.NoFollow = true
+		return r
+	}
+
+	want := []crawler.Result{
+		result(entrypoint, "", "/followed.html"),
+		nofollow(result(entrypoint, "", "/nofollowed.html")),
+		result(entrypoint, "", "/noindex.html"),
+		result(entrypoint, "", "/metanofollow.html"),
+		result(entrypoint, "/followed.html", "/final.html"),
+		result(entrypoint, "/noindex.html", "/final.html"),
+		nofollow(result(entrypoint, "/metanofollow.html", "/hidden2.html")),
+	}
+
+	t.Run("Respecting", func(t *testing.T) {
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			copyResults(want),
+			wantCrawlingErrs,
+		)
+	})
+
+	t.Run("IgnoringRobots", func(t *testing.T) {
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			append(
+				copyResults(want),
+				result(entrypoint, "/nofollowed.html", "/hidden.html"),
+			),
+			wantCrawlingErrs,
+			crawler.WithoutRobots(),
+		)
+	})
+}
+
+func TestCrawlingPageResults(t *testing.T) {
+	entrypoint, teardown := setupNoFollowServer(t)
+	defer teardown()
+
+	const concurrency = 5
+	const timeout = time.Minute
+
+	results, errs := crawler.Start(
+		context.Background(),
+		entrypoint,
+		concurrency,
+		timeout,
+		crawler.WithPageResults(),
+	)
+
+	go func() {
+		for err := range errs {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}()
+
+	const html = "text/html; charset=utf-8"
+
+	size := func(file string) int64 {
+		info, err := os.Stat("./testdata/nofollowsite/" + file)
+		fatalerr(t, err, "getting file size")
+		return info.Size()
+	}
+
+	page := func(depth uint, file string, words int) crawler.Page {
+		return crawler.Page{
+			Depth:       depth,
+			StatusCode:  200,
+			ContentType: html,
+			Size:        size(file),
+			H1s:         []string{},
+			WordCount:   words,
+		}
+	}
+	noindex := func(p crawler.Page) crawler.Page {
+		p.NoIndex = true
+		return p
+	}
+	nofollow := func(p crawler.Page) crawler.Page {
+		p.NoFollow = true
+		return p
+	}
+
+	wantPages := map[string]crawler.Page{
+		"":                   page(0, "index.html", 11),
+		"/followed.html":     page(1, "followed.html", 2),
+		"/noindex.html":      noindex(page(1, "noindex.html", 3)),
+		"/metanofollow.html": nofollow(page(1, "metanofollow.html", 4)),
+		"/final.html":        noindex(page(2, "final.html", 5)),
+	}
+	linksFromPage := map[string]bool{}
+
+	for res := range results {
+		if res.Page == nil {
+			if !linksFromPage[res.Parent.Path] {
+				t.Errorf("got link[%s] before its parent page result", res)
+			}
+			continue
+		}
+
+		want, ok := wantPages[res.Link.Path]
+		if !ok {
+			t.Errorf("unexpected page result[%s]", res)
+			continue
+		}
+		delete(wantPages, res.Link.Path)
+		linksFromPage[res.Link.Path] = true
+
+		// WHY: The file server sends the files modification time
+		//      on the Last-Modified header, which is not fixed,
+		//      and latency depends on the machine running the tests.
+		got := *res.Page
+		if got.LastModified.IsZero() {
+			t.Errorf("page[%s]: missing last modified time", res.Link.String())
+		}
+		if got.Latency <= 0 {
+			t.Errorf("page[%s]: missing latency", res.Link.String())
+		}
+		got.LastModified = time.Time{}
+		got.Latency = 0
+
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("page[%s]: want[%+v] != got[%+v]", res.Link.String(), want, got)
+		}
+	}
+
+	if len(wantPages) > 0 {
+		t.Errorf("missing page results: %+v", wantPages)
+	}
+}
+
+func TestCrawlingPageResultsOfFailedPages(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	const concurrency = 5
+	const timeout = time.Minute
+
+	results, errs := crawler.Start(
+		context.Background(),
+		entrypoint,
+		concurrency,
+		timeout,
+		crawler.WithPageResults(),
+	)
+
+	go func() {
+		for range errs {
+		}
+	}()
+
+	failed := map[string]bool{}
+
+	for res := range results {
+		if res.Page == nil || res.Page.Err == nil {
+			continue
+		}
+		failed[res.Link.Path] = true
+
+		if res.Page.StatusCode != http.StatusNotFound {
+			t.Errorf("page[%s]: want status 404, got %d", res.Link.String(), res.Page.StatusCode)
+		}
+		if res.Page.Depth != 1 {
+			t.Errorf("page[%s]: want depth 1, got %d", res.Link.String(), res.Page.Depth)
+		}
+	}
+
+	for _, path := range []string{"/wontExist.html", "/wont/exist/page.html", "/wont/exist2"} {
+		if !failed[path] {
+			t.Errorf("missing failed page result for [%s]", path)
+		}
+	}
+}
+
+func TestCrawlingPageMetadata(t *testing.T) {
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			return
+		}
+		fmt.Fprint(w, `
+			<html lang="en">
+			<head>
+				<base href="/docs/">
+				<title>Crawler docs</title>
+				<meta name="description" content="How to crawl">
+				<link rel="alternate" hreflang="pt" href="pt/">
+			</head>
+			<body><h1>Docs</h1><p>Crawl it all</p></body>
+			</html>
+		`)
+	}))
+	defer teardown()
+
+	results, errs := crawler.New(
+		crawler.WithoutRobots(),
+		crawler.WithPageResults(),
+	).Run(context.Background(), entrypoint)
+
+	var got *crawler.Page
+	for _, res := range collectResults(results, errs) {
+		if res.Page != nil && res.Link == entrypoint {
+			got = res.Page
+		}
+	}
+	if got == nil {
+		t.Fatal("missing entrypoint page result")
+	}
+
+	want := crawler.Page{
+		Title:       "Crawler docs",
+		Description: "How to crawl",
+		H1s:         []string{"Docs"},
+		Lang:        "en",
+		Alternates: []parser.Alternate{{
+			Lang: "pt",
+			URL:  url.URL{Scheme: entrypoint.Scheme, Host: entrypoint.Host, Path: "/docs/pt/"},
+		}},
+		WordCount: 4,
+	}
+	metadata := crawler.Page{
+		Title:       got.Title,
+		Description: got.Description,
+		H1s:         got.H1s,
+		Lang:        got.Lang,
+		Alternates:  got.Alternates,
+		WordCount:   got.WordCount,
+	}
+	if !reflect.DeepEqual(want, metadata) {
+		t.Fatalf("want metadata[%+v] != got[%+v]", want, metadata)
+	}
+}
+
+func TestCrawlingFetchErrors(t *testing.T) {
+	type tcase struct {
+		name  string
+		setup func(t *testing.T) (url.URL, func())
+		want  func(entrypoint url.URL) []crawler.FetchError
+	}
+
+	cases := []tcase{
+		{
+			name: "NotFoundPages",
+			setup: func(t *testing.T) (url.URL, func()) {
+				return setupFileServer(t, "./testdata/fakesite")
+			},
+			want: func(entrypoint url.URL) []crawler.FetchError {
+				notFound := func(path string) crawler.FetchError {
+					return crawler.FetchError{
+						URL:        result(entrypoint, "", path).Link,
+						Parent:     entrypoint,
+						Method:     http.MethodGet,
+						Phase:      crawler.StatusPhase,
+						StatusCode: http.StatusNotFound,
+					}
+				}
+				return []crawler.FetchError{
+					notFound("/wontExist.html"),
+					notFound("/wont/exist/page.html"),
+					notFound("/wont/exist2"),
+				}
+			},
+		},
+		{
+			name: "UnreachableRobots",
+			setup: func(t *testing.T) (url.URL, func()) {
+				return newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+				}))
+			},
+			want: func(entrypoint url.URL) []crawler.FetchError {
+				return []crawler.FetchError{{
+					URL:        result(entrypoint, "", "/robots.txt").Link,
+					Method:     http.MethodGet,
+					Phase:      crawler.StatusPhase,
+					StatusCode: http.StatusInternalServerError,
+				}}
+			},
+		},
+		{
+			name: "ThrottledRobots",
+			setup: func(t *testing.T) (url.URL, func()) {
+				return newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusTooManyRequests)
+				}))
+			},
+			want: func(entrypoint url.URL) []crawler.FetchError {
+				return []crawler.FetchError{{
+					URL:        result(entrypoint, "", "/robots.txt").Link,
+					Method:     http.MethodGet,
+					Phase:      crawler.StatusPhase,
+					StatusCode: http.StatusTooManyRequests,
+				}}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entrypoint, teardown := c.setup(t)
+			defer teardown()
+
+			results, errs := crawler.New().Run(context.Background(), entrypoint)
+			go func() {
+				for range results {
+				}
+			}()
+
+			want := map[string]crawler.FetchError{}
+			for _, fetchErr := range c.want(entrypoint) {
+				want[fetchErr.URL.String()] = fetchErr
+			}
+
+			for err := range errs {
+				var got *crawler.FetchError
+				if !errors.As(err, &got) {
+					t.Errorf("unexpected error[%s]", err)
+					continue
+				}
+
+				w, ok := want[got.URL.String()]
+				if !ok {
+					t.Errorf("unexpected fetch error[%s]", err)
+					continue
+				}
+				delete(want, got.URL.String())
+
+				if w.Parent != got.Parent || w.Method != got.Method ||
+					w.Phase != got.Phase || w.StatusCode != got.StatusCode {
+					t.Errorf("want fetch error[%+v] != got[%+v]", w, *got)
+				}
+			}
+
+			if len(want) > 0 {
+				t.Errorf("missing fetch errors: %+v", want)
+			}
+		})
+	}
+}
+
+func TestCrawlingNormalizesURLs(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/canonicalsite")
+	defer teardown()
+
+	const concurrency = 5
+	const wantCrawlingErrs = 0
+	const timeout = time.Minute
+
+	page := result(entrypoint, "", "/page.html")
+	page.Link.RawQuery = "a=2&b=1"
+
+	t.Run("Normalizing", func(t *testing.T) {
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			[]crawler.Result{
+				page,
+				result(entrypoint, "", "/duplicate.html"),
+				result(entrypoint, "/duplicate.html", "/final.html"),
+			},
+			wantCrawlingErrs,
+		)
+	})
+
+	t.Run("HonoringCanonical", func(t *testing.T) {
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			[]crawler.Result{
+				page,
+				result(entrypoint, "", "/duplicate.html"),
+				result(entrypoint, "/original.html", "/final.html"),
+			},
+			wantCrawlingErrs,
+			crawler.WithCanonical(),
+		)
+	})
+
+	t.Run("NotNormalizing", func(t *testing.T) {
+		tracked := result(entrypoint, "", "/page.html")
+		tracked.Link.RawQuery = "a=2&utm_source=test&b=1"
+		unsorted := result(entrypoint, "", "/page.html")
+		unsorted.Link.RawQuery = "b=1&a=2"
+
+		testCrawler(
+			t,
+			context.Background(),
+			entrypoint,
+			concurrency,
+			timeout,
+			[]crawler.Result{
+				unsorted,
+				page,
+				tracked,
+				result(entrypoint, "", "/duplicate.html"),
+				result(entrypoint, "/duplicate.html", "/final.html"),
+			},
+			wantCrawlingErrs,
+			crawler.WithNormalizer(nil),
+		)
+	})
+}
+
+func TestCrawlingMultipleSeeds(t *testing.T) {
+	first, teardownFirst := setupFileServer(t, "./testdata/fakesite")
+	defer teardownFirst()
+
+	second, teardownSecond := setupFileServer(t, "./testdata/fakesite")
+	defer teardownSecond()
+
+	// WHY: Each seed is crawled on its own, so each one has
+	//      its own results and its own 404 errors.
+	const wantCrawlingErrs = 6
+
+	want := []crawler.Result{}
+	for _, seed := range []url.URL{first, second} {
+		for _, res := range fakesiteResults(seed) {
+			res.Seed = seed
+			want = append(want, res)
+		}
+	}
+
+	results, errs := crawler.StartSeeds(context.Background(), []url.URL{first, second}, 2, time.Minute)
+	checkResults(t, results, errs, want, wantCrawlingErrs)
+}
+
+func TestCrawlingMultipleSeedsSharesCrawlers(t *testing.T) {
+	var mutex sync.Mutex
+	requests := 0
+	maxRequests := 0
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		if requests > maxRequests {
+			maxRequests = requests
+		}
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/a"></a><a href="/b"></a>`)
+		}
+
+		mutex.Lock()
+		requests--
+		mutex.Unlock()
+	})
+
+	seeds := []url.URL{}
+	for i := 0; i < 3; i++ {
+		seed, teardown := newServer(t, handler)
+		defer teardown()
+		seeds = append(seeds, seed)
+	}
+
+	results, errs := crawler.New(
+		crawler.WithConcurrency(1),
+		crawler.WithoutRobots(),
+	).RunSeeds(context.Background(), seeds)
+
+	got := collectResults(results, errs)
+	if len(got) != 2*len(seeds) {
+		t.Fatalf("want %d results, got %d: %v", 2*len(seeds), len(got), got)
+	}
+	if maxRequests != 1 {
+		t.Fatalf("want one request at a time for all seeds, got %d", maxRequests)
+	}
+}
+
+func TestCrawlerFailsToRunSeeds(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/emptysite")
+	defer teardown()
+
+	type tcase struct {
+		name  string
+		seeds []url.URL
+		opts  []crawler.Option
+	}
+
+	cases := []tcase{
+		{
+			name:  "WithoutSeeds",
+			seeds: []url.URL{},
+		},
+		{
+			name:  "WithCheckpointsOnManySeeds",
+			seeds: []url.URL{entrypoint, entrypoint},
+			opts:  []crawler.Option{crawler.WithCheckpoints(t.TempDir()+"/checkpoint.json", 0)},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			const wantErrs = 1
+
+			results, errs := crawler.New(c.opts...).RunSeeds(context.Background(), c.seeds)
+			checkResults(t, results, errs, []crawler.Result{}, wantErrs)
+		})
+	}
+}
+
 func TestCrawlerFailsToStartIfConcurrencyIsZero(t *testing.T) {
 	entrypoint, teardown := setupFileServer(t, "./testdata/emptysite")
 	defer teardown()
@@ -157,6 +882,28 @@ func TestCrawlerFailsToStartIfConcurrencyIsZero(t *testing.T) {
 
 }
 
+func fakesiteResults(entrypoint url.URL) []crawler.Result {
+	return []crawler.Result{
+		result(entrypoint, "", "/info.html"),
+		result(entrypoint, "", "/nesting/info.html"),
+		result(entrypoint, "", "/dir"),
+		result(entrypoint, "", "/wontExist.html"),
+		result(entrypoint, "", "/wont/exist/page.html"),
+		result(entrypoint, "", "/wont/exist2"),
+		result(entrypoint, "/info.html", "/cycle.html"),
+		result(entrypoint, "/info.html", "/final.html"),
+		result(entrypoint, "/cycle.html", "/info.html"),
+		result(entrypoint, "/cycle.html", "/final.html"),
+		result(entrypoint, "/nesting/info.html", "/cycle.html"),
+		result(entrypoint, "/nesting/info.html", "/final.html"),
+		result(entrypoint, "/dir/", "/dir/page1.html"),
+		result(entrypoint, "/dir/", "/dir/page2.html"),
+		result(entrypoint, "/dir/", "/dir/page3.txt"),
+		redirectResult(entrypoint, "/dir", "/dir/", http.StatusMovedPermanently),
+		result(entrypoint, "/dir/page1.html", ""),
+	}
+}
+
 func testCrawler(
 	t *testing.T,
 	ctx context.Context,
@@ -165,10 +912,22 @@ func testCrawler(
 	timeout time.Duration,
 	want []crawler.Result,
 	wantErrs uint,
+	opts ...crawler.Option,
 ) {
 	t.Helper()
 
-	results, errs := crawler.Start(ctx, entrypoint, concurrency, timeout)
+	results, errs := crawler.Start(ctx, entrypoint, concurrency, timeout, opts...)
+	checkResults(t, results, errs, want, wantErrs)
+}
+
+func checkResults(
+	t *testing.T,
+	results <-chan crawler.Result,
+	errs <-chan error,
+	want []crawler.Result,
+	wantErrs uint,
+) {
+	t.Helper()
 
 	drainedErrs := make(chan struct{})
 	errsCount := uint(0)
@@ -229,9 +988,17 @@ func result(entrypoint url.URL, parent string, link string) crawler.Result {
 			Host:   entrypoint.Host,
 			Path:   link,
 		},
+		Kind: parser.PageLink,
 	}
 }
 
+func redirectResult(entrypoint url.URL, from string, to string, status int) crawler.Result {
+	r := result(entrypoint, from, to)
+	r.Kind = parser.RedirectLink
+	r.RedirectStatus = status
+	return r
+}
+
 func newServer(t *testing.T, h http.Handler) (url.URL, func()) {
 	server := httptest.NewServer(h)
 	url, err := url.Parse(server.URL)
@@ -254,6 +1021,20 @@ func setupHangingServer(t *testing.T, hangtime time.Duration) (url.URL, func())
 	}
 }
 
+// setupNoFollowServer serves the nofollowsite and adds a
+// X-Robots-Tag header with noindex on /final.html.
+func setupNoFollowServer(t *testing.T) (url.URL, func()) {
+	files := http.FileServer(http.Dir("./testdata/nofollowsite"))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/final.html" {
+			w.Header().Add("X-Robots-Tag", "otherbot: nofollow")
+			w.Header().Add("X-Robots-Tag", "katcipis-crawler: noindex")
+		}
+		files.ServeHTTP(w, r)
+	})
+	return newServer(t, handler)
+}
+
 func setupFileServer(t *testing.T, dir string) (url.URL, func()) {
 	handler := http.FileServer(http.Dir(dir))
 	return newServer(t, handler)
@@ -266,3 +1047,25 @@ func fatalerr(t *testing.T, err error, op string) {
 		t.Fatalf("error[%s] while %s", err, op)
 	}
 }
+
+// schemeHeader has the scheme of the requests sent by schemeTransport
+const schemeHeader = "X-Test-Scheme"
+
+// schemeTransport sends the requests of any scheme to the target,
+// so a plain HTTP test server can act as both http and https.
+type schemeTransport struct {
+	target url.URL
+}
+
+func (t *schemeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sent := req.Clone(req.Context())
+	sent.Header.Set(schemeHeader, req.URL.Scheme)
+	sent.URL.Scheme = t.target.Scheme
+	sent.URL.Host = t.target.Host
+
+	res, err := http.DefaultTransport.RoundTrip(sent)
+	if err == nil {
+		res.Request = req
+	}
+	return res, err
+}
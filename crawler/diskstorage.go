@@ -0,0 +1,492 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DefaultDiskMemoryLimit is the maximum amount of jobs or keys kept
+// in memory by each frontier and visited set of a DiskStorage when
+// no memory limit is provided.
+const DefaultDiskMemoryLimit = 100000
+
+// DiskStorage keeps frontiers and visited sets on disk, so the memory
+// used does not grow with the amount of URLs found. Each frontier and
+// visited set has its own temporary directory inside Dir, which is
+// removed when they are closed.
+//
+// Frontiers keep up to MemoryLimit jobs in memory on each end of the
+// queue, the jobs in between are written on segment files.
+//
+// Visited sets keep up to MemoryLimit keys in memory, older keys are
+// written on segment files as sorted 128 bits hashes, which are merged
+// as they pile up. Only a small index of each segment is kept in memory,
+// so checking a key reads a single block of each segment. All keys are
+// also written on a log, which is only read by Range.
+type DiskStorage struct {
+	// Dir is the directory where the data is stored, the
+	// default directory for temporary files is used if empty.
+	Dir string
+	// MemoryLimit is the maximum amount of jobs or keys kept in memory,
+	// DefaultDiskMemoryLimit is used if zero.
+	MemoryLimit int
+}
+
+const (
+	// hashesPerBlock is the amount of hashes of a visited set segment
+	// on each block that is read when checking if a key is on it.
+	hashesPerBlock = 256
+	// maxHashSegments is the amount of visited set segments
+	// that triggers merging all segments on a single one.
+	maxHashSegments = 8
+)
+
+// NewFrontier creates a new empty frontier on disk
+func (s DiskStorage) NewFrontier() (Frontier, error) {
+	dir, err := os.MkdirTemp(s.Dir, "crawler-frontier")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create frontier dir: %s", err)
+	}
+	return &diskFrontier{dir: dir, limit: s.memoryLimit()}, nil
+}
+
+// NewVisitedSet creates a new empty visited set on disk
+func (s DiskStorage) NewVisitedSet() (VisitedSet, error) {
+	dir, err := os.MkdirTemp(s.Dir, "crawler-visited")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create visited set dir: %s", err)
+	}
+
+	keys, err := os.Create(filepath.Join(dir, "keys"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("unable to create visited set keys log: %s", err)
+	}
+
+	return &diskVisitedSet{
+		dir:   dir,
+		limit: s.memoryLimit(),
+		mem:   map[hashKey]bool{},
+		keys:  keys,
+		log:   bufio.NewWriter(keys),
+	}, nil
+}
+
+func (s DiskStorage) memoryLimit() int {
+	if s.MemoryLimit <= 0 {
+		return DefaultDiskMemoryLimit
+	}
+	return s.MemoryLimit
+}
+
+// diskFrontier is a queue where the jobs are popped from head and
+// pushed on tail. When tail is full it is written on a segment file,
+// segments are read, in order, when head is empty.
+type diskFrontier struct {
+	dir      string
+	limit    int
+	head     []FrontierJob
+	tail     []FrontierJob
+	segments []string
+	written  int
+	len      int
+}
+
+func (f *diskFrontier) Push(j FrontierJob) error {
+	f.tail = append(f.tail, j)
+	f.len++
+
+	if len(f.tail) < f.limit {
+		return nil
+	}
+
+	name := filepath.Join(f.dir, fmt.Sprintf("segment-%d", f.written))
+	if err := writeFrontierSegment(name, f.tail); err != nil {
+		return err
+	}
+	f.written++
+	f.segments = append(f.segments, name)
+	f.tail = nil
+	return nil
+}
+
+func (f *diskFrontier) Pop() (FrontierJob, bool, error) {
+	if len(f.head) == 0 {
+		if len(f.segments) > 0 {
+			jobs, err := readFrontierSegment(f.segments[0])
+			if err != nil {
+				return FrontierJob{}, false, err
+			}
+			os.Remove(f.segments[0])
+			f.segments = f.segments[1:]
+			f.head = jobs
+		} else {
+			f.head, f.tail = f.tail, nil
+		}
+	}
+
+	if len(f.head) == 0 {
+		return FrontierJob{}, false, nil
+	}
+
+	j := f.head[0]
+	f.head = f.head[1:]
+	f.len--
+	return j, true, nil
+}
+
+func (f *diskFrontier) Len() int {
+	return f.len
+}
+
+func (f *diskFrontier) Range(fn func(FrontierJob) error) error {
+	jobs := [][]FrontierJob{f.head}
+	for _, name := range f.segments {
+		segment, err := readFrontierSegment(name)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, segment)
+	}
+	jobs = append(jobs, f.tail)
+
+	for _, segment := range jobs {
+		for _, j := range segment {
+			if err := fn(j); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *diskFrontier) Close() error {
+	f.head = nil
+	f.tail = nil
+	f.segments = nil
+	f.len = 0
+	if err := os.RemoveAll(f.dir); err != nil {
+		return fmt.Errorf("unable to remove frontier dir[%s]: %s", f.dir, err)
+	}
+	return nil
+}
+
+func writeFrontierSegment(name string, jobs []FrontierJob) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("unable to create frontier segment[%s]: %s", name, err)
+	}
+
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for _, j := range jobs {
+		if err = encoder.Encode(j); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+		return fmt.Errorf("unable to write frontier segment[%s]: %s", name, err)
+	}
+	return nil
+}
+
+func readFrontierSegment(name string) ([]FrontierJob, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open frontier segment[%s]: %s", name, err)
+	}
+	defer file.Close()
+
+	jobs := []FrontierJob{}
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var j FrontierJob
+		err := decoder.Decode(&j)
+		if err == io.EOF {
+			return jobs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read frontier segment[%s]: %s", name, err)
+		}
+		jobs = append(jobs, j)
+	}
+}
+
+type hashKey [16]byte
+
+func hashOf(key string) hashKey {
+	var h hashKey
+	hash := fnv.New128a()
+	hash.Write([]byte(key))
+	copy(h[:], hash.Sum(nil))
+	return h
+}
+
+func (h hashKey) less(other hashKey) bool {
+	return bytes.Compare(h[:], other[:]) < 0
+}
+
+// diskVisitedSet keeps the hashes of the most recent keys on mem
+// and the older ones on sorted segments. Since a key is only added
+// when it is on no segment, each hash is on a single segment.
+type diskVisitedSet struct {
+	dir      string
+	limit    int
+	mem      map[hashKey]bool
+	segments []*hashSegment
+	written  int
+	keys     *os.File
+	log      *bufio.Writer
+}
+
+// hashSegment is a file with sorted hashes, index has the
+// first hash of each block of hashesPerBlock hashes.
+type hashSegment struct {
+	file  *os.File
+	size  int
+	index []hashKey
+}
+
+func (s *diskVisitedSet) Add(key string) (bool, error) {
+	h := hashOf(key)
+	found, err := s.contains(h)
+	if err != nil || found {
+		return false, err
+	}
+
+	// WHY: Keys are normalized URLs or results made of them,
+	//      which never have new lines since they are escaped.
+	if _, err := s.log.WriteString(key + "\n"); err != nil {
+		return false, fmt.Errorf("unable to write visited set keys log: %s", err)
+	}
+
+	s.mem[h] = true
+	if len(s.mem) >= s.limit {
+		if err := s.flush(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (s *diskVisitedSet) Contains(key string) (bool, error) {
+	return s.contains(hashOf(key))
+}
+
+func (s *diskVisitedSet) Range(fn func(key string) error) error {
+	if err := s.log.Flush(); err != nil {
+		return fmt.Errorf("unable to write visited set keys log: %s", err)
+	}
+
+	keys, err := os.Open(s.keys.Name())
+	if err != nil {
+		return fmt.Errorf("unable to open visited set keys log: %s", err)
+	}
+	defer keys.Close()
+
+	r := bufio.NewReader(keys)
+	for {
+		key, err := r.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read visited set keys log: %s", err)
+		}
+		if err := fn(key[:len(key)-1]); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *diskVisitedSet) Close() error {
+	s.keys.Close()
+	for _, segment := range s.segments {
+		segment.file.Close()
+	}
+	s.mem = nil
+	s.segments = nil
+	if err := os.RemoveAll(s.dir); err != nil {
+		return fmt.Errorf("unable to remove visited set dir[%s]: %s", s.dir, err)
+	}
+	return nil
+}
+
+func (s *diskVisitedSet) contains(h hashKey) (bool, error) {
+	if s.mem[h] {
+		return true, nil
+	}
+	for _, segment := range s.segments {
+		found, err := segment.contains(h)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// flush writes the hashes in memory on a new segment, merging
+// all segments when there are more than maxHashSegments.
+func (s *diskVisitedSet) flush() error {
+	hashes := make([]hashKey, 0, len(s.mem))
+	for h := range s.mem {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].less(hashes[j])
+	})
+
+	segment, err := s.writeSegment(func(emit func(hashKey) error) error {
+		for _, h := range hashes {
+			if err := emit(h); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mem = map[hashKey]bool{}
+	s.segments = append(s.segments, segment)
+
+	if len(s.segments) <= maxHashSegments {
+		return nil
+	}
+	return s.merge()
+}
+
+// merge merges all segments on a single one
+func (s *diskVisitedSet) merge() error {
+	merged, err := s.writeSegment(func(emit func(hashKey) error) error {
+		readers := make([]*bufio.Reader, len(s.segments))
+		heads := make([]*hashKey, len(s.segments))
+
+		next := func(i int) error {
+			var h hashKey
+			_, err := io.ReadFull(readers[i], h[:])
+			if err == io.EOF {
+				heads[i] = nil
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("unable to read visited set segment[%s]: %s",
+					s.segments[i].file.Name(), err)
+			}
+			heads[i] = &h
+			return nil
+		}
+
+		for i, segment := range s.segments {
+			readers[i] = bufio.NewReader(io.NewSectionReader(
+				segment.file, 0, int64(segment.size*len(hashKey{}))))
+			if err := next(i); err != nil {
+				return err
+			}
+		}
+
+		for {
+			min := -1
+			for i, h := range heads {
+				if h != nil && (min < 0 || h.less(*heads[min])) {
+					min = i
+				}
+			}
+			if min < 0 {
+				return nil
+			}
+			if err := emit(*heads[min]); err != nil {
+				return err
+			}
+			if err := next(min); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range s.segments {
+		segment.file.Close()
+		os.Remove(segment.file.Name())
+	}
+	s.segments = []*hashSegment{merged}
+	return nil
+}
+
+// writeSegment creates a new segment with the sorted hashes
+// emitted by the given function.
+func (s *diskVisitedSet) writeSegment(
+	hashes func(emit func(hashKey) error) error,
+) (*hashSegment, error) {
+	name := filepath.Join(s.dir, fmt.Sprintf("segment-%d", s.written))
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create visited set segment[%s]: %s", name, err)
+	}
+
+	segment := &hashSegment{file: file}
+	w := bufio.NewWriter(file)
+
+	err = hashes(func(h hashKey) error {
+		if segment.size%hashesPerBlock == 0 {
+			segment.index = append(segment.index, h)
+		}
+		segment.size++
+		_, err := w.Write(h[:])
+		return err
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(name)
+		return nil, fmt.Errorf("unable to write visited set segment[%s]: %s", name, err)
+	}
+
+	s.written++
+	return segment, nil
+}
+
+func (s *hashSegment) contains(h hashKey) (bool, error) {
+	block := sort.Search(len(s.index), func(i int) bool {
+		return h.less(s.index[i])
+	}) - 1
+	if block < 0 {
+		return false, nil
+	}
+
+	hashSize := len(hashKey{})
+	first := block * hashesPerBlock
+	count := s.size - first
+	if count > hashesPerBlock {
+		count = hashesPerBlock
+	}
+
+	data := make([]byte, count*hashSize)
+	if _, err := s.file.ReadAt(data, int64(first*hashSize)); err != nil {
+		return false, fmt.Errorf("unable to read visited set segment[%s]: %s", s.file.Name(), err)
+	}
+
+	i := sort.Search(count, func(i int) bool {
+		return bytes.Compare(data[i*hashSize:(i+1)*hashSize], h[:]) >= 0
+	})
+	return i < count && bytes.Equal(data[i*hashSize:(i+1)*hashSize], h[:]), nil
+}
@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// FetchPhase is the phase of fetching an URL where a failure happened
+type FetchPhase string
+
+const (
+	// RequestPhase is when the request is being built
+	RequestPhase FetchPhase = "request"
+	// TransportPhase is when the request is sent and the response
+	// is received, failures are network errors, timeouts, etc.
+	TransportPhase FetchPhase = "transport"
+	// StatusPhase is when the response status code is checked
+	StatusPhase FetchPhase = "status"
+	// ParsePhase is when the response body is parsed
+	ParsePhase FetchPhase = "parse"
+	// RedirectPhase is when redirects are followed, failures are
+	// redirect loops and too long redirect chains.
+	RedirectPhase FetchPhase = "redirect"
+)
+
+// FetchError is sent on the errors channel for each URL that
+// could not be fetched. The Parent is empty when the URL is the
+// entrypoint or its robots.txt.
+//
+// It can be retrieved from wrapping errors using errors.As.
+type FetchError struct {
+	// URL is the URL that failed to be fetched
+	URL url.URL
+	// Parent is the URL where the failed URL was found
+	Parent url.URL
+	// Method is the method of the request, GET if empty
+	Method string
+	// Phase is the phase of the fetch that failed
+	Phase FetchPhase
+	// StatusCode is the status code of the response,
+	// zero if no response was received.
+	StatusCode int
+	// Err is the cause of the failure, it may be nil
+	// on the StatusPhase when the status code is the cause.
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	var msg string
+
+	method := e.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	switch e.Phase {
+	case RequestPhase:
+		msg = fmt.Sprintf("unable to create %s request for url[%s]", method, e.URL.String())
+	case TransportPhase:
+		msg = fmt.Sprintf("unable to %s url[%s]", method, e.URL.String())
+	case StatusPhase:
+		msg = fmt.Sprintf("error status code[%d] on %s url[%s]", e.StatusCode, method, e.URL.String())
+	case ParsePhase:
+		msg = fmt.Sprintf("error parsing response body from %s url[%s]", method, e.URL.String())
+	case RedirectPhase:
+		msg = fmt.Sprintf("unable to follow redirects of %s url[%s]", method, e.URL.String())
+	default:
+		msg = fmt.Sprintf("unable to fetch url[%s] on phase[%s]", e.URL.String(), e.Phase)
+	}
+
+	if e.Parent.String() != "" {
+		msg += fmt.Sprintf(" found on url[%s]", e.Parent.String())
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the cause of the failure
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
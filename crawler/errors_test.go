@@ -0,0 +1,88 @@
+package crawler_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestFetchError(t *testing.T) {
+	type tcase struct {
+		name string
+		err  crawler.FetchError
+		want string
+	}
+
+	link := url.URL{Scheme: "http", Host: "test", Path: "/link"}
+	parent := url.URL{Scheme: "http", Host: "test"}
+	cause := errors.New("cause")
+
+	cases := []tcase{
+		{
+			name: "Request",
+			err:  crawler.FetchError{URL: link, Phase: crawler.RequestPhase, Err: cause},
+			want: "unable to create GET request for url[http://test/link]: cause",
+		},
+		{
+			name: "Transport",
+			err:  crawler.FetchError{URL: link, Phase: crawler.TransportPhase, Err: cause},
+			want: "unable to GET url[http://test/link]: cause",
+		},
+		{
+			name: "Status",
+			err:  crawler.FetchError{URL: link, Phase: crawler.StatusPhase, StatusCode: 404},
+			want: "error status code[404] on GET url[http://test/link]",
+		},
+		{
+			name: "Parse",
+			err:  crawler.FetchError{URL: link, Phase: crawler.ParsePhase, StatusCode: 200, Err: cause},
+			want: "error parsing response body from GET url[http://test/link]: cause",
+		},
+		{
+			name: "Method",
+			err: crawler.FetchError{
+				URL:        link,
+				Method:     http.MethodHead,
+				Phase:      crawler.StatusPhase,
+				StatusCode: 429,
+			},
+			want: "error status code[429] on HEAD url[http://test/link]",
+		},
+		{
+			name: "WithParent",
+			err: crawler.FetchError{
+				URL:        link,
+				Parent:     parent,
+				Phase:      crawler.StatusPhase,
+				StatusCode: 500,
+			},
+			want: "error status code[500] on GET url[http://test/link] found on url[http://test]",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fetchErr := c.err
+			if got := fetchErr.Error(); got != c.want {
+				t.Fatalf("want[%s] != got[%s]", c.want, got)
+			}
+
+			wrapped := fmt.Errorf("wrapped: %w", &fetchErr)
+
+			var got *crawler.FetchError
+			if !errors.As(wrapped, &got) {
+				t.Fatalf("unable to get FetchError from [%s]", wrapped)
+			}
+			if got != &fetchErr {
+				t.Fatalf("want[%p] != got[%p]", &fetchErr, got)
+			}
+			if errors.Unwrap(got) != c.err.Err {
+				t.Fatalf("want cause[%v] != got[%v]", c.err.Err, errors.Unwrap(got))
+			}
+		})
+	}
+}
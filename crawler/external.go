@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// maxCheckBodySize is how much of a body is read when checking
+// a link with GET, reading it allows the connection to be reused.
+const maxCheckBodySize = 64 * 1024
+
+// checkable returns true if the given external link can be checked,
+// only HTTP links can, links like mailto: and tel: can not.
+func checkable(u url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// checker will write one crawlResult, without any results, for each
+// job it reads from the jobs channel, just like the crawler does.
+// Each job URL is checked, not crawled, so no link is extracted from it.
+func checker(
+	ctx context.Context,
+	cfg Config,
+	client *http.Client,
+	jobs <-chan job,
+	errs chan<- error,
+) {
+	for j := range jobs {
+		page, err := checkLink(withEntrypoint(ctx, j.entrypoint), client, cfg, j.url)
+		if err != nil {
+			var fetchErr *FetchError
+			if errors.As(err, &fetchErr) {
+				fetchErr.Parent = j.from.Parent
+			}
+			// WHY: Jobs failed after the cancellation are discarded
+			//      by the scheduler, so they are not failures.
+			if ctx.Err() == nil {
+				errs <- err
+			}
+			page.Err = err
+		}
+		j.results <- crawlResult{job: j, page: page}
+	}
+}
+
+// checkLink checks if the given URL is reachable with a HEAD request.
+// Since some servers do not handle HEAD requests properly, if it fails
+// a GET request is made. The returned page is never nil.
+func checkLink(
+	ctx context.Context,
+	c *http.Client,
+	cfg Config,
+	u url.URL,
+) (*Page, error) {
+	page, err := check(ctx, c, cfg, http.MethodHead, u)
+	if err == nil {
+		return page, nil
+	}
+	return check(ctx, c, cfg, http.MethodGet, u)
+}
+
+func check(
+	ctx context.Context,
+	c *http.Client,
+	cfg Config,
+	method string,
+	u url.URL,
+) (*Page, error) {
+	checked := &Page{}
+
+	req, cancel, err := newRequest(ctx, cfg, method, u)
+	defer cancel()
+	if err != nil {
+		return checked, &FetchError{URL: u, Method: method, Phase: RequestPhase, Err: err}
+	}
+
+	req, done := traceLatency(req, checked)
+	defer done()
+
+	res, err := c.Do(req)
+	if err != nil {
+		return checked, &FetchError{URL: u, Method: method, Phase: TransportPhase, Err: err}
+	}
+	defer res.Body.Close()
+
+	checked.StatusCode = res.StatusCode
+	checked.ContentType = res.Header.Get("Content-Type")
+
+	if cfg.Hooks.OnResponse != nil {
+		cfg.Hooks.OnResponse(res)
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxCheckBodySize))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return checked, &FetchError{
+			URL:        u,
+			Method:     method,
+			Phase:      StatusPhase,
+			StatusCode: res.StatusCode,
+		}
+	}
+
+	return checked, nil
+}
@@ -0,0 +1,226 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestCrawlingChecksExternalLinks(t *testing.T) {
+	external, requests, teardownExternal := setupExternalServer(t)
+	defer teardownExternal()
+
+	extlink := func(path string) string {
+		return external.String() + path
+	}
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, `<a href="/page.html"></a><a href="%s"></a><a href="%s"></a><a href="%s"></a>`,
+				extlink("/ok"), extlink("/nohead"), extlink("/missing"))
+		case "/page.html":
+			fmt.Fprintf(w, `<a href="%s"></a>`, extlink("/ok"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer teardown()
+
+	results, errs := crawler.New(
+		crawler.WithExternalLinks(2),
+		crawler.WithPageResults(),
+	).Run(context.Background(), entrypoint)
+
+	gotErrs := make(chan []error)
+	go func() {
+		fetchErrs := []error{}
+		for err := range errs {
+			fetchErrs = append(fetchErrs, err)
+		}
+		gotErrs <- fetchErrs
+	}()
+
+	wantEdges := map[string]bool{
+		"->" + extlink("/ok"):           true,
+		"->" + extlink("/nohead"):       true,
+		"->" + extlink("/missing"):      true,
+		"/page.html->" + extlink("/ok"): true,
+	}
+	wantStatus := map[string]int{
+		extlink("/ok"):      http.StatusOK,
+		extlink("/nohead"):  http.StatusOK,
+		extlink("/missing"): http.StatusNotFound,
+	}
+
+	for res := range results {
+		if res.Link.Host != external.Host {
+			if res.External {
+				t.Errorf("internal result[%s] marked as external", res)
+			}
+			continue
+		}
+
+		if !res.External {
+			t.Errorf("external result[%s] not marked as external", res)
+		}
+
+		if res.Page == nil {
+			edge := res.Parent.Path + "->" + res.Link.String()
+			if !wantEdges[edge] {
+				t.Errorf("unexpected external link[%s]", res)
+			}
+			delete(wantEdges, edge)
+			continue
+		}
+
+		want, ok := wantStatus[res.Link.String()]
+		if !ok {
+			t.Errorf("unexpected external page result[%s]", res)
+			continue
+		}
+		delete(wantStatus, res.Link.String())
+
+		if res.Page.StatusCode != want {
+			t.Errorf("page[%s]: want status %d, got %d", res.Link.String(), want, res.Page.StatusCode)
+		}
+		if (want != http.StatusOK) != (res.Page.Err != nil) {
+			t.Errorf("page[%s]: unexpected err[%v]", res.Link.String(), res.Page.Err)
+		}
+	}
+
+	if len(wantEdges) > 0 {
+		t.Errorf("missing external links: %v", wantEdges)
+	}
+	if len(wantStatus) > 0 {
+		t.Errorf("missing external page results: %v", wantStatus)
+	}
+
+	fetchErrs := <-gotErrs
+	if len(fetchErrs) != 1 {
+		t.Fatalf("want only the missing link error, got: %v", fetchErrs)
+	}
+	var fetchErr *crawler.FetchError
+	if !errors.As(fetchErrs[0], &fetchErr) || fetchErr.URL.String() != extlink("/missing") {
+		t.Fatalf("want fetch error of missing link, got: %v", fetchErrs[0])
+	}
+
+	wantRequests := map[string]int{
+		"HEAD /ok":      1,
+		"HEAD /nohead":  1,
+		"GET /nohead":   1,
+		"HEAD /missing": 1,
+		"GET /missing":  1,
+	}
+	gotRequests := requests()
+	if len(wantRequests) != len(gotRequests) {
+		t.Fatalf("want external requests %v, got %v", wantRequests, gotRequests)
+	}
+	for req, count := range wantRequests {
+		if gotRequests[req] != count {
+			t.Fatalf("want external requests %v, got %v", wantRequests, gotRequests)
+		}
+	}
+}
+
+func TestCrawlingSendsNonHTTPExternalLinksWithoutCheckingThem(t *testing.T) {
+	links := []string{"mailto:someone@example.com", "tel:+5548999999999", "javascript:void(0)"}
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			for _, link := range links {
+				fmt.Fprintf(w, `<a href="%s"></a>`, link)
+			}
+		}
+	}))
+	defer teardown()
+
+	want := []crawler.Result{}
+	for _, link := range links {
+		u, err := url.Parse(link)
+		fatalerr(t, err, "parsing link")
+		want = append(want, crawler.Result{
+			Parent:   entrypoint,
+			Link:     *u,
+			Kind:     "page",
+			External: true,
+		})
+	}
+
+	results, errs := crawler.New(crawler.WithExternalLinks(1)).Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, want, 0)
+}
+
+func TestCrawlingIgnoresExternalLinksByDefault(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	results, errs := crawler.New(crawler.WithPageResults()).Run(context.Background(), entrypoint)
+	go func() {
+		for range errs {
+		}
+	}()
+
+	for res := range results {
+		if res.External || res.Link.Host != entrypoint.Host {
+			t.Errorf("unexpected external result[%s]", res)
+		}
+	}
+}
+
+func TestCrawlerFailsToRunIfExternalConcurrencyIsZero(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/emptysite")
+	defer teardown()
+
+	const wantErrs = 1
+
+	results, errs := crawler.New(crawler.WithExternalLinks(0)).Run(
+		context.Background(),
+		entrypoint,
+	)
+	checkResults(t, results, errs, []crawler.Result{}, wantErrs)
+}
+
+// setupExternalServer serves /ok, /nohead that does not accept HEAD
+// requests and /missing. The returned function gives how many times
+// each request, like "HEAD /ok", was made.
+func setupExternalServer(t *testing.T) (url.URL, func() map[string]int, func()) {
+	var mutex sync.Mutex
+	requests := map[string]int{}
+
+	u, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.Method+" "+r.URL.Path] += 1
+		mutex.Unlock()
+
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/nohead":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return u, func() map[string]int {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		copied := map[string]int{}
+		for k, v := range requests {
+			copied[k] = v
+		}
+		return copied
+	}, teardown
+}
@@ -3,8 +3,11 @@ package crawler
 import (
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/katcipis/crawler/parser"
 )
 
 // Formatter is a function that given a channel of crawling results
@@ -25,42 +28,199 @@ type Formatter func(<-chan Result, io.Writer) error
 //
 // No repeated URLs are going to be written on the sitemap.
 // The space complexity of this function is linear ( O(N) ) to
-// the amount of unique URLs found in the results.
+// the amount of unique URLs found in the results, since each one
+// is kept until written and after it, use a TextSitemapFormatter
+// with a DiskStorage to keep them on disk.
+//
+// When page results are available, pages with a noindex robots
+// directive and pages that were not crawled successfully, with a
+// status other than 200, are left out of the sitemap. Links are only
+// written after their page result arrives or, if it never arrives, after
+// all results are drained, so they can be left out if they are noindex
+// pages or failures. Redirected URLs are always left out, only the URL
+// they were redirected to is on the sitemap, and so are external links.
 func FormatAsTextSitemap(res <-chan Result, w io.Writer) error {
-	seen := map[string]bool{}
+	return TextSitemapFormatter{}.Format(res, w)
+}
+
+// FormatAsTextSitemapWithNoIndex works like FormatAsTextSitemap
+// but does not leave out pages with a noindex robots directive.
+func FormatAsTextSitemapWithNoIndex(res <-chan Result, w io.Writer) error {
+	return TextSitemapFormatter{IncludeNoIndex: true}.Format(res, w)
+}
+
+// TextSitemapFormatter formats results as a text sitemap,
+// check FormatAsTextSitemap for details.
+type TextSitemapFormatter struct {
+	// IncludeNoIndex includes pages with a noindex robots directive.
+	IncludeNoIndex bool
+	// Storage is where the URLs found are kept until they are written
+	// and after it, to avoid repeated URLs. MemoryStorage is used if nil.
+	Storage Storage
+}
+
+// Format will drain the given Result channel and write
+// them in the given writer formatted as a text sitemap.
+func (f TextSitemapFormatter) Format(res <-chan Result, w io.Writer) error {
 	first := true
 
-	write := func(s string) error {
-		if !seen[s] {
-			seen[s] = true
-			if first {
-				first = false
-			} else {
-				s = "\n" + s
-			}
-			_, err := w.Write([]byte(s))
-			return err
+	return sitemapURLs(res, f.IncludeNoIndex, f.Storage, func(u url.URL, _ *Page) error {
+		s := u.String()
+		if first {
+			first = false
+		} else {
+			s = "\n" + s
+		}
+		_, err := w.Write([]byte(s))
+		if err != nil {
+			return fmt.Errorf("text sitemap formatter: failed to write result: %s", err)
 		}
 		return nil
+	})
+}
+
+// sitemapURLs drains the results calling write once for each unique URL,
+// along with its page result if there is one. URLs of pages with a noindex
+// robots directive are left out, unless includeNoIndex is true, and so are
+// URLs that were redirected, since the URL they were redirected to is on
+// the sitemap, and URLs of pages that were not crawled successfully.
+// External links are never on the sitemap. The URLs are kept on the given
+// storage, or in memory if it is nil.
+//
+// Links are only written after their page result arrives or, if it never
+// arrives, after all results are drained, so they can be left out if they
+// are noindex pages, redirects or failures.
+func sitemapURLs(
+	res <-chan Result,
+	includeNoIndex bool,
+	storage Storage,
+	write func(url.URL, *Page) error,
+) error {
+	if storage == nil {
+		storage = MemoryStorage
+	}
+
+	seen, err := storage.NewVisitedSet()
+	if err != nil {
+		return err
+	}
+	defer seen.Close()
+
+	skipped, err := storage.NewVisitedSet()
+	if err != nil {
+		return err
+	}
+	defer skipped.Close()
+
+	// WHY: A link is found on many pages, so it is pushed only once
+	//      on the pending links, keeping them linear to unique URLs.
+	pending, err := storage.NewVisitedSet()
+	if err != nil {
+		return err
+	}
+	defer pending.Close()
+
+	pendingLinks, err := storage.NewFrontier()
+	if err != nil {
+		return err
+	}
+	defer pendingLinks.Close()
+
+	writeOnce := func(u url.URL, page *Page) error {
+		s := u.String()
+		skip, err := skipped.Contains(s)
+		if err != nil || skip {
+			return err
+		}
+		added, err := seen.Add(s)
+		if err != nil || !added {
+			return err
+		}
+		return write(u, page)
 	}
 
 	for r := range res {
-		err := write(r.Parent.String())
+		if r.Page != nil {
+			// WHY: The last redirect of the chain is not sent as
+			//      a link result, so all redirected URLs are skipped.
+			for _, redirect := range r.Page.Redirects {
+				if _, err := skipped.Add(redirect.URL.String()); err != nil {
+					return err
+				}
+			}
+			redirected := len(r.Page.Redirects) > 0
+			if redirected || !crawledOK(r) || (r.Page.NoIndex && !includeNoIndex) {
+				if _, err := skipped.Add(r.Link.String()); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := writeOnce(r.Link, r.Page); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if r.Kind == parser.RedirectLink {
+			if _, err := skipped.Add(r.Parent.String()); err != nil {
+				return err
+			}
+		} else if !r.FromSitemap {
+			// WHY: The parent of links found on sitemaps is the
+			//      sitemap file, which is not a page of the site.
+			if err := writeOnce(r.Parent, nil); err != nil {
+				return err
+			}
+		}
+
+		if r.External {
+			continue
+		}
+
+		link := r.Link.String()
+		written, err := seen.Contains(link)
 		if err != nil {
-			return fmt.Errorf("text sitemap formatter: failed to write result: %s", err)
+			return err
+		}
+		if written {
+			continue
+		}
+		added, err := pending.Add(link)
+		if err != nil {
+			return err
+		}
+		if added {
+			if err := pendingLinks.Push(FrontierJob{URL: link}); err != nil {
+				return err
+			}
 		}
+	}
 
-		err = write(r.Link.String())
+	for {
+		j, ok, err := pendingLinks.Pop()
+		if err != nil || !ok {
+			return err
+		}
+		link, err := url.Parse(j.URL)
 		if err != nil {
-			return fmt.Errorf("text sitemap formatter: failed to write result: %s", err)
+			return fmt.Errorf("invalid pending link[%s]: %s", j.URL, err)
+		}
+		if err := writeOnce(*link, nil); err != nil {
+			return err
 		}
 	}
+}
 
-	return nil
+// crawledOK returns true if the given page result
+// is of a page in the scope crawled successfully.
+func crawledOK(r Result) bool {
+	return !r.External && r.Page.Err == nil && r.Page.StatusCode == http.StatusOK
 }
 
 // FormatAsGraphvizSitemap will drain the given Result channel and
 // write then in the given writer formatted as a graphviz dot file.
+// Page results are ignored, except the ones of URLs redirected to,
+// which are also the results of their last redirects.
 func FormatAsGraphvizSitemap(res <-chan Result, w io.Writer) error {
 	_, err := w.Write([]byte("digraph {\n"))
 	if err != nil {
@@ -68,6 +228,11 @@ func FormatAsGraphvizSitemap(res <-chan Result, w io.Writer) error {
 	}
 
 	for r := range res {
+		// WHY: Page results of URLs redirected to are also
+		//      the results of their last redirects.
+		if r.Page != nil && r.RedirectStatus == 0 {
+			continue
+		}
 		linkedNodes := linkRepr(r)
 		_, err := w.Write([]byte(linkedNodes + "\n"))
 		if err != nil {
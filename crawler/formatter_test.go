@@ -3,6 +3,7 @@ package crawler_test
 import (
 	"bytes"
 	"errors"
+	"net/http"
 	"net/url"
 	"testing"
 
@@ -58,6 +59,115 @@ func TestTextSitemapFormatter(t *testing.T) {
 	}
 }
 
+func TestTextSitemapFormatterLeavesOutNoIndexPages(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	page := func(path string, noindex bool) crawler.Result {
+		r := result(entrypoint, "", path)
+		r.Page = &crawler.Page{StatusCode: 200, NoIndex: noindex}
+		return r
+	}
+
+	results := []crawler.Result{
+		page("", false),
+		result(entrypoint, "", "/noindex"),
+		result(entrypoint, "", "/index"),
+		page("/noindex", true),
+		page("/index", false),
+		result(entrypoint, "/noindex", "/notcrawled"),
+	}
+
+	testFormatter(t, FormatterTestCase{
+		name:    "LeavingOut",
+		results: results,
+		want:    "http://test\nhttp://test/index\nhttp://test/notcrawled",
+	}, crawler.FormatAsTextSitemap)
+
+	testFormatter(t, FormatterTestCase{
+		name:    "Including",
+		results: results,
+		want:    "http://test\nhttp://test/noindex\nhttp://test/index\nhttp://test/notcrawled",
+	}, crawler.FormatAsTextSitemapWithNoIndex)
+}
+
+func TestTextSitemapFormatterLeavesOutSitemapFiles(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	fromSitemap := result(entrypoint, "/sitemap.xml", "/orphan")
+	fromSitemap.FromSitemap = true
+
+	testFormatter(t, FormatterTestCase{
+		name: "LeavingOut",
+		results: []crawler.Result{
+			fromSitemap,
+			result(entrypoint, "", "/a"),
+		},
+		want: "http://test\nhttp://test/orphan\nhttp://test/a",
+	}, crawler.FormatAsTextSitemap)
+}
+
+func TestTextSitemapFormatterLeavesOutRedirects(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	redirected := result(entrypoint, "", "/old")
+	redirected.Page = &crawler.Page{
+		StatusCode: 301,
+		Redirects: []crawler.Redirect{{
+			URL:        redirected.Link,
+			StatusCode: 301,
+			Location:   url.URL{Scheme: "http", Host: "test", Path: "/new"},
+		}},
+	}
+
+	testFormatter(t, FormatterTestCase{
+		name: "WithPages",
+		results: []crawler.Result{
+			result(entrypoint, "", "/old"),
+			redirected,
+			redirectResult(entrypoint, "/old", "/new", 301),
+			result(entrypoint, "/new", "/a"),
+		},
+		want: "http://test\nhttp://test/new\nhttp://test/a",
+	}, crawler.FormatAsTextSitemap)
+
+	testFormatter(t, FormatterTestCase{
+		name: "WithoutPages",
+		results: []crawler.Result{
+			result(entrypoint, "", "/old"),
+			redirectResult(entrypoint, "/old", "/new", 301),
+			result(entrypoint, "/new", "/a"),
+		},
+		want: "http://test\nhttp://test/new\nhttp://test/a",
+	}, crawler.FormatAsTextSitemap)
+}
+
+func TestGraphvizSitemapFormatterIgnoresPages(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	page := result(entrypoint, "", "/page")
+	page.Page = &crawler.Page{}
+
+	testFormatter(t, FormatterTestCase{
+		name: "IgnoringPages",
+		results: []crawler.Result{
+			page,
+			result(entrypoint, "", "/page"),
+		},
+		want: "digraph {\n\"test\" -> \"/page\"\n}",
+	}, crawler.FormatAsGraphvizSitemap)
+}
+
+func TestGraphvizSitemapFormatterWritesPagesOfLastRedirects(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	redirected := redirectResult(entrypoint, "/old", "/new", http.StatusMovedPermanently)
+	redirected.Page = &crawler.Page{StatusCode: http.StatusOK}
+
+	testFormatter(t, FormatterTestCase{
+		name: "PagesOfLastRedirects",
+		results: []crawler.Result{
+			result(entrypoint, "", "/old"),
+			redirected,
+		},
+		want: "digraph {\n\"test\" -> \"/old\"\n\"/old\" -> \"/new\"\n}",
+	}, crawler.FormatAsGraphvizSitemap)
+}
+
 func TestOnWriteErrorFormatterFails(t *testing.T) {
 	type tcase struct {
 		name   string
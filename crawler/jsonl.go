@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// jsonlRecord is the JSON object written for each
+// link or page by FormatAsJSONLines.
+type jsonlRecord struct {
+	Type        string           `json:"type"`
+	Seed        string           `json:"seed,omitempty"`
+	Parent      string           `json:"parent,omitempty"`
+	Link        string           `json:"link"`
+	Kind        string           `json:"kind,omitempty"`
+	Discovery   string           `json:"discovery"`
+	Depth       *uint            `json:"depth,omitempty"`
+	NoFollow    bool             `json:"nofollow,omitempty"`
+	Status      int              `json:"status,omitempty"`
+	ContentType string           `json:"content_type,omitempty"`
+	Size        int64            `json:"size,omitempty"`
+	Leaf        bool             `json:"leaf,omitempty"`
+	Truncated   bool             `json:"truncated,omitempty"`
+	LatencyMS   float64          `json:"latency_ms,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	H1s         []string         `json:"h1s,omitempty"`
+	Lang        string           `json:"lang,omitempty"`
+	Alternates  []jsonlAlternate `json:"alternates,omitempty"`
+	WordCount   int              `json:"word_count,omitempty"`
+}
+
+type jsonlAlternate struct {
+	Lang string `json:"lang"`
+	URL  string `json:"url"`
+}
+
+// FormatAsJSONLines will drain the given Result channel and write them
+// in the given writer as JSON Lines, one JSON object per line:
+//
+// https://jsonlines.org/
+//
+// Links have the "link" type and carry their parent, link, kind, depth,
+// discovery and if they are nofollow. The discovery is "sitemap" for
+// links found on sitemaps and "link" for the other ones. Pages have
+// the "page" type and also carry the status code, content type, size in
+// bytes, latency in milliseconds and error of their fetch, if they are
+// leaves, whose body was not parsed, if their body was truncated at the
+// max body size and the metadata of HTML pages, like their title,
+// description, h1s, lang, alternates and word count.
+// Results of crawls with many seeds also carry their seed. Links of the
+// "redirect" kind carry the status code of the redirect, except the last
+// redirect of a chain, whose record is the page it was redirected to.
+// Empty fields are omitted.
+//
+// The depth of links is only known when page results are available,
+// since it is the depth of their parent page plus one.
+func FormatAsJSONLines(res <-chan Result, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	depths := map[string]uint{}
+
+	for r := range res {
+		record := jsonlRecord{
+			Type:      "link",
+			Parent:    r.Parent.String(),
+			Link:      r.Link.String(),
+			Kind:      string(r.Kind),
+			Discovery: "link",
+			NoFollow:  r.NoFollow,
+		}
+		if r.FromSitemap {
+			record.Discovery = "sitemap"
+		}
+		if r.RedirectStatus != 0 {
+			record.Status = r.RedirectStatus
+		}
+		if r.Seed.Host != "" {
+			record.Seed = r.Seed.String()
+		}
+
+		if r.Page != nil {
+			depth := r.Page.Depth
+			depths[record.Link] = depth
+
+			record.Type = "page"
+			record.Depth = &depth
+			record.Status = r.Page.StatusCode
+			record.ContentType = r.Page.ContentType
+			record.Size = r.Page.Size
+			record.Leaf = r.Page.Leaf
+			record.Truncated = r.Page.Truncated
+			record.LatencyMS = float64(r.Page.Latency) / float64(time.Millisecond)
+			if r.Page.Err != nil {
+				record.Error = r.Page.Err.Error()
+			}
+			record.Title = r.Page.Title
+			record.Description = r.Page.Description
+			record.H1s = r.Page.H1s
+			record.Lang = r.Page.Lang
+			record.WordCount = r.Page.WordCount
+			for _, alternate := range r.Page.Alternates {
+				record.Alternates = append(record.Alternates, jsonlAlternate{
+					Lang: alternate.Lang,
+					URL:  alternate.URL.String(),
+				})
+			}
+		} else if parentDepth, ok := depths[record.Parent]; ok {
+			depth := parentDepth + 1
+			record.Depth = &depth
+		}
+
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("jsonl formatter: failed to write result: %s", err)
+		}
+	}
+
+	return nil
+}
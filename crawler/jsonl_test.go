@@ -0,0 +1,157 @@
+package crawler_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/katcipis/crawler/crawler"
+	"github.com/katcipis/crawler/parser"
+)
+
+func TestJSONLinesFormatter(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	page := func(parent string, path string, p crawler.Page) crawler.Result {
+		r := result(entrypoint, parent, path)
+		r.Page = &p
+		return r
+	}
+	nofollow := result(entrypoint, "/a", "/c?d=1&e=<f>")
+	nofollow.NoFollow = true
+	fromSitemap := result(entrypoint, "/sitemap.xml", "/orphan")
+	fromSitemap.Kind = "page"
+	fromSitemap.FromSitemap = true
+	seeded := result(entrypoint, "", "/a")
+	seeded.Seed = entrypoint
+
+	cases := []FormatterTestCase{
+		{
+			name:    "empty",
+			results: []crawler.Result{},
+			want:    "",
+		},
+		{
+			name: "linksWithoutPages",
+			results: []crawler.Result{
+				result(entrypoint, "", "/a"),
+			},
+			want: `{"type":"link","parent":"http://test","link":"http://test/a","kind":"page","discovery":"link"}` + "\n",
+		},
+		{
+			name: "linksFromSitemaps",
+			results: []crawler.Result{
+				fromSitemap,
+			},
+			want: `{"type":"link","parent":"http://test/sitemap.xml","link":"http://test/orphan","kind":"page","discovery":"sitemap"}` + "\n",
+		},
+		{
+			name: "linksFromSeeds",
+			results: []crawler.Result{
+				seeded,
+			},
+			want: `{"type":"link","seed":"http://test","parent":"http://test","link":"http://test/a","kind":"page","discovery":"link"}` + "\n",
+		},
+		{
+			name: "leafPages",
+			results: []crawler.Result{
+				page("", "/doc.pdf", crawler.Page{
+					Depth:       1,
+					StatusCode:  200,
+					ContentType: "application/pdf",
+					Size:        1024,
+					Leaf:        true,
+				}),
+			},
+			want: `{"type":"page","parent":"http://test","link":"http://test/doc.pdf","kind":"page","discovery":"link","depth":1,"status":200,"content_type":"application/pdf","size":1024,"leaf":true}` + "\n",
+		},
+		{
+			name: "truncatedPages",
+			results: []crawler.Result{
+				page("", "/big", crawler.Page{
+					Depth:       1,
+					StatusCode:  200,
+					ContentType: "text/html",
+					Size:        1001,
+					Leaf:        true,
+					Truncated:   true,
+				}),
+			},
+			want: `{"type":"page","parent":"http://test","link":"http://test/big","kind":"page","discovery":"link","depth":1,"status":200,"content_type":"text/html","size":1001,"leaf":true,"truncated":true}` + "\n",
+		},
+		{
+			name: "pageMetadata",
+			results: []crawler.Result{
+				page("", "/a", crawler.Page{
+					Depth:       1,
+					StatusCode:  200,
+					Title:       "A",
+					Description: "About A",
+					H1s:         []string{"A", "More A"},
+					Lang:        "en",
+					Alternates: []parser.Alternate{{
+						Lang: "pt",
+						URL:  url.URL{Scheme: "http", Host: "test", Path: "/pt/a"},
+					}},
+					WordCount: 42,
+				}),
+			},
+			want: `{"type":"page","parent":"http://test","link":"http://test/a","kind":"page","discovery":"link","depth":1,"status":200,` +
+				`"title":"A","description":"About A","h1s":["A","More A"],"lang":"en",` +
+				`"alternates":[{"lang":"pt","url":"http://test/pt/a"}],"word_count":42}` + "\n",
+		},
+		{
+			name: "redirects",
+			results: []crawler.Result{
+				redirectResult(entrypoint, "/old", "/new", 301),
+			},
+			want: `{"type":"link","parent":"http://test/old","link":"http://test/new","kind":"redirect","discovery":"link","status":301}` + "\n",
+		},
+		{
+			name: "pagesAndLinks",
+			results: []crawler.Result{
+				{
+					Link: entrypoint,
+					Kind: "page",
+					Page: &crawler.Page{
+						StatusCode:  200,
+						ContentType: "text/html",
+						Latency:     1500 * time.Microsecond,
+					},
+				},
+				result(entrypoint, "", "/a"),
+				page("", "/a", crawler.Page{Depth: 1, StatusCode: 200}),
+				result(entrypoint, "/a", "/b"),
+				nofollow,
+				page("/a", "/b", crawler.Page{
+					Depth:      2,
+					StatusCode: 404,
+					Err:        errors.New("not found"),
+				}),
+			},
+			want: `{"type":"page","link":"http://test","kind":"page","discovery":"link","depth":0,"status":200,"content_type":"text/html","latency_ms":1.5}` + "\n" +
+				`{"type":"link","parent":"http://test","link":"http://test/a","kind":"page","discovery":"link","depth":1}` + "\n" +
+				`{"type":"page","parent":"http://test","link":"http://test/a","kind":"page","discovery":"link","depth":1,"status":200}` + "\n" +
+				`{"type":"link","parent":"http://test/a","link":"http://test/b","kind":"page","discovery":"link","depth":2}` + "\n" +
+				`{"type":"link","parent":"http://test/a","link":"http://test/c%3Fd=1&e=%3Cf%3E","kind":"page","discovery":"link","depth":2,"nofollow":true}` + "\n" +
+				`{"type":"page","parent":"http://test/a","link":"http://test/b","kind":"page","discovery":"link","depth":2,"status":404,"error":"not found"}` + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		testFormatter(t, c, crawler.FormatAsJSONLines)
+	}
+}
+
+func TestJSONLinesFormatterFailsOnWriteError(t *testing.T) {
+	res := make(chan crawler.Result, 3)
+	for i := 0; i < cap(res); i++ {
+		res <- crawler.Result{Parent: url.URL{Scheme: "http", Host: "fail.com"}}
+	}
+	close(res)
+
+	err := crawler.FormatAsJSONLines(res, &explodingWriter{failOnCall: 2})
+	if err == nil {
+		t.Fatal("expected error on failed second write")
+	}
+}
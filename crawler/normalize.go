@@ -0,0 +1,145 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultStripParams are the tracking and session query parameters
+// removed by the default normalizer.
+var DefaultStripParams = []string{
+	"utm_*",
+	"gclid",
+	"fbclid",
+	"msclkid",
+	"jsessionid",
+	"phpsessid",
+	"sessionid",
+}
+
+// Normalizer normalizes URLs before they are deduplicated, so
+// different URLs of the same resource are crawled only once.
+//
+// The scheme and host are lowercased, default ports and fragments
+// are removed and query parameters are sorted by name.
+type Normalizer struct {
+	// StripParams are query parameters removed from URLs, matched
+	// case insensitively. A trailing "*" matches any parameter with
+	// the given prefix, like "utm_*". Path parameters with the same
+	// names, like ";jsessionid=ID", are also removed.
+	StripParams []string
+	// RemoveTrailingSlash removes trailing slashes from paths, so
+	// "/docs/" and "/docs" are considered the same URL.
+	RemoveTrailingSlash bool
+}
+
+// NewDefaultNormalizer creates the Normalizer used by default,
+// which removes the DefaultStripParams.
+func NewDefaultNormalizer() *Normalizer {
+	params := make([]string, len(DefaultStripParams))
+	copy(params, DefaultStripParams)
+	return &Normalizer{StripParams: params}
+}
+
+// Normalize returns the normalized version of the given URL
+func (n *Normalizer) Normalize(u url.URL) url.URL {
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+
+	if (u.Scheme == "http" && strings.HasSuffix(u.Host, ":80")) ||
+		(u.Scheme == "https" && strings.HasSuffix(u.Host, ":443")) {
+		u.Host = u.Host[:strings.LastIndex(u.Host, ":")]
+	}
+
+	if strings.Contains(u.Path, ";") {
+		u.Path = n.stripPathParams(u.Path)
+		u.RawPath = ""
+	}
+
+	if n.RemoveTrailingSlash && strings.HasSuffix(u.Path, "/") {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+	}
+
+	u.RawQuery = n.normalizeQuery(u.RawQuery)
+	u.ForceQuery = false
+	return u
+}
+
+// normalizeQuery sorts the query parameters by name and removes the
+// stripped ones. It works with the raw query so the original encoding
+// is kept and parameters with the same name keep their relative order.
+func (n *Normalizer) normalizeQuery(rawquery string) string {
+	if rawquery == "" {
+		return ""
+	}
+
+	type param struct {
+		name string
+		raw  string
+	}
+
+	params := []param{}
+	for _, raw := range strings.Split(rawquery, "&") {
+		if raw == "" {
+			continue
+		}
+		name := strings.SplitN(raw, "=", 2)[0]
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if n.stripped(name) {
+			continue
+		}
+		params = append(params, param{name: name, raw: raw})
+	}
+
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].name < params[j].name
+	})
+
+	raws := make([]string, len(params))
+	for i, p := range params {
+		raws[i] = p.raw
+	}
+	return strings.Join(raws, "&")
+}
+
+func (n *Normalizer) stripPathParams(path string) string {
+	segments := strings.Split(path, "/")
+
+	for i, segment := range segments {
+		parts := strings.Split(segment, ";")
+		kept := parts[:1]
+		for _, param := range parts[1:] {
+			name := strings.SplitN(param, "=", 2)[0]
+			if !n.stripped(name) {
+				kept = append(kept, param)
+			}
+		}
+		segments[i] = strings.Join(kept, ";")
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func (n *Normalizer) stripped(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, param := range n.StripParams {
+		param = strings.ToLower(param)
+		if strings.HasSuffix(param, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(param, "*")) {
+				return true
+			}
+			continue
+		}
+		if name == param {
+			return true
+		}
+	}
+
+	return false
+}
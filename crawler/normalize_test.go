@@ -0,0 +1,126 @@
+package crawler_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestNormalizer(t *testing.T) {
+	type tcase struct {
+		name       string
+		normalizer *crawler.Normalizer
+		url        string
+		want       string
+	}
+
+	defaultNormalizer := crawler.NewDefaultNormalizer()
+
+	cases := []tcase{
+		{
+			name:       "alreadyNormalized",
+			normalizer: defaultNormalizer,
+			url:        "http://x/a",
+			want:       "http://x/a",
+		},
+		{
+			name:       "lowercaseSchemeAndHost",
+			normalizer: defaultNormalizer,
+			url:        "HTTP://X.COM/A",
+			want:       "http://x.com/A",
+		},
+		{
+			name:       "defaultHTTPPort",
+			normalizer: defaultNormalizer,
+			url:        "http://x:80/a",
+			want:       "http://x/a",
+		},
+		{
+			name:       "defaultHTTPSPort",
+			normalizer: defaultNormalizer,
+			url:        "https://x:443/a",
+			want:       "https://x/a",
+		},
+		{
+			name:       "nonDefaultPort",
+			normalizer: defaultNormalizer,
+			url:        "https://x:80/a",
+			want:       "https://x:80/a",
+		},
+		{
+			name:       "fragment",
+			normalizer: defaultNormalizer,
+			url:        "http://x/a#top",
+			want:       "http://x/a",
+		},
+		{
+			name:       "sortQuery",
+			normalizer: defaultNormalizer,
+			url:        "http://x/a?b=1&a=2",
+			want:       "http://x/a?a=2&b=1",
+		},
+		{
+			name:       "sortQueryKeepingRepeatedParamsOrder",
+			normalizer: defaultNormalizer,
+			url:        "http://x/a?b=2&a=1&b=1&&c",
+			want:       "http://x/a?a=1&b=2&b=1&c",
+		},
+		{
+			name:       "keepQueryEncoding",
+			normalizer: defaultNormalizer,
+			url:        "http://x/a?q=a%20b&p=a+b",
+			want:       "http://x/a?p=a+b&q=a%20b",
+		},
+		{
+			name:       "emptyQuery",
+			normalizer: defaultNormalizer,
+			url:        "http://x/a?",
+			want:       "http://x/a",
+		},
+		{
+			name:       "stripTrackingParams",
+			normalizer: defaultNormalizer,
+			url:        "http://x/a?utm_source=s&UTM_Medium=m&id=1&gclid=g&PHPSESSID=p",
+			want:       "http://x/a?id=1",
+		},
+		{
+			name:       "stripPathParams",
+			normalizer: defaultNormalizer,
+			url:        "http://x/a;jsessionid=123;v=1/b;JSESSIONID=456",
+			want:       "http://x/a;v=1/b",
+		},
+		{
+			name:       "keepTrailingSlash",
+			normalizer: defaultNormalizer,
+			url:        "http://x/a/",
+			want:       "http://x/a/",
+		},
+		{
+			name:       "removeTrailingSlash",
+			normalizer: &crawler.Normalizer{RemoveTrailingSlash: true},
+			url:        "http://X/a/",
+			want:       "http://x/a",
+		},
+		{
+			name:       "customStripParams",
+			normalizer: &crawler.Normalizer{StripParams: []string{"ref", "session*"}},
+			url:        "http://x/a?utm_source=s&ref=r&session_id=1&sessions=2",
+			want:       "http://x/a?utm_source=s",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			u, err := url.Parse(c.url)
+			if err != nil {
+				t.Fatalf("error[%s] parsing url[%s]", err, c.url)
+			}
+
+			normalized := c.normalizer.Normalize(*u)
+			if got := normalized.String(); got != c.want {
+				t.Fatalf("want[%s] != got[%s]", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,476 @@
+package crawler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/katcipis/crawler/parser"
+)
+
+const (
+	// DefaultUserAgent is the user agent used on requests and to match
+	// robots.txt rules when no user agent is provided.
+	DefaultUserAgent = "katcipis-crawler/1.0"
+	// DefaultConcurrency is the amount of concurrent crawlers
+	// used when no concurrency is provided.
+	DefaultConcurrency = 10
+	// DefaultRequestTimeout is the timeout of each request
+	// when no request timeout is provided.
+	DefaultRequestTimeout = time.Minute
+)
+
+// Config holds all the configuration of a Crawler.
+// It is not necessary to create a Config directly,
+// use New with the Option functions instead.
+type Config struct {
+	// Concurrency is the amount of concurrent crawlers,
+	// it must be greater than zero.
+	Concurrency uint
+	// RequestTimeout is the timeout of each request made,
+	// zero means no timeout.
+	RequestTimeout time.Duration
+	// HTTPClient is the client used to make all requests.
+	// Its transport is wrapped to enforce rate limits.
+	HTTPClient *http.Client
+	// UserAgent is sent on each request and used to match robots.txt rules.
+	UserAgent string
+	// IgnoreRobots disables fetching and honoring the robots.txt
+	// and the nofollow directives.
+	IgnoreRobots bool
+	// RateLimit is the maximum amount of requests per second
+	// made to each host, zero means no limit.
+	RateLimit float64
+	// RateBurst is the maximum burst of requests made to
+	// each host when RateLimit is used.
+	RateBurst uint
+	// MaxDepth is the maximum distance from the entrypoint
+	// of crawled URLs, zero means no limit.
+	MaxDepth uint
+	// MaxPages is the maximum amount of URLs that are crawled,
+	// including the entrypoint, zero means no limit.
+	MaxPages uint
+	// FollowKinds are the kinds of links that are sent as results and crawled.
+	FollowKinds []parser.LinkKind
+	// ReportKinds are the kinds of links that are sent as results
+	// but are not crawled.
+	ReportKinds []parser.LinkKind
+	// Normalizer normalizes all URLs before they are deduplicated,
+	// nil disables normalization.
+	Normalizer *Normalizer
+	// Scope decides which links are crawled, links out of it are external.
+	Scope Scope
+	// HonorCanonical enables honoring <link rel="canonical">.
+	HonorCanonical bool
+	// PageResults enables sending a page result for each crawled URL.
+	PageResults bool
+	// ExternalLinks enables sending and checking links out of the Scope.
+	ExternalLinks bool
+	// ExternalConcurrency is the amount of concurrent checkers of
+	// external links, it must be greater than zero if ExternalLinks is on.
+	ExternalConcurrency uint
+	// LinkFilters are applied on each result, only results accepted
+	// by all filters are sent and followed.
+	LinkFilters []LinkFilter
+	// Hooks are called during the crawling.
+	Hooks Hooks
+	// CheckpointPath is the file where checkpoints are saved,
+	// empty disables checkpoints.
+	CheckpointPath string
+	// CheckpointInterval is the interval between checkpoints,
+	// zero means that only the final checkpoint is saved.
+	CheckpointInterval time.Duration
+	// Resume is the checkpoint where the crawling is resumed from,
+	// nil starts a new crawling.
+	Resume *Checkpoint
+	// Storage is where the frontiers and visited sets are kept.
+	Storage Storage
+	// Strategy decides the order the URLs are crawled.
+	Strategy Strategy
+	// Sitemaps enables seeding the crawling with the URLs of sitemaps.
+	Sitemaps bool
+	// SitemapURLs are sitemaps used besides the ones on the robots.txt.
+	SitemapURLs []url.URL
+	// Retry decides which failed fetches are retried, by default none.
+	Retry RetryPolicy
+	// Headers are sent on each request to URLs in the Scope.
+	Headers http.Header
+	// CookieJar keeps the cookies of URLs in the Scope and sends them
+	// on each request to URLs in the Scope, nil disables cookies.
+	CookieJar http.CookieJar
+	// Credentials are sent on each request to URLs in the Scope.
+	Credentials Credentials
+	// CheckContentType enables parsing only responses whose
+	// Content-Type is HTML, or is missing.
+	CheckContentType bool
+	// HeadProbe enables making a HEAD request before fetching each URL,
+	// so bodies that are not parsed are never downloaded.
+	HeadProbe bool
+	// MaxBodySize is the maximum size in bytes of the bodies
+	// that are read, zero means no limit.
+	MaxBodySize int64
+}
+
+// Option configures optional behavior of the crawler
+type Option func(*Config)
+
+// LinkFilter returns true if the given result must be kept
+// or false if it must be discarded.
+type LinkFilter func(Result) bool
+
+// Hooks are functions called during the crawling process.
+// They are called concurrently by the crawlers, so they must
+// be safe for concurrent use. Nil hooks are ignored.
+type Hooks struct {
+	// OnRequest is called before each request is sent
+	// and it may modify the request.
+	OnRequest func(*http.Request)
+	// OnResponse is called after each response is received,
+	// before its body is read.
+	OnResponse func(*http.Response)
+}
+
+// WithConcurrency sets the amount of concurrent crawlers.
+func WithConcurrency(concurrency uint) Option {
+	return func(c *Config) {
+		c.Concurrency = concurrency
+	}
+}
+
+// WithRequestTimeout sets the timeout of each request made.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.RequestTimeout = timeout
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to make all requests,
+// which allows to customize the transport used by the crawler.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Config) {
+		c.HTTPClient = client
+	}
+}
+
+// WithUserAgent sets the user agent sent on each request.
+// The product token of the user agent (anything before the first "/")
+// is also used to select which robots.txt rules apply to the crawler.
+func WithUserAgent(useragent string) Option {
+	return func(c *Config) {
+		c.UserAgent = useragent
+	}
+}
+
+// WithoutRobots disables fetching and honoring the robots.txt
+// of the entrypoint host and the nofollow directives of links and pages.
+func WithoutRobots() Option {
+	return func(c *Config) {
+		c.IgnoreRobots = true
+	}
+}
+
+// WithRateLimit limits the amount of requests per second made to
+// each host, allowing bursts of up to burst requests. A rate of zero
+// (the default) means no rate limit. If the robots.txt of a host has
+// a Crawl-delay the slowest of them is used for that host.
+func WithRateLimit(rate float64, burst uint) Option {
+	return func(c *Config) {
+		c.RateLimit = rate
+		c.RateBurst = burst
+	}
+}
+
+// WithMaxDepth limits the crawling to URLs that are at most
+// depth links away from the entrypoint. Links found beyond
+// the maximum depth are still sent as results, but not crawled.
+func WithMaxDepth(depth uint) Option {
+	return func(c *Config) {
+		c.MaxDepth = depth
+	}
+}
+
+// WithMaxPages limits the amount of URLs that are crawled, including
+// the entrypoint. Once the limit is reached no new URL is scheduled, but
+// the links found on the URLs already scheduled are still sent as results.
+func WithMaxPages(pages uint) Option {
+	return func(c *Config) {
+		c.MaxPages = pages
+	}
+}
+
+// WithNormalizer sets the normalizer applied on all URLs before they
+// are deduplicated. By default the normalizer created by
+// NewDefaultNormalizer is used, passing nil disables normalization.
+func WithNormalizer(n *Normalizer) Option {
+	return func(c *Config) {
+		c.Normalizer = n
+	}
+}
+
+// WithScope sets the scope that decides which links are crawled,
+// by default only links with the same host of the entrypoint are.
+// Check ScopeRules for a flexible scope.
+func WithScope(scope Scope) Option {
+	return func(c *Config) {
+		c.Scope = scope
+	}
+}
+
+// WithCanonical enables honoring <link rel="canonical">. When a crawled
+// page has a canonical URL on the same host the links found on it are
+// sent as links of the canonical URL, which is then not crawled again.
+// If the canonical URL was already crawled the links are discarded.
+func WithCanonical() Option {
+	return func(c *Config) {
+		c.HonorCanonical = true
+	}
+}
+
+// WithPageResults enables sending a page result for each crawled URL,
+// check Result.Page for details.
+func WithPageResults() Option {
+	return func(c *Config) {
+		c.PageResults = true
+	}
+}
+
+// WithExternalLinks enables sending links out of the scope as results.
+// Followed external links are checked by concurrency checkers, separated
+// from the crawlers, with a HEAD request that falls back to a GET request
+// if it fails. External links are never crawled, their page results have
+// only the information about the check, like the status code. Only HTTP
+// links are checked, links like mailto: and tel: are just sent as results.
+func WithExternalLinks(concurrency uint) Option {
+	return func(c *Config) {
+		c.ExternalLinks = true
+		c.ExternalConcurrency = concurrency
+	}
+}
+
+// WithFollowedLinkKinds sets which kinds of links are sent as
+// results and crawled. By default only page links are followed.
+func WithFollowedLinkKinds(kinds ...parser.LinkKind) Option {
+	return func(c *Config) {
+		c.FollowKinds = kinds
+	}
+}
+
+// WithReportedLinkKinds sets which kinds of links are sent as results
+// but are not crawled, like assets when auditing them.
+// By default no extra kind of link is reported.
+func WithReportedLinkKinds(kinds ...parser.LinkKind) Option {
+	return func(c *Config) {
+		c.ReportKinds = kinds
+	}
+}
+
+// WithLinkFilter adds a filter applied on each result.
+// It can be used multiple times, only results accepted
+// by all filters are sent and followed.
+func WithLinkFilter(filter LinkFilter) Option {
+	return func(c *Config) {
+		c.LinkFilters = append(c.LinkFilters, filter)
+	}
+}
+
+// WithRequestHook sets a hook called before each request is sent.
+func WithRequestHook(hook func(*http.Request)) Option {
+	return func(c *Config) {
+		c.Hooks.OnRequest = hook
+	}
+}
+
+// WithResponseHook sets a hook called after each response is received.
+func WithResponseHook(hook func(*http.Response)) Option {
+	return func(c *Config) {
+		c.Hooks.OnResponse = hook
+	}
+}
+
+// WithCheckpoints saves checkpoints of the crawling on the given file
+// periodically, on the given interval, and when the crawling ends,
+// including when the context is cancelled. Check WithResume to resume
+// the crawling from a checkpoint.
+//
+// The whole state of the crawling is loaded in memory to save or
+// restore a checkpoint, so they are not suited for a DiskStorage.
+func WithCheckpoints(path string, interval time.Duration) Option {
+	return func(c *Config) {
+		c.CheckpointPath = path
+		c.CheckpointInterval = interval
+	}
+}
+
+// WithResume resumes the crawling from the given checkpoint, which
+// must be from a crawling with the same entrypoint. Results already
+// sent before the checkpoint are not sent again, so they must be written
+// as soon as they are received, like FormatAsJSONLines does. Formatters
+// that only write after all results are received, like the sitemap ones,
+// lose the results received before the checkpoint.
+func WithResume(cp *Checkpoint) Option {
+	return func(c *Config) {
+		c.Resume = cp
+	}
+}
+
+// WithStorage sets where the state of the crawling, the URLs waiting
+// to be crawled and the ones already visited, is kept. By default
+// it is kept in memory, use a DiskStorage to crawl huge sites.
+func WithStorage(storage Storage) Option {
+	return func(c *Config) {
+		c.Storage = storage
+	}
+}
+
+// WithStrategy sets the order the URLs are crawled, by default they are
+// crawled BreadthFirst. Use DepthFirst or BestFirst to change it, which is
+// useful when the crawling may not finish and the important URLs, like the
+// shallower ones, must be crawled first. DepthFirst and BestFirst keep
+// their frontier in memory, so the crawling fails if they are used with
+// a storage that does not, like a DiskStorage.
+func WithStrategy(strategy Strategy) Option {
+	return func(c *Config) {
+		c.Strategy = strategy
+	}
+}
+
+// WithSitemaps seeds the crawling with the URLs found on sitemaps, so
+// pages that no other page links to are also crawled. The sitemaps are
+// the given ones and the ones on the Sitemap lines of the entrypoint
+// robots.txt, if there is none /sitemap.xml of the entrypoint is used.
+// Sitemap indexes and gzipped sitemaps are supported.
+//
+// URLs found on sitemaps have depth one, as if they were linked by the
+// entrypoint, and are sent as results with the sitemap as their parent
+// and Result.FromSitemap set.
+func WithSitemaps(sitemaps ...url.URL) Option {
+	return func(c *Config) {
+		c.Sitemaps = true
+		c.SitemapURLs = sitemaps
+	}
+}
+
+// WithRetries retries the failed fetches of crawled URLs as decided by
+// the given policy, like DefaultRetryPolicy. By default they are not
+// retried, so a transient failure loses all the URLs found after it.
+func WithRetries(policy RetryPolicy) Option {
+	return func(c *Config) {
+		c.Retry = policy
+	}
+}
+
+// WithHeader adds a header sent on each request to URLs in the crawling
+// scope, it can be used many times. Like cookies and credentials, headers
+// are never sent to URLs out of the scope, even when following redirects.
+func WithHeader(name string, value string) Option {
+	return func(c *Config) {
+		if c.Headers == nil {
+			c.Headers = http.Header{}
+		}
+		c.Headers.Add(name, value)
+	}
+}
+
+// WithCookieJar keeps the cookies set by URLs in the crawling scope on
+// the given jar and sends them on requests to URLs in the scope. Use
+// LoadCookies to create a jar with the cookies of a cookies.txt file,
+// like the cookies of a logged in session.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(c *Config) {
+		c.CookieJar = jar
+	}
+}
+
+// WithBasicAuth authenticates the requests to URLs in the crawling
+// scope with the given username and password, using basic authentication.
+func WithBasicAuth(username string, password string) Option {
+	return func(c *Config) {
+		c.Credentials = Credentials{Username: username, Password: password}
+	}
+}
+
+// WithBearerToken authenticates the requests to URLs in the
+// crawling scope with the given bearer token.
+func WithBearerToken(token string) Option {
+	return func(c *Config) {
+		c.Credentials = Credentials{BearerToken: token}
+	}
+}
+
+// WithContentTypeCheck enables checking the Content-Type of the
+// responses before parsing them. Only HTML responses are parsed, or the
+// ones without a Content-Type, the other ones are not even read and are
+// sent as page results of leaf pages, with their content type and size.
+// By default all responses are parsed searching for links.
+func WithContentTypeCheck() Option {
+	return func(c *Config) {
+		c.CheckContentType = true
+	}
+}
+
+// WithHeadProbe enables making a HEAD request before fetching each URL,
+// checking its Content-Type and Content-Length, so large files that are
+// not parsed, like videos and PDFs, are never downloaded. It implies
+// WithContentTypeCheck. If the HEAD request fails the URL is fetched.
+func WithHeadProbe() Option {
+	return func(c *Config) {
+		c.CheckContentType = true
+		c.HeadProbe = true
+	}
+}
+
+// WithMaxBodySize limits how many bytes are read of each response. Pages
+// larger than the limit are not parsed and are sent as page results of
+// leaf pages, sitemaps larger than the limit fail with ErrBodyTooLarge.
+// The limit is checked against the Content-Length, when there is one,
+// and enforced while reading the body, since it may be missing or wrong.
+// A size of zero (the default) means no limit.
+func WithMaxBodySize(size int64) Option {
+	return func(c *Config) {
+		c.MaxBodySize = size
+	}
+}
+
+func newConfig(opts []Option) Config {
+	cfg := Config{
+		Concurrency:    DefaultConcurrency,
+		RequestTimeout: DefaultRequestTimeout,
+		HTTPClient:     &http.Client{},
+		UserAgent:      DefaultUserAgent,
+		RateBurst:      1,
+		FollowKinds:    []parser.LinkKind{parser.PageLink},
+		Normalizer:     NewDefaultNormalizer(),
+		Scope:          SameHostScope,
+		Storage:        MemoryStorage,
+		Strategy:       BreadthFirst,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+func (c Config) validate() error {
+	if c.Concurrency == 0 {
+		return errors.New("concurrency level must be greater than zero")
+	}
+	if c.ExternalLinks && c.ExternalConcurrency == 0 {
+		return errors.New("external links concurrency level must be greater than zero")
+	}
+	return nil
+}
+
+func (c Config) normalize(u url.URL) url.URL {
+	if c.Normalizer == nil {
+		return u
+	}
+	return c.Normalizer.Normalize(u)
+}
+
+func (c Config) rateInterval() time.Duration {
+	if c.RateLimit <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / c.RateLimit)
+}
@@ -0,0 +1,246 @@
+package crawler_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestCrawlerWithDefaults(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	const wantCrawlingErrs = 3
+
+	results, errs := crawler.New().Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, fakesiteResults(entrypoint), wantCrawlingErrs)
+}
+
+func TestCrawlerFailsToRunIfConcurrencyIsZero(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/emptysite")
+	defer teardown()
+
+	const wantErrs = 1
+
+	results, errs := crawler.New(crawler.WithConcurrency(0)).Run(
+		context.Background(),
+		entrypoint,
+	)
+	checkResults(t, results, errs, []crawler.Result{}, wantErrs)
+}
+
+func TestCrawlerWithHTTPClient(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	const wantCrawlingErrs = 3
+	// WHY: robots.txt + 12 pages + /dir being redirected to /dir/
+	const wantRequests = 14
+
+	transport := &countingTransport{}
+	client := &http.Client{Transport: transport}
+
+	results, errs := crawler.New(crawler.WithHTTPClient(client)).Run(
+		context.Background(),
+		entrypoint,
+	)
+	checkResults(t, results, errs, fakesiteResults(entrypoint), wantCrawlingErrs)
+
+	if transport.count() != wantRequests {
+		t.Fatalf("want[%d] requests on custom client, got[%d]", wantRequests, transport.count())
+	}
+}
+
+func TestCrawlerWithLinkFilter(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	const wantCrawlingErrs = 0
+
+	onlyHTML := func(r crawler.Result) bool {
+		return strings.HasSuffix(r.Link.Path, ".html") &&
+			!strings.HasPrefix(r.Link.Path, "/wont")
+	}
+	notFinal := func(r crawler.Result) bool {
+		return r.Link.Path != "/final.html"
+	}
+
+	results, errs := crawler.New(
+		crawler.WithLinkFilter(onlyHTML),
+		crawler.WithLinkFilter(notFinal),
+	).Run(context.Background(), entrypoint)
+
+	checkResults(t, results, errs, []crawler.Result{
+		result(entrypoint, "", "/info.html"),
+		result(entrypoint, "", "/nesting/info.html"),
+		result(entrypoint, "/info.html", "/cycle.html"),
+		result(entrypoint, "/cycle.html", "/info.html"),
+		result(entrypoint, "/nesting/info.html", "/cycle.html"),
+	}, wantCrawlingErrs)
+}
+
+func TestCrawlerWithMaxDepth(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	t.Run("Depth1", func(t *testing.T) {
+		const wantCrawlingErrs = 3
+
+		results, errs := crawler.New(crawler.WithMaxDepth(1)).Run(
+			context.Background(),
+			entrypoint,
+		)
+
+		checkResults(t, results, errs, []crawler.Result{
+			result(entrypoint, "", "/info.html"),
+			result(entrypoint, "", "/nesting/info.html"),
+			result(entrypoint, "", "/dir"),
+			result(entrypoint, "", "/wontExist.html"),
+			result(entrypoint, "", "/wont/exist/page.html"),
+			result(entrypoint, "", "/wont/exist2"),
+			result(entrypoint, "/info.html", "/cycle.html"),
+			result(entrypoint, "/info.html", "/final.html"),
+			result(entrypoint, "/nesting/info.html", "/cycle.html"),
+			result(entrypoint, "/nesting/info.html", "/final.html"),
+			result(entrypoint, "/dir/", "/dir/page1.html"),
+			result(entrypoint, "/dir/", "/dir/page2.html"),
+			result(entrypoint, "/dir/", "/dir/page3.txt"),
+			redirectResult(entrypoint, "/dir", "/dir/", http.StatusMovedPermanently),
+		}, wantCrawlingErrs)
+	})
+
+	t.Run("Depth2", func(t *testing.T) {
+		const wantCrawlingErrs = 3
+
+		results, errs := crawler.New(crawler.WithMaxDepth(2)).Run(
+			context.Background(),
+			entrypoint,
+		)
+
+		checkResults(t, results, errs, fakesiteResults(entrypoint), wantCrawlingErrs)
+	})
+}
+
+func TestCrawlerWithMaxPages(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	t.Run("OnlyEntrypoint", func(t *testing.T) {
+		const wantCrawlingErrs = 0
+
+		results, errs := crawler.New(crawler.WithMaxPages(1)).Run(
+			context.Background(),
+			entrypoint,
+		)
+
+		checkResults(t, results, errs, []crawler.Result{
+			result(entrypoint, "", "/info.html"),
+			result(entrypoint, "", "/nesting/info.html"),
+			result(entrypoint, "", "/dir"),
+			result(entrypoint, "", "/wontExist.html"),
+			result(entrypoint, "", "/wont/exist/page.html"),
+			result(entrypoint, "", "/wont/exist2"),
+		}, wantCrawlingErrs)
+	})
+
+	t.Run("FourPages", func(t *testing.T) {
+		const wantCrawlingErrs = 0
+
+		results, errs := crawler.New(crawler.WithMaxPages(4)).Run(
+			context.Background(),
+			entrypoint,
+		)
+
+		checkResults(t, results, errs, []crawler.Result{
+			result(entrypoint, "", "/info.html"),
+			result(entrypoint, "", "/nesting/info.html"),
+			result(entrypoint, "", "/dir"),
+			result(entrypoint, "", "/wontExist.html"),
+			result(entrypoint, "", "/wont/exist/page.html"),
+			result(entrypoint, "", "/wont/exist2"),
+			result(entrypoint, "/info.html", "/cycle.html"),
+			result(entrypoint, "/info.html", "/final.html"),
+			result(entrypoint, "/nesting/info.html", "/cycle.html"),
+			result(entrypoint, "/nesting/info.html", "/final.html"),
+			result(entrypoint, "/dir/", "/dir/page1.html"),
+			result(entrypoint, "/dir/", "/dir/page2.html"),
+			result(entrypoint, "/dir/", "/dir/page3.txt"),
+			redirectResult(entrypoint, "/dir", "/dir/", http.StatusMovedPermanently),
+		}, wantCrawlingErrs)
+	})
+
+	t.Run("MoreThanAvailable", func(t *testing.T) {
+		const wantCrawlingErrs = 3
+
+		results, errs := crawler.New(crawler.WithMaxPages(100)).Run(
+			context.Background(),
+			entrypoint,
+		)
+
+		checkResults(t, results, errs, fakesiteResults(entrypoint), wantCrawlingErrs)
+	})
+}
+
+func TestCrawlerWithHooks(t *testing.T) {
+	const header = "X-Crawler-Test"
+
+	files := http.FileServer(http.Dir("./testdata/fakesite"))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(header) == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+	entrypoint, teardown := newServer(t, handler)
+	defer teardown()
+
+	const wantCrawlingErrs = 3
+
+	mu := sync.Mutex{}
+	responses := 0
+
+	results, errs := crawler.New(
+		crawler.WithRequestHook(func(r *http.Request) {
+			r.Header.Set(header, "hooked")
+		}),
+		crawler.WithResponseHook(func(r *http.Response) {
+			mu.Lock()
+			responses += 1
+			mu.Unlock()
+		}),
+	).Run(context.Background(), entrypoint)
+
+	checkResults(t, results, errs, fakesiteResults(entrypoint), wantCrawlingErrs)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// WHY: robots.txt + 12 pages, the redirect on /dir is transparent
+	const wantResponses = 13
+	if responses != wantResponses {
+		t.Fatalf("want[%d] responses on hook, got[%d]", wantResponses, responses)
+	}
+}
+
+type countingTransport struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.requests += 1
+	t.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (t *countingTransport) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.requests
+}
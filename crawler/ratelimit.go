@@ -0,0 +1,176 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxThrottledAttempts is how many times a request is made when
+	// the server keeps answering that the crawler must slow down.
+	maxThrottledAttempts = 3
+	// maxRetryAfter caps how long a host is backed off when the server
+	// asks for it using the Retry-After header.
+	maxRetryAfter = 5 * time.Minute
+)
+
+// hostLimiter is a per host rate limiter shared by all crawlers.
+// Each host has its own token bucket, implemented as a generic cell
+// rate algorithm, and can also be backed off until a given time.
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    uint
+	hosts    map[string]*hostBucket
+}
+
+type hostBucket struct {
+	interval time.Duration
+	burst    uint
+	// next is the theoretical arrival time of the next request
+	next         time.Time
+	blockedUntil time.Time
+}
+
+// newHostLimiter creates a limiter that allows one request per interval
+// on each host, with bursts of up to burst requests. An interval of zero
+// means that hosts are not rate limited, but they can still be backed off.
+func newHostLimiter(interval time.Duration, burst uint) *hostLimiter {
+	if burst == 0 {
+		burst = 1
+	}
+	return &hostLimiter{
+		interval: interval,
+		burst:    burst,
+		hosts:    map[string]*hostBucket{},
+	}
+}
+
+// setHostInterval slows down the rate limit of a specific host,
+// like when the host robots.txt has a Crawl-delay. The host keeps
+// the slowest interval and the smallest burst of both limits.
+func (l *hostLimiter) setHostInterval(host string, interval time.Duration, burst uint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if burst == 0 {
+		burst = 1
+	}
+	h := l.host(host)
+	if interval > h.interval {
+		h.interval = interval
+	}
+	if burst < h.burst {
+		h.burst = burst
+	}
+}
+
+// backoff blocks all requests to the given host until the given time.
+func (l *hostLimiter) backoff(host string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h := l.host(host)
+	if until.After(h.blockedUntil) {
+		h.blockedUntil = until
+	}
+}
+
+// wait blocks until a request to the given host is allowed
+// or the context is done.
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	delay := l.reserve(host, time.Now())
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (l *hostLimiter) reserve(host string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h := l.host(host)
+
+	start := now
+	if h.blockedUntil.After(start) {
+		start = h.blockedUntil
+	}
+
+	if h.interval <= 0 {
+		return start.Sub(now)
+	}
+
+	next := h.next
+	if next.Before(start) {
+		next = start
+	}
+
+	allowAt := next.Add(-time.Duration(h.burst-1) * h.interval)
+	if allowAt.Before(start) {
+		allowAt = start
+	}
+
+	h.next = next.Add(h.interval)
+	return allowAt.Sub(now)
+}
+
+func (l *hostLimiter) host(host string) *hostBucket {
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostBucket{interval: l.interval, burst: l.burst}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+// limitedTransport waits for the host rate limit before each
+// request, including the ones made when following redirects.
+type limitedTransport struct {
+	limiter *hostLimiter
+	next    http.RoundTripper
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// parseRetryAfter parses the Retry-After header, which may be
+// a delay in seconds or an HTTP date. It returns false if the
+// header is absent or invalid.
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	val := header.Get("Retry-After")
+	if val == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseUint(val, 10, 32); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	date, err := http.ParseTime(val)
+	if err != nil {
+		return 0, false
+	}
+
+	delay := date.Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, true
+}
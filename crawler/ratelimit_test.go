@@ -0,0 +1,190 @@
+package crawler_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestCrawlingRespectsRateLimit(t *testing.T) {
+	const rate = 50
+	const burst = 1
+	const interval = time.Second / rate
+
+	recorder := &requestRecorder{}
+	handler := recorder.wrap(http.FileServer(http.Dir("./testdata/fakesite")))
+	entrypoint, teardown := newServer(t, handler)
+	defer teardown()
+
+	crawlAndDrain(t, entrypoint, crawler.WithRateLimit(rate, burst))
+
+	recorder.checkMinInterval(t, interval)
+}
+
+func TestCrawlingRespectsCrawlDelay(t *testing.T) {
+	const crawlDelay = 50 * time.Millisecond
+	const rate = 1000
+	const burst = 100
+
+	recorder := &requestRecorder{}
+	files := http.FileServer(http.Dir("./testdata/fakesite"))
+	handler := recorder.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nCrawl-delay: 0.05\n"))
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	entrypoint, teardown := newServer(t, handler)
+	defer teardown()
+
+	crawlAndDrain(t, entrypoint, crawler.WithRateLimit(rate, burst))
+
+	recorder.checkMinInterval(t, crawlDelay)
+}
+
+func TestCrawlingRespectsRateLimitSlowerThanCrawlDelay(t *testing.T) {
+	const rate = 20
+	const burst = 1
+	const interval = time.Second / rate
+
+	recorder := &requestRecorder{}
+	files := http.FileServer(http.Dir("./testdata/fakesite"))
+	handler := recorder.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nCrawl-delay: 0.001\n"))
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	entrypoint, teardown := newServer(t, handler)
+	defer teardown()
+
+	crawlAndDrain(t, entrypoint, crawler.WithRateLimit(rate, burst))
+
+	recorder.checkMinInterval(t, interval)
+}
+
+func TestCrawlingBacksOffOnRetryAfter(t *testing.T) {
+	entrypoint, teardown := setupThrottlingServer(t, "./testdata/fakesite", "/info.html")
+	defer teardown()
+
+	const concurrency = 5
+	const wantCrawlingErrs = 3
+	const timeout = time.Minute
+
+	start := time.Now()
+
+	testCrawler(
+		t,
+		context.Background(),
+		entrypoint,
+		concurrency,
+		timeout,
+		fakesiteResults(entrypoint),
+		wantCrawlingErrs,
+	)
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected host to be backed off for 1s, crawling took[%s]", elapsed)
+	}
+}
+
+func crawlAndDrain(t *testing.T, entrypoint url.URL, opts ...crawler.Option) {
+	t.Helper()
+
+	const concurrency = 10
+	const timeout = time.Minute
+
+	results, errs := crawler.Start(
+		context.Background(),
+		entrypoint,
+		concurrency,
+		timeout,
+		opts...,
+	)
+
+	go func() {
+		for range errs {
+		}
+	}()
+
+	for range results {
+	}
+}
+
+// setupThrottlingServer serves files from dir, but the first
+// request to the throttled path gets a 429 with a Retry-After.
+func setupThrottlingServer(t *testing.T, dir string, throttled string) (url.URL, func()) {
+	files := http.FileServer(http.Dir(dir))
+	mu := sync.Mutex{}
+	throttle := true
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		shouldThrottle := throttle && r.URL.Path == throttled
+		if shouldThrottle {
+			throttle = false
+		}
+		mu.Unlock()
+
+		if shouldThrottle {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+
+	return newServer(t, handler)
+}
+
+type requestRecorder struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (r *requestRecorder) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/robots.txt" {
+			r.mu.Lock()
+			r.times = append(r.times, time.Now())
+			r.mu.Unlock()
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+func (r *requestRecorder) checkMinInterval(t *testing.T, interval time.Duration) {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.times) < 2 {
+		t.Fatalf("expected multiple requests, got[%d]", len(r.times))
+	}
+
+	// WHY: requests are recorded when they arrive, not when they are sent,
+	//      so the interval between two specific requests may vary. Checking
+	//      against the first request with some tolerance avoids flaky tests.
+	tolerance := interval / 2
+	first := r.times[0]
+
+	for i := 1; i < len(r.times); i++ {
+		got := r.times[i].Sub(first)
+		want := time.Duration(i) * interval
+		if got < want-tolerance {
+			t.Errorf(
+				"request[%d] arrived after[%s], expected at least[%s]",
+				i,
+				got,
+				want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/katcipis/crawler/parser"
+)
+
+// maxRedirects is how many redirects are followed when fetching an URL
+const maxRedirects = 10
+
+var (
+	// ErrRedirectLoop is the cause of the failure to fetch an URL
+	// that redirects to an URL already on its redirect chain.
+	ErrRedirectLoop = errors.New("redirect loop")
+	// ErrTooManyRedirects is the cause of the failure to fetch
+	// an URL with a redirect chain longer than 10 hops.
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// Redirect is a hop of the redirect chain of a crawled URL
+type Redirect struct {
+	// URL is the URL that was redirected
+	URL url.URL
+	// StatusCode is the status code of the redirect, like 301
+	StatusCode int
+	// Location is the URL the request was redirected to
+	Location url.URL
+}
+
+// redirectsKey is the context key of the redirects
+// chain where the redirects of a request are recorded.
+type redirectsKey struct{}
+
+func withRedirects(ctx context.Context, redirects *[]Redirect) context.Context {
+	return context.WithValue(ctx, redirectsKey{}, redirects)
+}
+
+// withoutRedirects returns a context where the redirects of requests
+// are not recorded, for requests made while checking a redirect.
+func withoutRedirects(ctx context.Context) context.Context {
+	return context.WithValue(ctx, redirectsKey{}, nil)
+}
+
+// newCheckRedirect records the redirects of requests with a redirects
+// chain on their context and stops following them when they lead out
+// of the crawling scope, or to URLs disallowed by the robots rules on
+// their context, so the URL where they were stopped is not crawled.
+// Redirect loops and too long chains fail on any request, otherwise
+// the check of the given client is used, if any.
+func newCheckRedirect(
+	cfg Config,
+	check func(*http.Request, []*http.Request) error,
+) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		redirects, recording := req.Context().Value(redirectsKey{}).(*[]Redirect)
+		if recording {
+			*redirects = append(*redirects, Redirect{
+				URL:        *via[len(via)-1].URL,
+				StatusCode: req.Response.StatusCode,
+				Location:   *req.URL,
+			})
+		}
+
+		for _, prev := range via {
+			if prev.URL.String() == req.URL.String() {
+				return ErrRedirectLoop
+			}
+		}
+		if len(via) >= maxRedirects {
+			return ErrTooManyRedirects
+		}
+
+		entrypoint, ok := req.Context().Value(entrypointKey{}).(url.URL)
+		if recording && ok && !cfg.Scope.Contains(entrypoint, *req.URL) {
+			return http.ErrUseLastResponse
+		}
+
+		// WHY: The redirect is recorded, so the scheduler filters
+		//      it by the robots rules and reports it as skipped.
+		robots, ok := req.Context().Value(robotsKey{}).(*hostsRobots)
+		if recording && ok && !robots.allowed(withoutRedirects(req.Context()), *req.URL) {
+			return http.ErrUseLastResponse
+		}
+
+		if check != nil {
+			return check(req, via)
+		}
+		return nil
+	}
+}
+
+// redirectedURL returns the URL a crawled job was redirected to, which
+// is the job URL if it was not redirected or if the redirects were not
+// followed until the end, because of a redirect loop, a too long chain
+// or because they lead out of the crawling scope. The URL redirected to
+// may have failed to be fetched.
+func redirectedURL(r crawlResult) url.URL {
+	if r.page == nil || len(r.page.Redirects) == 0 ||
+		redirectsFailed(r.page.Err) || isRedirect(r.page.StatusCode) {
+		return r.job.url
+	}
+	return r.page.Redirects[len(r.page.Redirects)-1].Location
+}
+
+// redirectsFailed returns true if the given error is a failure
+// to follow redirects, instead of a failure of the URL redirected to.
+func redirectsFailed(err error) bool {
+	return errors.Is(err, ErrRedirectLoop) || errors.Is(err, ErrTooManyRedirects)
+}
+
+// redirectResults creates a redirect result for each hop of the redirect
+// chain of a crawled job, the last one may be out of the crawling scope
+// if following the redirects was stopped on it.
+func redirectResults(r crawlResult) []Result {
+	results := []Result{}
+	if r.page == nil {
+		return results
+	}
+
+	for _, redirect := range r.page.Redirects {
+		results = append(results, Result{
+			Parent:         redirect.URL,
+			Link:           redirect.Location,
+			Kind:           parser.RedirectLink,
+			RedirectStatus: redirect.StatusCode,
+		})
+	}
+	return results
+}
+
+// filterByRedirect sends the results of a page that was redirected as
+// results of the URL it was redirected to, marking it as seen so it is
+// not crawled again. If the URL was already seen the results are
+// discarded, since they are results of a page already crawled, and
+// false is returned.
+func filterByRedirect(
+	r crawlResult,
+	results []Result,
+	filterByUniqueness func([]Result) ([]Result, error),
+) ([]Result, bool, error) {
+	redirected := redirectedURL(r)
+	if redirected.String() == r.job.url.String() {
+		return results, true, nil
+	}
+
+	unique, err := filterByUniqueness([]Result{{Link: redirected}})
+	if err != nil || len(unique) == 0 {
+		return nil, false, err
+	}
+
+	for i := range results {
+		results[i].Parent = redirected
+	}
+	return results, true, nil
+}
+
+// redirectedPageResults splits the page of a redirected job on the page
+// result of the job URL, with the status of the first redirect, and the
+// page result of the URL it was redirected to, with its own status and
+// error, if it failed. The second one is the result of the last redirect,
+// with its status, and it is nil if the redirects were not followed until
+// the end, because they failed or lead out of the crawling scope, or if
+// the URL it was redirected to was already crawled, when fresh is false.
+func redirectedPageResults(r crawlResult, fresh bool) (Result, *Result) {
+	page := pageResult(r)
+	if len(r.page.Redirects) == 0 {
+		return page, nil
+	}
+
+	final := *page.Page
+	redirects := r.page.Redirects
+	last := redirects[len(redirects)-1]
+
+	first := &Page{
+		Depth:      page.Page.Depth,
+		StatusCode: redirects[0].StatusCode,
+		Latency:    page.Page.Latency,
+		Err:        page.Page.Err,
+		Redirects:  redirects,
+	}
+	page.Page = first
+
+	if !fresh || redirectsFailed(final.Err) || isRedirect(final.StatusCode) {
+		return page, nil
+	}
+
+	final.Redirects = nil
+	return page, &Result{
+		Parent:         last.URL,
+		Link:           last.Location,
+		Kind:           parser.RedirectLink,
+		FromSitemap:    page.FromSitemap,
+		RedirectStatus: last.StatusCode,
+		Page:           &final,
+	}
+}
+
+func isRedirect(status int) bool {
+	return status >= 300 && status < 400
+}
+
+// FormatAsRedirectsReport will drain the given Result channel and write
+// on the given writer a report of the redirect chains with more than one
+// hop and of the redirect loops, sorted by URL, like:
+//
+//	http://example.com/old: 2 redirects
+//	  301 http://example.com/old -> http://example.com/older
+//	  302 http://example.com/older -> http://example.com/new
+//
+// It requires page results, since they have the redirect chains.
+func FormatAsRedirectsReport(res <-chan Result, w io.Writer) error {
+	chains := map[string]*Page{}
+
+	for r := range res {
+		if r.Page == nil || len(r.Page.Redirects) == 0 {
+			continue
+		}
+		if len(r.Page.Redirects) > 1 || errors.Is(r.Page.Err, ErrRedirectLoop) {
+			chains[r.Link.String()] = r.Page
+		}
+	}
+
+	links := []string{}
+	for link := range chains {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+
+	for _, link := range links {
+		page := chains[link]
+
+		summary := fmt.Sprintf("%d redirects", len(page.Redirects))
+		if errors.Is(page.Err, ErrRedirectLoop) {
+			summary = "redirect loop"
+		}
+
+		report := []string{fmt.Sprintf("%s: %s", link, summary)}
+		for _, redirect := range page.Redirects {
+			report = append(report, fmt.Sprintf("  %d %s -> %s",
+				redirect.StatusCode, redirect.URL.String(), redirect.Location.String()))
+		}
+
+		_, err := w.Write([]byte(strings.Join(report, "\n") + "\n"))
+		if err != nil {
+			return fmt.Errorf("redirects formatter: failed to write result: %s", err)
+		}
+	}
+
+	return nil
+}
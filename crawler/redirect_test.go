@@ -0,0 +1,352 @@
+package crawler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+	"github.com/katcipis/crawler/parser"
+)
+
+func TestCrawlingRedirects(t *testing.T) {
+	var mutex sync.Mutex
+	checked := []string{}
+
+	external, teardownExternal := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		checked = append(checked, r.URL.Path)
+		mutex.Unlock()
+	}))
+	defer teardownExternal()
+
+	entrypoint, teardown := setupRedirectServer(t, external)
+	defer teardown()
+
+	want := []crawler.Result{
+		result(entrypoint, "", "/old"),
+		result(entrypoint, "", "/moved"),
+		result(entrypoint, "", "/loop"),
+		result(entrypoint, "", "/out"),
+		redirectResult(entrypoint, "/old", "/older", http.StatusMovedPermanently),
+		redirectResult(entrypoint, "/older", "/new", http.StatusFound),
+		redirectResult(entrypoint, "/moved", "/new", http.StatusMovedPermanently),
+		redirectResult(entrypoint, "/loop", "/loop2", http.StatusFound),
+		redirectResult(entrypoint, "/loop2", "/loop", http.StatusFound),
+		result(entrypoint, "/new", "/a"),
+		{
+			Parent:         url.URL{Scheme: entrypoint.Scheme, Host: entrypoint.Host, Path: "/out"},
+			Link:           url.URL{Scheme: external.Scheme, Host: external.Host, Path: "/landing"},
+			Kind:           parser.RedirectLink,
+			External:       true,
+			RedirectStatus: http.StatusFound,
+		},
+	}
+
+	results, errs := crawler.New(
+		crawler.WithoutRobots(),
+		crawler.WithExternalLinks(1),
+	).Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, want, 1)
+
+	if len(checked) != 1 || checked[0] != "/landing" {
+		t.Fatalf("want only the redirect out of the scope checked, got %v", checked)
+	}
+}
+
+func TestCrawlingRedirectsOutOfScopeAreDiscarded(t *testing.T) {
+	external, teardownExternal := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request out of the scope: %s", r.URL.Path)
+	}))
+	defer teardownExternal()
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/out"></a>`)
+		case "/out":
+			http.Redirect(w, r, external.String()+"/landing", http.StatusFound)
+		}
+	}))
+	defer teardown()
+
+	results, errs := crawler.New(crawler.WithoutRobots()).Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, []crawler.Result{result(entrypoint, "", "/out")}, 0)
+}
+
+func TestCrawlingRedirectLoops(t *testing.T) {
+	entrypoint, teardown := setupRedirectServer(t, url.URL{Scheme: "http", Host: "external.test"})
+	defer teardown()
+
+	results, errs := crawler.New(crawler.WithoutRobots()).Run(context.Background(), entrypoint)
+	go func() {
+		for range results {
+		}
+	}()
+
+	loop := entrypoint
+	loop.Path = "/loop"
+
+	gotErrs := 0
+	for err := range errs {
+		gotErrs++
+
+		var fetchErr *crawler.FetchError
+		if !errors.As(err, &fetchErr) {
+			t.Fatalf("want *crawler.FetchError, got %v", err)
+		}
+		if fetchErr.Phase != crawler.RedirectPhase || fetchErr.URL != loop {
+			t.Errorf("want redirect failure of %s, got %v", loop.String(), err)
+		}
+		if !errors.Is(err, crawler.ErrRedirectLoop) {
+			t.Errorf("want redirect loop error, got %v", err)
+		}
+	}
+
+	if gotErrs != 1 {
+		t.Fatalf("want 1 error, got %d", gotErrs)
+	}
+}
+
+func TestCrawlingRedirectsReport(t *testing.T) {
+	entrypoint, teardown := setupRedirectServer(t, url.URL{Scheme: "http", Host: "external.test"})
+	defer teardown()
+
+	results, errs := crawler.New(
+		crawler.WithoutRobots(),
+		crawler.WithPageResults(),
+	).Run(context.Background(), entrypoint)
+	go func() {
+		for range errs {
+		}
+	}()
+
+	got := &bytes.Buffer{}
+	fatalerr(t, crawler.FormatAsRedirectsReport(results, got), "formatting redirects")
+
+	ep := entrypoint.String()
+	want := fmt.Sprintf("%[1]s/loop: redirect loop\n"+
+		"  302 %[1]s/loop -> %[1]s/loop2\n"+
+		"  302 %[1]s/loop2 -> %[1]s/loop\n"+
+		"%[1]s/old: 2 redirects\n"+
+		"  301 %[1]s/old -> %[1]s/older\n"+
+		"  302 %[1]s/older -> %[1]s/new\n", ep)
+
+	if want != got.String() {
+		t.Fatalf("want:[%s] != got[%s]", want, got.String())
+	}
+}
+
+func TestCrawlingRedirectsPageResults(t *testing.T) {
+	entrypoint, teardown := setupRedirectServer(t, url.URL{Scheme: "http", Host: "external.test"})
+	defer teardown()
+
+	results, errs := crawler.New(
+		crawler.WithoutRobots(),
+		crawler.WithPageResults(),
+		crawler.WithConcurrency(1),
+	).Run(context.Background(), entrypoint)
+
+	pages := map[string]crawler.Result{}
+	for _, res := range collectResults(results, errs) {
+		if res.Page != nil {
+			pages[res.Link.Path] = res
+		}
+	}
+
+	old := pages["/old"]
+	if old.Page.StatusCode != http.StatusMovedPermanently || len(old.Page.Redirects) != 2 {
+		t.Errorf("want /old page with status 301 and 2 redirects, got %+v", old.Page)
+	}
+
+	redirected, ok := pages["/new"]
+	if !ok {
+		t.Fatalf("want page result of the redirected URL, got %v", pages)
+	}
+	if redirected.Kind != parser.RedirectLink || redirected.Parent.Path != "/older" {
+		t.Errorf("want /new page redirected from /older, got %v", redirected)
+	}
+	if redirected.Page.StatusCode != http.StatusOK || len(redirected.Page.Redirects) != 0 {
+		t.Errorf("want /new page with status 200 and no redirects, got %+v", redirected.Page)
+	}
+}
+
+func TestCrawlingRedirectsEndingInErrors(t *testing.T) {
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/old"></a>`)
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer teardown()
+
+	results, errs := crawler.New(
+		crawler.WithoutRobots(),
+		crawler.WithPageResults(),
+	).Run(context.Background(), entrypoint)
+
+	gotErrs := []error{}
+	drainedErrs := make(chan struct{})
+	go func() {
+		for err := range errs {
+			gotErrs = append(gotErrs, err)
+		}
+		close(drainedErrs)
+	}()
+
+	got := &bytes.Buffer{}
+	fatalerr(t, crawler.FormatAsBrokenLinksReport(results, got), "formatting broken links")
+	<-drainedErrs
+
+	ep := entrypoint.String()
+	want := fmt.Sprintf("%[1]s/new: 404 Not Found\n"+
+		"  found on %[1]s/old\n"+
+		"%[1]s/old: 301 Moved Permanently to %[1]s/new: 404 Not Found\n"+
+		"  found on %[1]s\n", ep)
+
+	if want != got.String() {
+		t.Errorf("want:[%s] != got[%s]", want, got.String())
+	}
+
+	if len(gotErrs) != 1 {
+		t.Fatalf("want 1 error, got %v", gotErrs)
+	}
+
+	var fetchErr *crawler.FetchError
+	if !errors.As(gotErrs[0], &fetchErr) {
+		t.Fatalf("want *crawler.FetchError, got %v", gotErrs[0])
+	}
+	if fetchErr.URL.Path != "/new" || fetchErr.Parent.Path != "/old" || fetchErr.StatusCode != http.StatusNotFound {
+		t.Errorf("want 404 failure of /new found on /old, got %v", fetchErr)
+	}
+}
+
+func TestCrawlingRedirectsSendTheLastRedirectOnce(t *testing.T) {
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/dir"></a>`)
+		case "/dir":
+			http.Redirect(w, r, "/dir/", http.StatusMovedPermanently)
+		case "/dir/":
+			fmt.Fprint(w, `<a href="/dir/page"></a>`)
+		}
+	}))
+	defer teardown()
+
+	results, errs := crawler.New(
+		crawler.WithoutRobots(),
+		crawler.WithPageResults(),
+	).Run(context.Background(), entrypoint)
+	go func() {
+		for range errs {
+		}
+	}()
+
+	got := &bytes.Buffer{}
+	fatalerr(t, crawler.FormatAsJSONLines(results, got), "formatting JSON Lines")
+
+	dir := entrypoint
+	dir.Path = "/dir"
+	redirected := entrypoint
+	redirected.Path = "/dir/"
+
+	records := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(got.String()), "\n") {
+		record := map[string]interface{}{}
+		fatalerr(t, json.Unmarshal([]byte(line), &record), "parsing JSON Lines")
+		if record["parent"] == dir.String() && record["link"] == redirected.String() {
+			records = append(records, record)
+		}
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("want the last redirect sent once, got %v", records)
+	}
+	if records[0]["type"] != "page" || records[0]["kind"] != "redirect" || records[0]["depth"] != 1.0 {
+		t.Errorf("want the page of the last redirect at depth 1, got %v", records[0])
+	}
+}
+
+func TestRedirectsReportFormatterFailsOnWriteError(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	loop := result(entrypoint, "", "/loop")
+	loop.Page = &crawler.Page{
+		StatusCode: http.StatusFound,
+		Err:        crawler.ErrRedirectLoop,
+		Redirects: []crawler.Redirect{{
+			URL:        loop.Link,
+			StatusCode: http.StatusFound,
+			Location:   loop.Link,
+		}},
+	}
+
+	res := make(chan crawler.Result, 1)
+	res <- loop
+	close(res)
+
+	err := crawler.FormatAsRedirectsReport(res, &explodingWriter{failOnCall: 1})
+	if err == nil {
+		t.Fatal("expected error on failed write")
+	}
+}
+
+// setupRedirectServer creates a server with a two hops redirect chain,
+// /old -> /older -> /new, another redirect to /new from /moved, a redirect
+// loop, /loop -> /loop2 -> /loop, and a redirect to the given external URL.
+func setupRedirectServer(t *testing.T, external url.URL) (url.URL, func()) {
+	return newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/old"></a><a href="/moved"></a><a href="/loop"></a><a href="/out"></a>`)
+		case "/old":
+			http.Redirect(w, r, "/older", http.StatusMovedPermanently)
+		case "/older":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		case "/moved":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/new":
+			fmt.Fprint(w, `<a href="/a"></a>`)
+		case "/loop":
+			http.Redirect(w, r, "/loop2", http.StatusFound)
+		case "/loop2":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/out":
+			http.Redirect(w, r, external.String()+"/landing", http.StatusFound)
+		}
+	}))
+}
+
+func TestCrawlingRedirectsDisallowedByRobotsAreSkipped(t *testing.T) {
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /secret\n")
+		case "/":
+			fmt.Fprint(w, `<a href="/old"></a>`)
+		case "/old":
+			http.Redirect(w, r, "/secret", http.StatusMovedPermanently)
+		case "/secret":
+			t.Errorf("unexpected request disallowed by robots.txt: %s", r.URL.Path)
+			fmt.Fprint(w, `<a href="/s2"></a>`)
+		}
+	}))
+	defer teardown()
+
+	// WHY: The redirect from /old to /secret is reported as skipped
+	const wantSkippedURLs = 1
+
+	results, errs := crawler.New().Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, []crawler.Result{result(entrypoint, "", "/old")}, wantSkippedURLs)
+}
@@ -0,0 +1,183 @@
+package crawler
+
+import (
+	"container/heap"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy decides which failed fetches of crawled URLs are retried
+// and how long the crawler waits before each retry. URLs waiting to be
+// retried do not hold a crawler, other URLs are crawled meanwhile.
+//
+// Failures on the StatusPhase are retried when their status code is on
+// Statuses, failures on other phases are retried when their phase is on
+// Phases. The URLs are only reported as failed after the last attempt.
+type RetryPolicy struct {
+	// MaxAttempts is how many times an URL is fetched, including
+	// the first attempt. Zero or one disables retries.
+	MaxAttempts uint
+	// InitialBackoff is how long the crawler waits before the first
+	// retry, each retry after it waits twice as long as the previous one.
+	InitialBackoff time.Duration
+	// MaxBackoff caps how long the crawler waits before a retry,
+	// zero means no cap.
+	MaxBackoff time.Duration
+	// Jitter is the fraction, from 0 to 1, of each backoff that is
+	// randomized, so retries of many URLs are spread over time.
+	Jitter float64
+	// Statuses are the status codes that are retried
+	Statuses []int
+	// Phases are the phases, besides the StatusPhase, whose failures
+	// are retried. Like the TransportPhase, for network errors.
+	Phases []FetchPhase
+	// Budget is the maximum amount of retries of the whole crawling,
+	// zero means no limit. When it is exhausted failures are not retried.
+	Budget uint
+}
+
+// DefaultRetryPolicy retries network errors and 5xx status codes
+// that are usually transient up to three attempts.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Jitter:         0.5,
+	Statuses: []int{
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+	Phases: []FetchPhase{TransportPhase},
+}
+
+// retryable returns true if the given failure of an URL that already
+// failed the given amount of attempts, besides this one, must be retried.
+func (p RetryPolicy) retryable(failed uint, err error) bool {
+	if failed+1 >= p.MaxAttempts {
+		return false
+	}
+
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) {
+		return false
+	}
+
+	if fetchErr.Phase == StatusPhase {
+		for _, status := range p.Statuses {
+			if status == fetchErr.StatusCode {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, phase := range p.Phases {
+		if phase == fetchErr.Phase {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns how long to wait before the given retry, starting at one.
+func (p RetryPolicy) backoff(retry uint) time.Duration {
+	backoff := p.InitialBackoff
+	for i := uint(1); i < retry; i++ {
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	jitter := p.Jitter
+	if jitter <= 0 {
+		return backoff
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+	randomized := time.Duration(float64(backoff) * jitter * rand.Float64())
+	return backoff - randomized
+}
+
+// retryQueue has the jobs waiting to be retried,
+// ordered by when they must be retried.
+type retryQueue struct {
+	jobs  retryJobs
+	timer *time.Timer
+}
+
+type retryJob struct {
+	job job
+	at  time.Time
+}
+
+func (q *retryQueue) push(j job, at time.Time) {
+	heap.Push(&q.jobs, retryJob{job: j, at: at})
+}
+
+// pop returns a job whose retry time has come, if any
+func (q *retryQueue) pop(now time.Time) (job, bool) {
+	if len(q.jobs) == 0 || q.jobs[0].at.After(now) {
+		return job{}, false
+	}
+	return heap.Pop(&q.jobs).(retryJob).job, true
+}
+
+func (q *retryQueue) len() int {
+	return len(q.jobs)
+}
+
+// wake returns a channel that receives when the retry time
+// of the next job comes, nil if there are no jobs.
+func (q *retryQueue) wake(now time.Time) <-chan time.Time {
+	if len(q.jobs) == 0 {
+		return nil
+	}
+
+	wait := q.jobs[0].at.Sub(now)
+	if q.timer == nil {
+		q.timer = time.NewTimer(wait)
+	} else {
+		q.timer.Reset(wait)
+	}
+	return q.timer.C
+}
+
+func (q *retryQueue) stop() {
+	if q.timer != nil {
+		q.timer.Stop()
+	}
+}
+
+type retryJobs []retryJob
+
+func (r retryJobs) Len() int {
+	return len(r)
+}
+
+func (r retryJobs) Less(i, j int) bool {
+	return r[i].at.Before(r[j].at)
+}
+
+func (r retryJobs) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+func (r *retryJobs) Push(x interface{}) {
+	*r = append(*r, x.(retryJob))
+}
+
+func (r *retryJobs) Pop() interface{} {
+	old := *r
+	last := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,215 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestCrawlingRetries(t *testing.T) {
+	type tcase struct {
+		name         string
+		failures     map[string]int
+		status       int
+		policy       crawler.RetryPolicy
+		want         []string
+		wantRequests map[string]int
+		wantErrs     uint
+	}
+
+	policy := crawler.DefaultRetryPolicy
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+
+	budget := policy
+	budget.Budget = 1
+
+	cases := []tcase{
+		{
+			name:         "RecoversFromTransientFailures",
+			failures:     map[string]int{"/a": 2},
+			status:       http.StatusServiceUnavailable,
+			policy:       policy,
+			want:         []string{"/a", "/b", "/a/1"},
+			wantRequests: map[string]int{"/": 1, "/a": 3, "/b": 1, "/a/1": 1},
+		},
+		{
+			name:         "GivesUpAfterMaxAttempts",
+			failures:     map[string]int{"/a": 5},
+			status:       http.StatusBadGateway,
+			policy:       policy,
+			want:         []string{"/a", "/b"},
+			wantRequests: map[string]int{"/": 1, "/a": 3, "/b": 1},
+			wantErrs:     1,
+		},
+		{
+			name:         "DoesNotRetryOtherStatuses",
+			failures:     map[string]int{"/a": 1},
+			status:       http.StatusNotFound,
+			policy:       policy,
+			want:         []string{"/a", "/b"},
+			wantRequests: map[string]int{"/": 1, "/a": 1, "/b": 1},
+			wantErrs:     1,
+		},
+		{
+			name:         "RespectsBudget",
+			failures:     map[string]int{"/a": 5, "/b": 5},
+			status:       http.StatusInternalServerError,
+			policy:       budget,
+			want:         []string{"/a", "/b"},
+			wantRequests: map[string]int{"/": 1, "/a": 2, "/b": 1},
+			wantErrs:     2,
+		},
+		{
+			name:         "Disabled",
+			failures:     map[string]int{"/a": 1},
+			status:       http.StatusServiceUnavailable,
+			want:         []string{"/a", "/b"},
+			wantRequests: map[string]int{"/": 1, "/a": 1, "/b": 1},
+			wantErrs:     1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			requests := map[string]int{}
+
+			entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/robots.txt" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				mutex.Lock()
+				requests[r.URL.Path]++
+				attempt := requests[r.URL.Path]
+				mutex.Unlock()
+
+				if attempt <= c.failures[r.URL.Path] {
+					w.WriteHeader(c.status)
+					return
+				}
+
+				switch r.URL.Path {
+				case "/":
+					fmt.Fprint(w, `<a href="/a"></a><a href="/b"></a>`)
+				case "/a":
+					fmt.Fprint(w, `<a href="/a/1"></a>`)
+				}
+			}))
+			defer teardown()
+
+			want := []crawler.Result{}
+			for _, path := range c.want {
+				parent := ""
+				if path == "/a/1" {
+					parent = "/a"
+				}
+				want = append(want, result(entrypoint, parent, path))
+			}
+
+			// WHY: With a single crawler it is known which URL
+			//      gets the retries when the budget is exhausted.
+			results, errs := crawler.New(
+				crawler.WithConcurrency(1),
+				crawler.WithRetries(c.policy),
+			).Run(context.Background(), entrypoint)
+			checkResults(t, results, errs, want, c.wantErrs)
+
+			if !reflect.DeepEqual(c.wantRequests, requests) {
+				t.Fatalf("want requests %v, got %v", c.wantRequests, requests)
+			}
+		})
+	}
+}
+
+func TestCrawlingRetriesDoNotHoldCrawlers(t *testing.T) {
+	var mutex sync.Mutex
+	requests := []string{}
+	failed := false
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		mutex.Lock()
+		requests = append(requests, r.URL.Path)
+		fail := r.URL.Path == "/a" && !failed
+		if fail {
+			failed = true
+		}
+		mutex.Unlock()
+
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/a"></a><a href="/b"></a><a href="/c"></a>`)
+		}
+	}))
+	defer teardown()
+
+	policy := crawler.DefaultRetryPolicy
+	policy.InitialBackoff = 100 * time.Millisecond
+	policy.Jitter = 0
+
+	// WHY: With a single crawler the retry of /a only happens after
+	//      /b and /c are crawled if the crawler is free during the backoff.
+	results, errs := crawler.New(
+		crawler.WithConcurrency(1),
+		crawler.WithRetries(policy),
+	).Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, []crawler.Result{
+		result(entrypoint, "", "/a"),
+		result(entrypoint, "", "/b"),
+		result(entrypoint, "", "/c"),
+	}, 0)
+
+	want := []string{"/", "/a", "/b", "/c", "/a"}
+	if !reflect.DeepEqual(want, requests) {
+		t.Fatalf("want requests %v, got %v", want, requests)
+	}
+}
+
+func TestCrawlingRetriesAreSavedOnCheckpoints(t *testing.T) {
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/":
+			fmt.Fprint(w, `<a href="/a"></a>`)
+		}
+	}))
+	defer teardown()
+
+	policy := crawler.DefaultRetryPolicy
+	policy.InitialBackoff = time.Hour
+
+	checkpoint := t.TempDir() + "/checkpoint.json"
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	results, errs := crawler.New(
+		crawler.WithoutRobots(),
+		crawler.WithRetries(policy),
+		crawler.WithCheckpoints(checkpoint, 0),
+	).Run(ctx, entrypoint)
+	collectResults(results, errs)
+
+	cp, err := crawler.LoadCheckpoint(checkpoint)
+	fatalerr(t, err, "loading checkpoint")
+
+	if len(cp.Frontier) != 1 || cp.Frontier[0].URL != entrypoint.String()+"/a" {
+		t.Fatalf("want the URL waiting to be retried on the checkpoint frontier, got %+v", cp.Frontier)
+	}
+}
@@ -0,0 +1,283 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/katcipis/crawler/parser"
+)
+
+// SkipError is sent on the errors channel for each URL that
+// was found during the crawling but was not crawled. The Parent
+// is empty when the skipped URL is the entrypoint itself.
+type SkipError struct {
+	// Link is the skipped URL
+	Link url.URL
+	// Parent is the URL where the skipped Link was found
+	Parent url.URL
+	// Reason describes why the Link was skipped
+	Reason string
+}
+
+func (e *SkipError) Error() string {
+	if e.Parent.String() == "" {
+		return fmt.Sprintf("skipped url[%s]: %s", e.Link.String(), e.Reason)
+	}
+	return fmt.Sprintf(
+		"skipped url[%s] found on url[%s]: %s",
+		e.Link.String(),
+		e.Parent.String(),
+		e.Reason,
+	)
+}
+
+const (
+	robotsDisallowed  = "disallowed by robots.txt"
+	robotsUnreachable = "robots.txt unreachable"
+)
+
+// maxRobotsSize is how much of a robots.txt is parsed, the rest is
+// ignored, RFC 9309 requires parsing at least 500 KiB.
+const maxRobotsSize = 500 * 1024
+
+// fetchRobots fetches the robots.txt of the given URL host.
+// As specified on RFC 9309 any 4xx status code means that there
+// is no restriction, but if the robots.txt is unreachable (429, 5xx
+// or network errors) an error is returned and nothing should be
+// crawled. Only the first maxRobotsSize bytes of it are parsed.
+func fetchRobots(
+	ctx context.Context,
+	c *http.Client,
+	cfg Config,
+	u url.URL,
+) (parser.Robots, error) {
+	robotsURL := url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   "/robots.txt",
+	}
+
+	req, cancel, err := newRequest(ctx, cfg, http.MethodGet, robotsURL)
+	defer cancel()
+	if err != nil {
+		return parser.Robots{}, &FetchError{URL: robotsURL, Method: http.MethodGet, Phase: RequestPhase, Err: err}
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return parser.Robots{}, &FetchError{URL: robotsURL, Method: http.MethodGet, Phase: TransportPhase, Err: err}
+	}
+	defer res.Body.Close()
+
+	if cfg.Hooks.OnResponse != nil {
+		cfg.Hooks.OnResponse(res)
+	}
+
+	// WHY: Too many requests is not a missing robots.txt,
+	//      the server is just not able to answer it now.
+	if res.StatusCode >= 400 && res.StatusCode < 500 &&
+		res.StatusCode != http.StatusTooManyRequests {
+		return parser.Robots{}, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return parser.Robots{}, &FetchError{
+			URL:        robotsURL,
+			Method:     http.MethodGet,
+			Phase:      StatusPhase,
+			StatusCode: res.StatusCode,
+			Err:        errors.New(robotsUnreachable),
+		}
+	}
+
+	robots, err := parser.ParseRobots(io.LimitReader(res.Body, maxRobotsSize))
+	if err != nil {
+		return parser.Robots{}, &FetchError{
+			URL:        robotsURL,
+			Method:     http.MethodGet,
+			Phase:      ParsePhase,
+			StatusCode: res.StatusCode,
+			Err:        err,
+		}
+	}
+
+	return robots, nil
+}
+
+// hostsRobots are the robots rules of the hosts found while crawling an
+// entrypoint, kept by their origin since RFC 9309 applies a robots.txt
+// only to the scheme, host and port it was fetched from. They are shared by its scheduler, which filters the links
+// found, and by the crawlers, which fetch the robots.txt of new hosts and
+// check the redirects they follow, so they are safe for concurrent use.
+type hostsRobots struct {
+	client  *http.Client
+	cfg     Config
+	limiter *hostLimiter
+	errs    chan<- error
+
+	mutex   sync.Mutex
+	origins map[string]*parser.Robots
+}
+
+func newHostsRobots(
+	client *http.Client,
+	cfg Config,
+	limiter *hostLimiter,
+	entrypoint url.URL,
+	entrypointRobots parser.Robots,
+	errs chan<- error,
+) *hostsRobots {
+	return &hostsRobots{
+		client:  client,
+		cfg:     cfg,
+		limiter: limiter,
+		errs:    errs,
+		origins: map[string]*parser.Robots{robotsOrigin(entrypoint): &entrypointRobots},
+	}
+}
+
+// get returns the robots rules of the host of the given URL, nil if its
+// robots.txt is unreachable, or false if it was not fetched yet.
+func (h *hostsRobots) get(u url.URL) (*parser.Robots, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	robots, ok := h.origins[robotsOrigin(u)]
+	return robots, ok
+}
+
+// fetch fetches and keeps the robots rules of the host of the given URL.
+// If the robots.txt is unreachable the failure is reported on the errs
+// channel and nil is kept, unless the crawling was cancelled, then
+// nothing is kept. When it has a Crawl-delay it is set on the limiter.
+func (h *hostsRobots) fetch(ctx context.Context, u url.URL) *parser.Robots {
+	if robots, ok := h.get(u); ok {
+		return robots
+	}
+
+	robots, err := fetchRobots(ctx, h.client, h.cfg, u)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		h.errs <- err
+		h.set(u, nil)
+		return nil
+	}
+
+	if delay, ok := robots.CrawlDelay(h.cfg.UserAgent); ok {
+		h.limiter.setHostInterval(u.Host, delay, 1)
+	}
+	h.set(u, &robots)
+	return &robots
+}
+
+// allowed returns true if the given URL is allowed by the robots rules
+// of its host, which are fetched if needed. If the robots.txt of the
+// host is unreachable the URL is not allowed.
+func (h *hostsRobots) allowed(ctx context.Context, u url.URL) bool {
+	robots := h.fetch(ctx, u)
+	return robots != nil && robots.Allowed(h.cfg.UserAgent, u)
+}
+
+func (h *hostsRobots) set(u url.URL, robots *parser.Robots) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.origins[robotsOrigin(u)] = robots
+}
+
+// robotsOrigin returns the scheme and host of the given URL,
+// which share the same robots.txt.
+func robotsOrigin(u url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// robotsKey is the context key of the robots rules of the crawling
+// a request is part of, used to check the URLs it is redirected to.
+type robotsKey struct{}
+
+func withRobots(ctx context.Context, robots *hostsRobots) context.Context {
+	return context.WithValue(ctx, robotsKey{}, robots)
+}
+
+// robotsFilter filters out results with links disallowed by the robots
+// rules of their hosts. Each skipped link is reported only once on the
+// errs channel.
+//
+// The robots.txt of each host is fetched by a crawler when its first link
+// is found, so the scheduler is not blocked by it, meanwhile the links of
+// the host are held. If it is unreachable all links of the host are skipped.
+type robotsFilter struct {
+	cfg      Config
+	robots   *hostsRobots
+	errs     chan<- error
+	skipped  map[string]bool
+	fetching map[string]bool
+}
+
+func newRobotsFilter(cfg Config, robots *hostsRobots, errs chan<- error) *robotsFilter {
+	return &robotsFilter{
+		cfg:      cfg,
+		robots:   robots,
+		errs:     errs,
+		skipped:  map[string]bool{},
+		fetching: map[string]bool{},
+	}
+}
+
+// filter returns the results allowed by the robots rules of their hosts
+// and the results held because the robots.txt of their hosts was not
+// fetched yet, which must be filtered again after it is. It also returns
+// the URLs of the robots.txt that must be fetched, each one only once.
+func (f *robotsFilter) filter(results []Result) ([]Result, []Result, []url.URL) {
+	allowed := []Result{}
+	held := []Result{}
+	fetch := []url.URL{}
+
+	for _, res := range results {
+		robots, ok := f.robots.get(res.Link)
+		if !ok {
+			held = append(held, res)
+			if origin := robotsOrigin(res.Link); !f.fetching[origin] {
+				f.fetching[origin] = true
+				fetch = append(fetch, url.URL{
+					Scheme: res.Link.Scheme,
+					Host:   res.Link.Host,
+					Path:   "/robots.txt",
+				})
+			}
+			continue
+		}
+
+		reason := robotsDisallowed
+		if robots == nil {
+			reason = robotsUnreachable
+		} else if robots.Allowed(f.cfg.UserAgent, res.Link) {
+			allowed = append(allowed, res)
+			continue
+		}
+
+		linkstr := res.Link.String()
+		if !f.skipped[linkstr] {
+			f.skipped[linkstr] = true
+			f.errs <- &SkipError{
+				Link:   res.Link,
+				Parent: res.Parent,
+				Reason: reason,
+			}
+		}
+	}
+	return allowed, held, fetch
+}
+
+// fetched must be called when fetching the robots.txt of
+// the given origin is done, even if it failed.
+func (f *robotsFilter) fetched(origin string) {
+	delete(f.fetching, origin)
+}
@@ -0,0 +1,159 @@
+package crawler
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Scope decides which links are inside the crawling scope.
+// Links inside the scope are crawled, the ones outside of it
+// are external links. The entrypoint is always crawled.
+type Scope interface {
+	// Contains returns true if the link is inside the
+	// scope of the crawling started on the entrypoint.
+	Contains(entrypoint url.URL, link url.URL) bool
+}
+
+// ScopeFunc is an adapter to allow the use of ordinary functions as a Scope
+type ScopeFunc func(entrypoint url.URL, link url.URL) bool
+
+// Contains calls f(entrypoint, link)
+func (f ScopeFunc) Contains(entrypoint url.URL, link url.URL) bool {
+	return f(entrypoint, link)
+}
+
+// SameHostScope contains only links with the same host,
+// including the port, of the entrypoint. It is the default scope.
+var SameHostScope Scope = ScopeFunc(sameHost)
+
+// SameDomainScope contains links with the same registrable domain
+// of the entrypoint, also known as eTLD+1, like "example.com" for
+// "www.example.com". So all subdomains of the domain are included.
+// Ports are not taken into account. If the entrypoint host is an IP
+// address, or has no registrable domain, it works like SameHostScope.
+var SameDomainScope Scope = ScopeFunc(sameDomain)
+
+// ScopeRules is a Scope that restricts or extends a base scope.
+// A link is inside the scope when:
+//
+// - Its host is not on DenyHosts
+//
+// - It is on the Base scope or its host is on AllowHosts
+//
+// - Its path starts with one of IncludePaths, if there is any
+//
+// - Its path does not start with any of ExcludePaths
+//
+// - It matches one of the Include expressions, if there is any
+//
+// - It does not match any of the Exclude expressions
+//
+// Hosts match themselves and all their subdomains, so "example.com"
+// matches "example.com" and "docs.example.com". Expressions are
+// matched against the whole link, like "https://example.com/docs?q=1".
+type ScopeRules struct {
+	// Base is the scope extended and restricted by the rules,
+	// SameHostScope is used if nil.
+	Base Scope
+	// AllowHosts are hosts inside the scope besides the Base ones
+	AllowHosts []string
+	// DenyHosts are hosts that are never inside the scope
+	DenyHosts []string
+	// IncludePaths are path prefixes, like "/docs/", to restrict the scope
+	IncludePaths []string
+	// ExcludePaths are path prefixes excluded from the scope
+	ExcludePaths []string
+	// Include are expressions to restrict the scope
+	Include []*regexp.Regexp
+	// Exclude are expressions that exclude links from the scope
+	Exclude []*regexp.Regexp
+}
+
+// Contains returns true if the link is inside the scope defined by the rules
+func (r *ScopeRules) Contains(entrypoint url.URL, link url.URL) bool {
+	host := strings.ToLower(link.Hostname())
+	if matchesHost(r.DenyHosts, host) {
+		return false
+	}
+
+	base := r.Base
+	if base == nil {
+		base = SameHostScope
+	}
+	if !base.Contains(entrypoint, link) && !matchesHost(r.AllowHosts, host) {
+		return false
+	}
+
+	path := link.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if len(r.IncludePaths) > 0 && !hasAnyPrefix(path, r.IncludePaths) {
+		return false
+	}
+	if hasAnyPrefix(path, r.ExcludePaths) {
+		return false
+	}
+
+	linkstr := link.String()
+	if len(r.Include) > 0 && !matchesAny(linkstr, r.Include) {
+		return false
+	}
+	return !matchesAny(linkstr, r.Exclude)
+}
+
+func sameHost(entrypoint url.URL, link url.URL) bool {
+	return strings.EqualFold(link.Host, entrypoint.Host)
+}
+
+func sameDomain(entrypoint url.URL, link url.URL) bool {
+	domain, ok := registrableDomain(entrypoint.Hostname())
+	if !ok {
+		return sameHost(entrypoint, link)
+	}
+	linkDomain, ok := registrableDomain(link.Hostname())
+	return ok && linkDomain == domain
+}
+
+func registrableDomain(host string) (string, bool) {
+	if net.ParseIP(host) != nil {
+		return "", false
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(host))
+	if err != nil {
+		return "", false
+	}
+	return domain, true
+}
+
+func matchesHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(s string, exps []*regexp.Regexp) bool {
+	for _, exp := range exps {
+		if exp.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestScopes(t *testing.T) {
+	type tcase struct {
+		name       string
+		scope      crawler.Scope
+		entrypoint string
+		link       string
+		want       bool
+	}
+
+	cases := []tcase{
+		{
+			name:       "SameHost",
+			scope:      crawler.SameHostScope,
+			entrypoint: "http://example.com",
+			link:       "https://example.com/a",
+			want:       true,
+		},
+		{
+			name:       "SameHostIgnoresCase",
+			scope:      crawler.SameHostScope,
+			entrypoint: "http://example.com",
+			link:       "http://EXAMPLE.com/a",
+			want:       true,
+		},
+		{
+			name:       "SameHostSubdomain",
+			scope:      crawler.SameHostScope,
+			entrypoint: "http://example.com",
+			link:       "http://www.example.com/a",
+			want:       false,
+		},
+		{
+			name:       "SameHostOtherPort",
+			scope:      crawler.SameHostScope,
+			entrypoint: "http://example.com",
+			link:       "http://example.com:8080/a",
+			want:       false,
+		},
+		{
+			name:       "SameDomainSubdomain",
+			scope:      crawler.SameDomainScope,
+			entrypoint: "http://www.example.com",
+			link:       "http://docs.example.com/a",
+			want:       true,
+		},
+		{
+			name:       "SameDomainRoot",
+			scope:      crawler.SameDomainScope,
+			entrypoint: "http://www.example.com",
+			link:       "http://example.com:8080/a",
+			want:       true,
+		},
+		{
+			name:       "SameDomainOtherDomain",
+			scope:      crawler.SameDomainScope,
+			entrypoint: "http://www.example.com",
+			link:       "http://www.example.org/a",
+			want:       false,
+		},
+		{
+			name:       "SameDomainMultiLabelSuffix",
+			scope:      crawler.SameDomainScope,
+			entrypoint: "http://www.example.co.uk",
+			link:       "http://docs.example.co.uk",
+			want:       true,
+		},
+		{
+			name:       "SameDomainOtherDomainOnMultiLabelSuffix",
+			scope:      crawler.SameDomainScope,
+			entrypoint: "http://www.example.co.uk",
+			link:       "http://other.co.uk",
+			want:       false,
+		},
+		{
+			name:       "SameDomainIPFallsBackToSameHost",
+			scope:      crawler.SameDomainScope,
+			entrypoint: "http://127.0.0.1:8080",
+			link:       "http://127.0.0.1:8081",
+			want:       false,
+		},
+		{
+			name:       "RulesDefaultBase",
+			scope:      &crawler.ScopeRules{},
+			entrypoint: "http://example.com",
+			link:       "http://www.example.com/a",
+			want:       false,
+		},
+		{
+			name:       "RulesAllowHost",
+			scope:      &crawler.ScopeRules{AllowHosts: []string{"example.org"}},
+			entrypoint: "http://example.com",
+			link:       "http://docs.example.org/a",
+			want:       true,
+		},
+		{
+			name:       "RulesAllowHostDoesNotMatchSuffix",
+			scope:      &crawler.ScopeRules{AllowHosts: []string{"example.org"}},
+			entrypoint: "http://example.com",
+			link:       "http://otherexample.org/a",
+			want:       false,
+		},
+		{
+			name: "RulesDenyHost",
+			scope: &crawler.ScopeRules{
+				Base:      crawler.SameDomainScope,
+				DenyHosts: []string{"private.example.com"},
+			},
+			entrypoint: "http://example.com",
+			link:       "http://a.private.example.com/a",
+			want:       false,
+		},
+		{
+			name: "RulesDenyHostWinsOverAllowHost",
+			scope: &crawler.ScopeRules{
+				AllowHosts: []string{"example.org"},
+				DenyHosts:  []string{"example.org"},
+			},
+			entrypoint: "http://example.com",
+			link:       "http://example.org/a",
+			want:       false,
+		},
+		{
+			name:       "RulesIncludePath",
+			scope:      &crawler.ScopeRules{IncludePaths: []string{"/docs/"}},
+			entrypoint: "http://example.com/docs/",
+			link:       "http://example.com/docs/a",
+			want:       true,
+		},
+		{
+			name:       "RulesNotIncludedPath",
+			scope:      &crawler.ScopeRules{IncludePaths: []string{"/docs/"}},
+			entrypoint: "http://example.com/docs/",
+			link:       "http://example.com/blog/a",
+			want:       false,
+		},
+		{
+			name:       "RulesIncludeRootPath",
+			scope:      &crawler.ScopeRules{IncludePaths: []string{"/"}},
+			entrypoint: "http://example.com",
+			link:       "http://example.com",
+			want:       true,
+		},
+		{
+			name:       "RulesExcludePath",
+			scope:      &crawler.ScopeRules{ExcludePaths: []string{"/docs/old/"}},
+			entrypoint: "http://example.com",
+			link:       "http://example.com/docs/old/a",
+			want:       false,
+		},
+		{
+			name: "RulesInclude",
+			scope: &crawler.ScopeRules{
+				Include: []*regexp.Regexp{regexp.MustCompile(`\.html$`)},
+			},
+			entrypoint: "http://example.com",
+			link:       "http://example.com/a.html",
+			want:       true,
+		},
+		{
+			name: "RulesNotIncluded",
+			scope: &crawler.ScopeRules{
+				Include: []*regexp.Regexp{regexp.MustCompile(`\.html$`)},
+			},
+			entrypoint: "http://example.com",
+			link:       "http://example.com/a.pdf",
+			want:       false,
+		},
+		{
+			name: "RulesExclude",
+			scope: &crawler.ScopeRules{
+				Exclude: []*regexp.Regexp{regexp.MustCompile(`[?&]page=`)},
+			},
+			entrypoint: "http://example.com",
+			link:       "http://example.com/a?page=2",
+			want:       false,
+		},
+		{
+			name: "ScopeFunc",
+			scope: crawler.ScopeFunc(func(entrypoint url.URL, link url.URL) bool {
+				return link.Scheme == "https"
+			}),
+			entrypoint: "http://example.com",
+			link:       "https://example.org",
+			want:       true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entrypoint, err := url.Parse(c.entrypoint)
+			fatalerr(t, err, "parsing entrypoint")
+			link, err := url.Parse(c.link)
+			fatalerr(t, err, "parsing link")
+
+			if got := c.scope.Contains(*entrypoint, *link); got != c.want {
+				t.Fatalf("want[%t] != got[%t]", c.want, got)
+			}
+		})
+	}
+}
+
+func TestCrawlingRespectsScope(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	const wantCrawlingErrs = 0
+
+	results, errs := crawler.New(crawler.WithScope(&crawler.ScopeRules{
+		IncludePaths: []string{"/nesting/"},
+	})).Run(context.Background(), entrypoint)
+
+	checkResults(t, results, errs, []crawler.Result{
+		result(entrypoint, "", "/nesting/info.html"),
+	}, wantCrawlingErrs)
+}
+
+func TestCrawlingHonorsRobotsOfOtherHostsInScope(t *testing.T) {
+	other, teardownOther := setupFileServer(t, "./testdata/robotssite")
+	defer teardownOther()
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `<a href="%s/public.html"></a><a href="%s/private/public.html"></a>`,
+			other.String(), other.String())
+	}))
+	defer teardown()
+
+	// WHY: robots.txt of other has a disallowed /private/secret.html
+	const wantSkippedURLs = 1
+
+	results, errs := crawler.New(crawler.WithScope(&crawler.ScopeRules{
+		AllowHosts: []string{other.Hostname()},
+	})).Run(context.Background(), entrypoint)
+
+	link := func(path string) url.URL {
+		return result(other, "", path).Link
+	}
+	checkResults(t, results, errs, []crawler.Result{
+		{Parent: entrypoint, Link: link("/public.html"), Kind: "page"},
+		{Parent: entrypoint, Link: link("/private/public.html"), Kind: "page"},
+	}, wantSkippedURLs)
+}
+
+func TestCrawlingIsNotBlockedByRobotsOfOtherHostsInScope(t *testing.T) {
+	crawled := make(chan struct{})
+
+	other, teardownOther := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			return
+		}
+		// WHY: The robots.txt is only served after a page of the
+		//      entrypoint host is crawled, so if fetching it blocked
+		//      the scheduler nothing else would be crawled meanwhile.
+		select {
+		case <-crawled:
+		case <-time.After(time.Second):
+			t.Error("fetching robots.txt of other host blocked the crawling")
+		}
+	}))
+	defer teardownOther()
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, `<a href="%s/page"></a><a href="/a"></a>`, other.String())
+		case "/a":
+			close(crawled)
+		}
+	}))
+	defer teardown()
+
+	results, errs := crawler.New(crawler.WithScope(&crawler.ScopeRules{
+		AllowHosts: []string{other.Hostname()},
+	})).Run(context.Background(), entrypoint)
+
+	checkResults(t, results, errs, []crawler.Result{
+		{Parent: entrypoint, Link: result(other, "", "/page").Link, Kind: "page"},
+		result(entrypoint, "", "/a"),
+	}, 0)
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// FormatAsSEOAuditReport will drain the given Result channel and write
+// on the given writer a report of the pages with SEO issues, sorted by
+// URL, with their issues, like:
+//
+//	http://example.com/about
+//	  missing title
+//	  multiple h1s: "About", "Team"
+//	http://example.com/contact
+//	  duplicate title "Example": also on http://example.com
+//
+// The issues are pages without a title, pages with the same title of
+// other pages and pages with more than one h1 heading. It requires page
+// results, since they have the metadata of the pages. Only HTML pages
+// crawled successfully are audited, pages with a noindex robots
+// directive are not, since they are not indexed.
+func FormatAsSEOAuditReport(res <-chan Result, w io.Writer) error {
+	pages := map[string]*Page{}
+	titles := map[string][]string{}
+
+	for r := range res {
+		if r.Page == nil || !auditable(r) {
+			continue
+		}
+		link := r.Link.String()
+		if _, ok := pages[link]; ok {
+			continue
+		}
+		pages[link] = r.Page
+		if r.Page.Title != "" {
+			titles[r.Page.Title] = append(titles[r.Page.Title], link)
+		}
+	}
+
+	links := []string{}
+	for link := range pages {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+
+	for _, link := range links {
+		issues := seoIssues(link, pages[link], titles)
+		if len(issues) == 0 {
+			continue
+		}
+
+		report := append([]string{link}, issues...)
+		_, err := w.Write([]byte(strings.Join(report, "\n  ") + "\n"))
+		if err != nil {
+			return fmt.Errorf("seo audit formatter: failed to write result: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// auditable returns true if the page of the given result was
+// parsed as HTML and can be indexed, so its metadata matters.
+func auditable(r Result) bool {
+	p := r.Page
+	return !r.External && p.Err == nil && p.StatusCode == 200 &&
+		!p.Leaf && !p.NoIndex && len(p.Redirects) == 0 && isHTML(p.ContentType)
+}
+
+func seoIssues(link string, p *Page, titles map[string][]string) []string {
+	issues := []string{}
+
+	if p.Title == "" {
+		issues = append(issues, "missing title")
+	} else if len(titles[p.Title]) > 1 {
+		others := []string{}
+		for _, other := range titles[p.Title] {
+			if other != link {
+				others = append(others, other)
+			}
+		}
+		sort.Strings(others)
+		issues = append(issues, fmt.Sprintf("duplicate title %q: also on %s",
+			p.Title, strings.Join(others, ", ")))
+	}
+
+	if len(p.H1s) > 1 {
+		quoted := []string{}
+		for _, h1 := range p.H1s {
+			quoted = append(quoted, fmt.Sprintf("%q", h1))
+		}
+		issues = append(issues, "multiple h1s: "+strings.Join(quoted, ", "))
+	}
+
+	return issues
+}
@@ -0,0 +1,93 @@
+package crawler_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestSEOAuditReportFormatter(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	page := func(path string, p crawler.Page) crawler.Result {
+		r := result(entrypoint, "", path)
+		if p.StatusCode == 0 {
+			p.StatusCode = 200
+		}
+		if p.ContentType == "" {
+			p.ContentType = "text/html; charset=utf-8"
+		}
+		r.Page = &p
+		return r
+	}
+	external := page("/external", crawler.Page{})
+	external.External = true
+
+	cases := []FormatterTestCase{
+		{
+			name:    "empty",
+			results: []crawler.Result{},
+			want:    "",
+		},
+		{
+			name: "noIssues",
+			results: []crawler.Result{
+				page("", crawler.Page{Title: "Home", H1s: []string{"Welcome"}}),
+				result(entrypoint, "", "/a"),
+				page("/a", crawler.Page{Title: "A"}),
+			},
+			want: "",
+		},
+		{
+			name: "issues",
+			results: []crawler.Result{
+				page("", crawler.Page{Title: "Home", H1s: []string{"Welcome", "News"}}),
+				page("/a", crawler.Page{}),
+				page("/b", crawler.Page{Title: "Home"}),
+				page("/c", crawler.Page{Title: "Home", H1s: []string{"C"}}),
+				page("/d", crawler.Page{Title: "D"}),
+			},
+			want: "http://test\n" +
+				"  duplicate title \"Home\": also on http://test/b, http://test/c\n" +
+				"  multiple h1s: \"Welcome\", \"News\"\n" +
+				"http://test/a\n" +
+				"  missing title\n" +
+				"http://test/b\n" +
+				"  duplicate title \"Home\": also on http://test, http://test/c\n" +
+				"http://test/c\n" +
+				"  duplicate title \"Home\": also on http://test, http://test/b\n",
+		},
+		{
+			name: "pagesNotAudited",
+			results: []crawler.Result{
+				page("/missing", crawler.Page{StatusCode: 404, Err: errors.New("not found")}),
+				page("/doc.pdf", crawler.Page{ContentType: "application/pdf"}),
+				page("/leaf", crawler.Page{Leaf: true}),
+				page("/noindex", crawler.Page{NoIndex: true}),
+				page("/old", crawler.Page{StatusCode: 301, Redirects: []crawler.Redirect{{}}}),
+				external,
+			},
+			want: "",
+		},
+	}
+
+	for _, c := range cases {
+		testFormatter(t, c, crawler.FormatAsSEOAuditReport)
+	}
+}
+
+func TestSEOAuditReportFormatterFailsOnWriteError(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	untitled := result(entrypoint, "", "/untitled")
+	untitled.Page = &crawler.Page{StatusCode: 200}
+
+	res := make(chan crawler.Result, 1)
+	res <- untitled
+	close(res)
+
+	err := crawler.FormatAsSEOAuditReport(res, &explodingWriter{failOnCall: 1})
+	if err == nil {
+		t.Fatal("expected error on failed write")
+	}
+}
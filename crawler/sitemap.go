@@ -0,0 +1,141 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/katcipis/crawler/parser"
+)
+
+// seedSitemaps schedules the sitemaps to be read: the configured ones and
+// the ones on the Sitemap lines of the entrypoint robots.txt or, if there
+// is none, the /sitemap.xml of the entrypoint.
+func seedSitemaps(state *crawlState, entrypoint url.URL, cfg Config, robots parser.Robots) error {
+	sitemaps := []Result{}
+
+	for _, u := range cfg.SitemapURLs {
+		sitemaps = append(sitemaps, Result{Link: u, Kind: parser.SitemapLink})
+	}
+
+	robotsURL := url.URL{Scheme: entrypoint.Scheme, Host: entrypoint.Host, Path: "/robots.txt"}
+	for _, u := range robots.Sitemaps() {
+		sitemaps = append(sitemaps, Result{Parent: robotsURL, Link: u, Kind: parser.SitemapLink})
+	}
+
+	if len(sitemaps) == 0 {
+		sitemaps = append(sitemaps, Result{
+			Link: url.URL{Scheme: entrypoint.Scheme, Host: entrypoint.Host, Path: "/sitemap.xml"},
+			Kind: parser.SitemapLink,
+		})
+	}
+
+	return scheduleSitemaps(state, sitemaps, 0)
+}
+
+// seedsSitemapURLs splits the given sitemaps between the given seeds,
+// so each sitemap is read only once when crawling many seeds: by the
+// first seed on the host of the sitemap or, if there is none, by the
+// first seed.
+func seedsSitemapURLs(sitemaps []url.URL, seeds []url.URL) [][]url.URL {
+	split := make([][]url.URL, len(seeds))
+
+	for _, sitemap := range sitemaps {
+		i := 0
+		for j, seed := range seeds {
+			if strings.EqualFold(seed.Host, sitemap.Host) {
+				i = j
+				break
+			}
+		}
+		split[i] = append(split[i], sitemap)
+	}
+
+	return split
+}
+
+// scheduleSitemaps schedules the sitemaps that were not visited yet.
+// Sitemaps are crawled by the crawlers like pages, but their results
+// are the URLs found on them, check getSitemapLinks.
+func scheduleSitemaps(state *crawlState, sitemaps []Result, depth uint) error {
+	for _, sitemap := range sitemaps {
+		added, err := state.visited.Add(sitemap.Link.String())
+		if err != nil {
+			return err
+		}
+		if !added {
+			continue
+		}
+
+		err = state.urls.Push(newFrontierJob(job{
+			url:   sitemap.Link,
+			depth: depth,
+			from:  sitemap,
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partitionSitemaps separates the sitemaps found on a sitemap
+// index from the other results found on a sitemap.
+func partitionSitemaps(results []Result) ([]Result, []Result) {
+	others := []Result{}
+	sitemaps := []Result{}
+
+	for _, res := range results {
+		if res.Kind == parser.SitemapLink {
+			sitemaps = append(sitemaps, res)
+			continue
+		}
+		others = append(others, res)
+	}
+
+	return others, sitemaps
+}
+
+// getSitemapLinks fetches and parses the sitemap on the given URL.
+// The URLs of the sitemap are page links and the sitemaps of a sitemap
+// index are sitemap links. The returned page is never nil, even on errors
+// it has the information about the fetch.
+func getSitemapLinks(
+	ctx context.Context,
+	c *http.Client,
+	cfg Config,
+	u url.URL,
+) (*Page, []parser.Link, error) {
+	crawled := &Page{}
+
+	res, closeRes, err := get(ctx, c, cfg, u, crawled)
+	defer closeRes()
+	if err != nil || res == nil {
+		return crawled, nil, err
+	}
+
+	body := newBodyReader(cfg, res)
+	sitemap, err := parser.ParseSitemap(body)
+	if body.exceeded() {
+		err = ErrBodyTooLarge
+	}
+	if err != nil {
+		return crawled, nil, &FetchError{
+			URL:        u,
+			Method:     http.MethodGet,
+			Phase:      ParsePhase,
+			StatusCode: res.StatusCode,
+			Err:        err,
+		}
+	}
+
+	links := []parser.Link{}
+	for _, link := range sitemap.URLs {
+		links = append(links, parser.Link{URL: link, Kind: parser.PageLink})
+	}
+	for _, link := range sitemap.Sitemaps {
+		links = append(links, parser.Link{URL: link, Kind: parser.SitemapLink})
+	}
+	return crawled, links, nil
+}
@@ -0,0 +1,173 @@
+package crawler_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestCrawlingWithSitemaps(t *testing.T) {
+	var entrypoint url.URL
+	var teardown func()
+
+	entrypoint, teardown = newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nSitemap: %s/index.xml\n", entrypoint.String())
+		case "/index.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/pages.xml.gz</loc></sitemap></sitemapindex>`, entrypoint.String())
+		case "/pages.xml.gz":
+			w.Write(gzipped(t, fmt.Sprintf(`<urlset><url><loc>%s/orphan</loc></url></urlset>`, entrypoint.String())))
+		case "/":
+			fmt.Fprint(w, `<a href="/a"></a>`)
+		case "/orphan":
+			fmt.Fprint(w, `<a href="/b"></a>`)
+		}
+	}))
+	defer teardown()
+
+	fromSitemap := result(entrypoint, "/pages.xml.gz", "/orphan")
+	fromSitemap.FromSitemap = true
+
+	want := []crawler.Result{
+		result(entrypoint, "", "/a"),
+		fromSitemap,
+		result(entrypoint, "/orphan", "/b"),
+	}
+
+	results, errs := crawler.New(crawler.WithSitemaps()).Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, want, 0)
+}
+
+func TestCrawlingWithGivenSitemaps(t *testing.T) {
+	var entrypoint url.URL
+	var teardown func()
+
+	entrypoint, teardown = newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "/custom.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/orphan</loc></url></urlset>`, entrypoint.String())
+		}
+	}))
+	defer teardown()
+
+	sitemap := entrypoint
+	sitemap.Path = "/custom.xml"
+
+	fromSitemap := result(entrypoint, "/custom.xml", "/orphan")
+	fromSitemap.FromSitemap = true
+
+	results, errs := crawler.New(crawler.WithSitemaps(sitemap)).Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, []crawler.Result{fromSitemap}, 0)
+}
+
+func TestCrawlingWithDefaultSitemap(t *testing.T) {
+	var entrypoint url.URL
+	var teardown func()
+
+	entrypoint, teardown = newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/orphan</loc></url></urlset>`, entrypoint.String())
+		}
+	}))
+	defer teardown()
+
+	fromSitemap := result(entrypoint, "/sitemap.xml", "/orphan")
+	fromSitemap.FromSitemap = true
+
+	results, errs := crawler.New(crawler.WithSitemaps(), crawler.WithPageResults()).Run(context.Background(), entrypoint)
+
+	got := collectResults(results, errs)
+	found := false
+	for _, res := range got {
+		if res.Page != nil && res.Link == fromSitemap.Link {
+			found = true
+			if !res.FromSitemap || res.Parent != fromSitemap.Parent || res.Page.Depth != 1 {
+				t.Errorf("want page result from sitemap[%s] with depth 1, got %+v", fromSitemap.Parent.String(), res)
+			}
+		}
+		if res.Page != nil && res.Link.Path == "/sitemap.xml" {
+			t.Errorf("unexpected page result for the sitemap: %+v", res)
+		}
+	}
+	if !found {
+		t.Fatalf("want page result of the sitemap URL, got %+v", got)
+	}
+}
+
+func TestCrawlingWithInvalidSitemap(t *testing.T) {
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "/sitemap.xml":
+			fmt.Fprint(w, `<urlset><url>`)
+		case "/":
+			fmt.Fprint(w, `<a href="/a"></a>`)
+		}
+	}))
+	defer teardown()
+
+	const wantErrs = 1
+
+	results, errs := crawler.New(crawler.WithSitemaps()).Run(context.Background(), entrypoint)
+	checkResults(t, results, errs, []crawler.Result{result(entrypoint, "", "/a")}, wantErrs)
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err := w.Write([]byte(s))
+	fatalerr(t, err, "writing gzip")
+	fatalerr(t, w.Close(), "closing gzip")
+	return buf.Bytes()
+}
+
+func TestCrawlingMultipleSeedsReadsGivenSitemapsOnce(t *testing.T) {
+	var first url.URL
+	var teardownFirst func()
+
+	first, teardownFirst = newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "/custom.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/orphan</loc></url></urlset>`, first.String())
+		}
+	}))
+	defer teardownFirst()
+
+	second, teardownSecond := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer teardownSecond()
+
+	sitemap := first
+	sitemap.Path = "/custom.xml"
+
+	fromSitemap := result(first, "/custom.xml", "/orphan")
+	fromSitemap.FromSitemap = true
+	fromSitemap.Seed = first
+
+	// WHY: The second seed and its /sitemap.xml are not found
+	const wantCrawlingErrs = 2
+
+	results, errs := crawler.New(crawler.WithSitemaps(sitemap)).RunSeeds(
+		context.Background(),
+		[]url.URL{second, first},
+	)
+	checkResults(t, results, errs, []crawler.Result{fromSitemap}, wantCrawlingErrs)
+}
@@ -0,0 +1,141 @@
+package crawler
+
+import "github.com/katcipis/crawler/parser"
+
+// Storage creates the frontiers and visited sets where the state of the
+// crawling is kept. The formatters that need to remember URLs, like the
+// sitemap ones, also keep their state on a Storage.
+type Storage interface {
+	// NewFrontier creates a new empty frontier
+	NewFrontier() (Frontier, error)
+	// NewVisitedSet creates a new empty visited set
+	NewVisitedSet() (VisitedSet, error)
+}
+
+// Frontier is a queue of URLs waiting to be crawled.
+// A Frontier is used by a single goroutine, so it
+// does not need to be safe for concurrent use.
+type Frontier interface {
+	// Push adds the job to the frontier
+	Push(j FrontierJob) error
+	// Pop removes the next job from the frontier,
+	// ok is false if the frontier is empty.
+	Pop() (j FrontierJob, ok bool, err error)
+	// Len returns the amount of jobs on the frontier
+	Len() int
+	// Range calls fn for each job on the frontier, without removing
+	// them, in the order they were pushed, stopping at the first
+	// error returned by fn.
+	Range(fn func(FrontierJob) error) error
+	// Close releases all resources of the frontier
+	Close() error
+}
+
+// VisitedSet is a set of already seen keys, like URLs.
+// A VisitedSet is used by a single goroutine, so it
+// does not need to be safe for concurrent use.
+type VisitedSet interface {
+	// Add adds the key to the set, added is
+	// false if the key was already on the set.
+	Add(key string) (added bool, err error)
+	// Contains returns true if the key is on the set
+	Contains(key string) (bool, error)
+	// Range calls fn for each key on the set,
+	// stopping at the first error returned by fn.
+	Range(fn func(key string) error) error
+	// Close releases all resources of the set
+	Close() error
+}
+
+// FrontierJob is an URL waiting on a Frontier to be crawled
+type FrontierJob struct {
+	// URL is the URL to be crawled
+	URL string `json:"url"`
+	// Depth is the distance in links from the entrypoint
+	Depth uint `json:"depth"`
+	// Parent is the URL where the URL was found
+	Parent string `json:"parent,omitempty"`
+	// Kind is the kind of the link found on Parent
+	Kind parser.LinkKind `json:"kind,omitempty"`
+	// External is true when the URL is going to be checked, not crawled
+	External bool `json:"external,omitempty"`
+	// FromSitemap is true when the URL was discovered on a sitemap
+	FromSitemap bool `json:"sitemap,omitempty"`
+}
+
+// MemoryStorage keeps frontiers and visited sets in memory,
+// it is the default storage.
+var MemoryStorage Storage = memoryStorage{}
+
+type memoryStorage struct{}
+
+func (memoryStorage) NewFrontier() (Frontier, error) {
+	return &memoryFrontier{}, nil
+}
+
+func (memoryStorage) NewVisitedSet() (VisitedSet, error) {
+	return memoryVisitedSet{}, nil
+}
+
+type memoryFrontier struct {
+	jobs []FrontierJob
+}
+
+func (f *memoryFrontier) Push(j FrontierJob) error {
+	f.jobs = append(f.jobs, j)
+	return nil
+}
+
+func (f *memoryFrontier) Pop() (FrontierJob, bool, error) {
+	if len(f.jobs) == 0 {
+		return FrontierJob{}, false, nil
+	}
+	j := f.jobs[0]
+	f.jobs = f.jobs[1:]
+	return j, true, nil
+}
+
+func (f *memoryFrontier) Len() int {
+	return len(f.jobs)
+}
+
+func (f *memoryFrontier) Range(fn func(FrontierJob) error) error {
+	for _, j := range f.jobs {
+		if err := fn(j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *memoryFrontier) Close() error {
+	f.jobs = nil
+	return nil
+}
+
+type memoryVisitedSet map[string]bool
+
+func (s memoryVisitedSet) Add(key string) (bool, error) {
+	if s[key] {
+		return false, nil
+	}
+	s[key] = true
+	return true, nil
+}
+
+func (s memoryVisitedSet) Contains(key string) (bool, error) {
+	return s[key], nil
+}
+
+func (s memoryVisitedSet) Range(fn func(key string) error) error {
+	for key := range s {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s memoryVisitedSet) Close() error {
+	return nil
+}
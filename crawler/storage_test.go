@@ -0,0 +1,218 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+type storageTestCase struct {
+	name    string
+	storage func(dir string) crawler.Storage
+}
+
+// WHY: A tiny memory limit forces the disk storage
+//
+//	to write and read its segment files.
+var storageCases = []storageTestCase{
+	{
+		name: "Memory",
+		storage: func(string) crawler.Storage {
+			return crawler.MemoryStorage
+		},
+	},
+	{
+		name: "Disk",
+		storage: func(dir string) crawler.Storage {
+			return crawler.DiskStorage{Dir: dir, MemoryLimit: 3}
+		},
+	},
+}
+
+func TestFrontiers(t *testing.T) {
+	for _, c := range storageCases {
+		t.Run(c.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			frontier, err := c.storage(dir).NewFrontier()
+			fatalerr(t, err, "creating frontier")
+
+			newJob := func(i int) crawler.FrontierJob {
+				return crawler.FrontierJob{
+					URL:    fmt.Sprintf("http://test/%d", i),
+					Depth:  uint(i),
+					Parent: "http://test",
+					Kind:   "page",
+				}
+			}
+
+			want := []crawler.FrontierJob{}
+			for i := 0; i < 10; i++ {
+				fatalerr(t, frontier.Push(newJob(i)), "pushing job")
+				want = append(want, newJob(i))
+			}
+
+			popAndCheck := func(n int) {
+				for i := 0; i < n; i++ {
+					got, ok, err := frontier.Pop()
+					fatalerr(t, err, "popping job")
+					if !ok {
+						t.Fatalf("frontier empty, want job[%+v]", want[0])
+					}
+					if got != want[0] {
+						t.Fatalf("want job[%+v] != got[%+v]", want[0], got)
+					}
+					want = want[1:]
+				}
+			}
+
+			popAndCheck(4)
+
+			for i := 10; i < 15; i++ {
+				fatalerr(t, frontier.Push(newJob(i)), "pushing job")
+				want = append(want, newJob(i))
+			}
+
+			if frontier.Len() != len(want) {
+				t.Fatalf("want len %d, got %d", len(want), frontier.Len())
+			}
+
+			ranged := []crawler.FrontierJob{}
+			fatalerr(t, frontier.Range(func(j crawler.FrontierJob) error {
+				ranged = append(ranged, j)
+				return nil
+			}), "ranging frontier")
+			if !reflect.DeepEqual(want, ranged) {
+				t.Fatalf("want ranged jobs %v, got %v", want, ranged)
+			}
+
+			popAndCheck(len(want))
+
+			if _, ok, err := frontier.Pop(); ok || err != nil {
+				t.Fatalf("want empty frontier, got ok[%t] err[%v]", ok, err)
+			}
+
+			fatalerr(t, frontier.Close(), "closing frontier")
+			checkDirIsEmpty(t, dir)
+		})
+	}
+}
+
+func TestVisitedSets(t *testing.T) {
+	for _, c := range storageCases {
+		t.Run(c.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			set, err := c.storage(dir).NewVisitedSet()
+			fatalerr(t, err, "creating visited set")
+
+			const keys = 1000
+
+			want := []string{}
+			for i := 0; i < keys; i++ {
+				key := fmt.Sprintf("http://test/%d", i)
+				added, err := set.Add(key)
+				fatalerr(t, err, "adding key")
+				if !added {
+					t.Fatalf("key[%s] not added", key)
+				}
+				want = append(want, key)
+			}
+
+			for _, key := range want {
+				added, err := set.Add(key)
+				fatalerr(t, err, "adding key again")
+				if added {
+					t.Fatalf("key[%s] added twice", key)
+				}
+
+				found, err := set.Contains(key)
+				fatalerr(t, err, "checking key")
+				if !found {
+					t.Fatalf("key[%s] not found", key)
+				}
+			}
+
+			found, err := set.Contains("http://test/missing")
+			fatalerr(t, err, "checking missing key")
+			if found {
+				t.Fatal("missing key found")
+			}
+
+			got := []string{}
+			fatalerr(t, set.Range(func(key string) error {
+				got = append(got, key)
+				return nil
+			}), "ranging visited set")
+
+			sort.Strings(want)
+			sort.Strings(got)
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("want ranged keys %v, got %v", want, got)
+			}
+
+			fatalerr(t, set.Close(), "closing visited set")
+			checkDirIsEmpty(t, dir)
+		})
+	}
+}
+
+func TestCrawlingWithDiskStorage(t *testing.T) {
+	entrypoint, teardown := setupFileServer(t, "./testdata/fakesite")
+	defer teardown()
+
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	const wantCrawlingErrs = 3
+
+	results, errs := crawler.New(crawler.WithStorage(crawler.DiskStorage{
+		Dir:         dir,
+		MemoryLimit: 1,
+	})).Run(context.Background(), entrypoint)
+
+	checkResults(t, results, errs, fakesiteResults(entrypoint), wantCrawlingErrs)
+	checkDirIsEmpty(t, dir)
+}
+
+func TestTextSitemapFormatterWithDiskStorage(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	f := crawler.TextSitemapFormatter{
+		Storage: crawler.DiskStorage{Dir: dir, MemoryLimit: 1},
+	}
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+
+	testFormatter(t, FormatterTestCase{
+		name: "Disk",
+		results: []crawler.Result{
+			result(entrypoint, "", "/a"),
+			result(entrypoint, "", "/b"),
+			result(entrypoint, "/a", "/b"),
+			result(entrypoint, "/b", "/c"),
+			result(entrypoint, "/c", ""),
+		},
+		want: "http://test\nhttp://test/a\nhttp://test/b\nhttp://test/c",
+	}, f.Format)
+
+	checkDirIsEmpty(t, dir)
+}
+
+func checkDirIsEmpty(t *testing.T, dir string) {
+	t.Helper()
+
+	files, err := os.ReadDir(dir)
+	fatalerr(t, err, "reading dir")
+	if len(files) > 0 {
+		t.Fatalf("want empty dir[%s], got %d files", dir, len(files))
+	}
+}
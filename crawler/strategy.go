@@ -0,0 +1,208 @@
+package crawler
+
+import (
+	"container/heap"
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Strategy decides the order the URLs are crawled by creating the
+// frontier of URLs waiting to be crawled. Since the crawlers work
+// concurrently the order is only exact with a single crawler.
+type Strategy func(storage Storage) (Frontier, error)
+
+// ErrFrontierInMemory is returned by the strategies that keep their
+// frontier in memory when the storage does not keep its frontiers in
+// memory, like a DiskStorage, since the frontier could not grow as large
+// as the storage allows.
+var ErrFrontierInMemory = errors.New("the strategy frontier is kept in memory, the storage frontiers are not")
+
+// ScoreFunc scores an URL on the frontier,
+// higher scores are crawled first.
+type ScoreFunc func(FrontierJob) float64
+
+// BreadthFirst crawls the URLs in the order they are found, so all
+// URLs at one depth are crawled before the deeper ones. The frontier
+// is created by the storage. It is the default strategy.
+var BreadthFirst Strategy = func(storage Storage) (Frontier, error) {
+	return storage.NewFrontier()
+}
+
+// DepthFirst crawls the most recently found URLs first, going as
+// deep as possible before going back. The frontier is kept in memory,
+// so it fails with ErrFrontierInMemory on storages like DiskStorage.
+var DepthFirst Strategy = func(storage Storage) (Frontier, error) {
+	if !frontiersInMemory(storage) {
+		return nil, ErrFrontierInMemory
+	}
+	return &stackFrontier{}, nil
+}
+
+// BestFirst crawls the URLs with the highest scores first, URLs with
+// the same score are crawled in the order they are found. The scores
+// are calculated once, when the URL is found. The frontier is kept
+// in memory, so it fails with ErrFrontierInMemory on storages like
+// DiskStorage.
+func BestFirst(score ScoreFunc) Strategy {
+	return func(storage Storage) (Frontier, error) {
+		if !frontiersInMemory(storage) {
+			return nil, ErrFrontierInMemory
+		}
+		return &priorityFrontier{score: score}, nil
+	}
+}
+
+// ScoreByPathDepth scores URLs with shorter paths higher,
+// so "/docs" is crawled before "/docs/api/index.html".
+func ScoreByPathDepth(j FrontierJob) float64 {
+	u, err := url.Parse(j.URL)
+	if err != nil {
+		return 0
+	}
+	path := strings.Trim(u.Path, "/")
+	if path == "" {
+		return 0
+	}
+	return -float64(strings.Count(path, "/") + 1)
+}
+
+// ScoreByPattern scores URLs matching any of the
+// expressions with one and the other ones with zero.
+func ScoreByPattern(exps ...*regexp.Regexp) ScoreFunc {
+	return func(j FrontierJob) float64 {
+		if matchesAny(j.URL, exps) {
+			return 1
+		}
+		return 0
+	}
+}
+
+// frontiersInMemory returns true if the given storage keeps its
+// frontiers in memory, custom storages are assumed to not keep them.
+func frontiersInMemory(storage Storage) bool {
+	switch s := storage.(type) {
+	case memoryStorage:
+		return true
+	case *BloomStorage:
+		return s.Frontiers == nil || frontiersInMemory(s.Frontiers)
+	}
+	return false
+}
+
+type stackFrontier struct {
+	jobs []FrontierJob
+}
+
+func (f *stackFrontier) Push(j FrontierJob) error {
+	f.jobs = append(f.jobs, j)
+	return nil
+}
+
+func (f *stackFrontier) Pop() (FrontierJob, bool, error) {
+	if len(f.jobs) == 0 {
+		return FrontierJob{}, false, nil
+	}
+	j := f.jobs[len(f.jobs)-1]
+	f.jobs = f.jobs[:len(f.jobs)-1]
+	return j, true, nil
+}
+
+func (f *stackFrontier) Len() int {
+	return len(f.jobs)
+}
+
+func (f *stackFrontier) Range(fn func(FrontierJob) error) error {
+	for _, j := range f.jobs {
+		if err := fn(j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *stackFrontier) Close() error {
+	f.jobs = nil
+	return nil
+}
+
+// priorityFrontier is a heap of jobs ordered by their scores,
+// jobs with the same score are ordered by when they were pushed.
+type priorityFrontier struct {
+	score  ScoreFunc
+	jobs   scoredJobs
+	pushed uint64
+}
+
+type scoredJob struct {
+	job   FrontierJob
+	score float64
+	order uint64
+}
+
+func (f *priorityFrontier) Push(j FrontierJob) error {
+	heap.Push(&f.jobs, scoredJob{job: j, score: f.score(j), order: f.pushed})
+	f.pushed++
+	return nil
+}
+
+func (f *priorityFrontier) Pop() (FrontierJob, bool, error) {
+	if len(f.jobs) == 0 {
+		return FrontierJob{}, false, nil
+	}
+	return heap.Pop(&f.jobs).(scoredJob).job, true, nil
+}
+
+func (f *priorityFrontier) Len() int {
+	return len(f.jobs)
+}
+
+func (f *priorityFrontier) Range(fn func(FrontierJob) error) error {
+	jobs := make(scoredJobs, len(f.jobs))
+	copy(jobs, f.jobs)
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].order < jobs[j].order
+	})
+
+	for _, j := range jobs {
+		if err := fn(j.job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *priorityFrontier) Close() error {
+	f.jobs = nil
+	return nil
+}
+
+type scoredJobs []scoredJob
+
+func (s scoredJobs) Len() int {
+	return len(s)
+}
+
+func (s scoredJobs) Less(i, j int) bool {
+	if s[i].score != s[j].score {
+		return s[i].score > s[j].score
+	}
+	return s[i].order < s[j].order
+}
+
+func (s scoredJobs) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s *scoredJobs) Push(x interface{}) {
+	*s = append(*s, x.(scoredJob))
+}
+
+func (s *scoredJobs) Pop() interface{} {
+	old := *s
+	last := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return last
+}
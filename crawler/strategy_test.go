@@ -0,0 +1,237 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+func TestStrategies(t *testing.T) {
+	type tcase struct {
+		name     string
+		strategy crawler.Strategy
+		want     []string
+	}
+
+	pushed := []string{"/a/b/c", "/a", "/x/y", "/b"}
+
+	cases := []tcase{
+		{
+			name:     "BreadthFirst",
+			strategy: crawler.BreadthFirst,
+			want:     []string{"/a/b/c", "/a", "/x/y", "/b"},
+		},
+		{
+			name:     "DepthFirst",
+			strategy: crawler.DepthFirst,
+			want:     []string{"/b", "/x/y", "/a", "/a/b/c"},
+		},
+		{
+			name:     "BestFirstByPathDepth",
+			strategy: crawler.BestFirst(crawler.ScoreByPathDepth),
+			want:     []string{"/a", "/b", "/x/y", "/a/b/c"},
+		},
+		{
+			name:     "BestFirstByPattern",
+			strategy: crawler.BestFirst(crawler.ScoreByPattern(regexp.MustCompile("/x/"))),
+			want:     []string{"/x/y", "/a/b/c", "/a", "/b"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			frontier, err := c.strategy(crawler.MemoryStorage)
+			fatalerr(t, err, "creating frontier")
+			defer frontier.Close()
+
+			for _, path := range pushed {
+				fatalerr(t, frontier.Push(crawler.FrontierJob{URL: "http://test" + path}), "pushing job")
+			}
+
+			ranged := []string{}
+			fatalerr(t, frontier.Range(func(j crawler.FrontierJob) error {
+				ranged = append(ranged, j.URL[len("http://test"):])
+				return nil
+			}), "ranging frontier")
+			if !reflect.DeepEqual(pushed, ranged) {
+				t.Fatalf("want ranged jobs in pushed order %v, got %v", pushed, ranged)
+			}
+
+			got := []string{}
+			for {
+				j, ok, err := frontier.Pop()
+				fatalerr(t, err, "popping job")
+				if !ok {
+					break
+				}
+				got = append(got, j.URL[len("http://test"):])
+			}
+
+			if !reflect.DeepEqual(c.want, got) {
+				t.Fatalf("want order %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestScoreByPathDepth(t *testing.T) {
+	cases := map[string]float64{
+		"http://test":             0,
+		"http://test/":            0,
+		"http://test/a":           -1,
+		"http://test/a/":          -1,
+		"http://test/a/b.html":    -2,
+		"http://test/a/b/c?q=d/e": -3,
+	}
+
+	for u, want := range cases {
+		if got := crawler.ScoreByPathDepth(crawler.FrontierJob{URL: u}); got != want {
+			t.Errorf("url[%s]: want score %g, got %g", u, want, got)
+		}
+	}
+}
+
+func TestCrawlingStrategies(t *testing.T) {
+	type tcase struct {
+		name     string
+		strategy crawler.Strategy
+		want     []string
+	}
+
+	cases := []tcase{
+		{
+			name:     "BreadthFirst",
+			strategy: crawler.BreadthFirst,
+			want:     []string{"", "/a", "/b", "/a/1", "/b/1"},
+		},
+		{
+			name:     "DepthFirst",
+			strategy: crawler.DepthFirst,
+			want:     []string{"", "/b", "/b/1", "/a", "/a/1"},
+		},
+		{
+			name:     "BestFirst",
+			strategy: crawler.BestFirst(crawler.ScoreByPattern(regexp.MustCompile("/b"))),
+			want:     []string{"", "/b", "/b/1", "/a", "/a/1"},
+		},
+	}
+
+	links := map[string][]string{
+		"/":  {"/a", "/b"},
+		"/a": {"/a/1"},
+		"/b": {"/b/1"},
+	}
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for _, link := range links[r.URL.Path] {
+			fmt.Fprintf(w, `<a href="%s"></a>`, link)
+		}
+	}))
+	defer teardown()
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// WHY: The order is only exact with a single crawler
+			results, errs := crawler.New(
+				crawler.WithConcurrency(1),
+				crawler.WithPageResults(),
+				crawler.WithStrategy(c.strategy),
+			).Run(context.Background(), entrypoint)
+
+			got := []string{}
+			for _, res := range collectResults(results, errs) {
+				if res.Page != nil {
+					got = append(got, res.Link.Path)
+				}
+			}
+
+			if !reflect.DeepEqual(c.want, got) {
+				t.Fatalf("want crawling order %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestCrawlingStrategiesInMemoryFailOnDiskStorage(t *testing.T) {
+	type tcase struct {
+		name     string
+		strategy crawler.Strategy
+		storage  crawler.Storage
+		wantErr  bool
+	}
+
+	disk := crawler.DiskStorage{Dir: t.TempDir()}
+	cases := []tcase{
+		{
+			name:     "BreadthFirst",
+			strategy: crawler.BreadthFirst,
+			storage:  disk,
+		},
+		{
+			name:     "DepthFirst",
+			strategy: crawler.DepthFirst,
+			storage:  disk,
+			wantErr:  true,
+		},
+		{
+			name:     "BestFirst",
+			strategy: crawler.BestFirst(crawler.ScoreByPathDepth),
+			storage:  disk,
+			wantErr:  true,
+		},
+		{
+			name:     "BestFirstOnBloomStorage",
+			strategy: crawler.BestFirst(crawler.ScoreByPathDepth),
+			storage:  crawler.NewBloomStorage(nil, 100, 0.01),
+		},
+		{
+			name:     "BestFirstOnBloomStorageOnDisk",
+			strategy: crawler.BestFirst(crawler.ScoreByPathDepth),
+			storage:  crawler.NewBloomStorage(disk, 100, 0.01),
+			wantErr:  true,
+		},
+	}
+
+	entrypoint, teardown := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/a"></a>`)
+		}
+	}))
+	defer teardown()
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			results, errs := crawler.New(
+				crawler.WithStorage(c.storage),
+				crawler.WithStrategy(c.strategy),
+			).Run(context.Background(), entrypoint)
+
+			go func() {
+				for range results {
+				}
+			}()
+
+			gotErr := false
+			for err := range errs {
+				if !errors.Is(err, crawler.ErrFrontierInMemory) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				gotErr = true
+			}
+
+			if gotErr != c.wantErr {
+				t.Fatalf("want error %t, got %t", c.wantErr, gotErr)
+			}
+		})
+	}
+}
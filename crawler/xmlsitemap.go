@@ -0,0 +1,183 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+const (
+	// MaxSitemapURLs is the maximum amount of URLs on a XML sitemap
+	MaxSitemapURLs = 50000
+	// MaxSitemapBytes is the maximum size of a XML sitemap
+	MaxSitemapBytes = 50 * 1024 * 1024
+)
+
+const (
+	xmlSitemapHeader = xml.Header +
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	xmlSitemapFooter      = "</urlset>\n"
+	xmlSitemapIndexHeader = xml.Header +
+		`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	xmlSitemapIndexFooter = "</sitemapindex>\n"
+)
+
+// XMLSitemapFormatter formats results as a XML sitemap
+// following this specification:
+//
+// https://www.sitemaps.org/protocol.html
+//
+// URLs are written the same way as FormatAsTextSitemap does, with
+// a <lastmod> when the page had a Last-Modified header. When the URLs
+// do not fit on a single sitemap they are split on several sitemap files,
+// created with Create, and a sitemap index is written instead.
+type XMLSitemapFormatter struct {
+	// MaxURLs is the maximum amount of URLs on each sitemap,
+	// MaxSitemapURLs is used if zero.
+	MaxURLs int
+	// MaxBytes is the maximum size in bytes of each sitemap,
+	// MaxSitemapBytes is used if zero.
+	MaxBytes int
+	// IncludeNoIndex includes pages with a noindex robots directive.
+	IncludeNoIndex bool
+	// Create creates the sitemap files with the given names, like
+	// "sitemap-1.xml". If nil the formatting fails when the URLs
+	// do not fit on a single sitemap.
+	Create func(name string) (io.WriteCloser, error)
+	// IndexURL is the URL where the sitemap files are going to be
+	// published, used to create the locations on the sitemap index.
+	// If empty the root of the first URL found is used.
+	IndexURL url.URL
+	// Storage is where the URLs found are kept until they are written
+	// and after it, to avoid repeated URLs. MemoryStorage is used if nil.
+	Storage Storage
+}
+
+// FormatAsXMLSitemap will drain the given Result channel and write
+// them in the given writer formatted as a single XML sitemap.
+// If the URLs do not fit on a single sitemap an error is returned,
+// use a XMLSitemapFormatter to split them on several files.
+func FormatAsXMLSitemap(res <-chan Result, w io.Writer) error {
+	f := XMLSitemapFormatter{}
+	return f.Format(res, w)
+}
+
+// Format will drain the given Result channel and write them in the
+// given writer formatted as a XML sitemap, or as a sitemap index if
+// the URLs had to be split on several sitemap files.
+func (f XMLSitemapFormatter) Format(res <-chan Result, w io.Writer) error {
+	maxURLs := f.MaxURLs
+	if maxURLs <= 0 {
+		maxURLs = MaxSitemapURLs
+	}
+	maxBytes := f.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = MaxSitemapBytes
+	}
+
+	// WHY: The current sitemap is kept in memory until it is full,
+	//      since only then we know if a sitemap index is required.
+	sitemap := &bytes.Buffer{}
+	urls := 0
+	files := []string{}
+	indexURL := f.IndexURL
+
+	flush := func() error {
+		if f.Create == nil {
+			return errors.New("xml sitemap formatter: urls do not fit on a single sitemap")
+		}
+		name := fmt.Sprintf("sitemap-%d.xml", len(files)+1)
+		if err := f.writeFile(name, sitemap.Bytes()); err != nil {
+			return err
+		}
+		files = append(files, name)
+		sitemap.Reset()
+		urls = 0
+		return nil
+	}
+
+	err := sitemapURLs(res, f.IncludeNoIndex, f.Storage, func(u url.URL, page *Page) error {
+		if indexURL.Host == "" {
+			indexURL = url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+		}
+
+		entry := xmlSitemapEntry(u, page)
+		size := len(xmlSitemapHeader) + sitemap.Len() + len(entry) + len(xmlSitemapFooter)
+		if urls > 0 && (urls >= maxURLs || size > maxBytes) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		sitemap.Write(entry)
+		urls++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return writeXML(w, xmlSitemapHeader, sitemap.Bytes(), xmlSitemapFooter)
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	index := &bytes.Buffer{}
+	for _, name := range files {
+		loc := indexURL.ResolveReference(&url.URL{Path: name})
+		index.WriteString("<sitemap><loc>")
+		xml.EscapeText(index, []byte(loc.String()))
+		index.WriteString("</loc></sitemap>\n")
+	}
+
+	return writeXML(w, xmlSitemapIndexHeader, index.Bytes(), xmlSitemapIndexFooter)
+}
+
+func (f XMLSitemapFormatter) writeFile(name string, sitemap []byte) error {
+	file, err := f.Create(name)
+	if err != nil {
+		return fmt.Errorf("xml sitemap formatter: unable to create sitemap[%s]: %s", name, err)
+	}
+
+	err = writeXML(file, xmlSitemapHeader, sitemap, xmlSitemapFooter)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("xml sitemap formatter: unable to close sitemap[%s]: %s", name, closeErr)
+	}
+	return err
+}
+
+func writeXML(w io.Writer, header string, body []byte, footer string) error {
+	doc := make([]byte, 0, len(header)+len(body)+len(footer))
+	doc = append(doc, header...)
+	doc = append(doc, body...)
+	doc = append(doc, footer...)
+
+	if _, err := w.Write(doc); err != nil {
+		return fmt.Errorf("xml sitemap formatter: failed to write sitemap: %s", err)
+	}
+	return nil
+}
+
+func xmlSitemapEntry(u url.URL, page *Page) []byte {
+	entry := &bytes.Buffer{}
+
+	entry.WriteString("<url><loc>")
+	xml.EscapeText(entry, []byte(u.String()))
+	entry.WriteString("</loc>")
+
+	if page != nil && !page.LastModified.IsZero() {
+		entry.WriteString("<lastmod>")
+		entry.WriteString(page.LastModified.UTC().Format(time.RFC3339))
+		entry.WriteString("</lastmod>")
+	}
+
+	entry.WriteString("</url>\n")
+	return entry.Bytes()
+}
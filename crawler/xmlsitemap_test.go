@@ -0,0 +1,303 @@
+package crawler_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/katcipis/crawler/crawler"
+)
+
+const (
+	xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	xmlFooter      = "</urlset>\n"
+	xmlIndexHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	xmlIndexFooter = "</sitemapindex>\n"
+)
+
+func TestXMLSitemapFormatter(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	lastModified := time.Date(2020, 3, 4, 5, 6, 7, 0, time.FixedZone("x", 3600))
+	page := func(path string, p crawler.Page) crawler.Result {
+		r := result(entrypoint, "", path)
+		if p.StatusCode == 0 {
+			p.StatusCode = 200
+		}
+		r.Page = &p
+		return r
+	}
+	external := page("/external", crawler.Page{})
+	external.Link.Host = "external.test"
+	external.External = true
+	externalLink := external
+	externalLink.Page = nil
+	withQuery := result(entrypoint, "", "/a")
+	withQuery.Link.RawQuery = "b=1&c=<d>"
+
+	cases := []FormatterTestCase{
+		{
+			name:    "empty",
+			results: []crawler.Result{},
+			want:    xmlHeader + xmlFooter,
+		},
+		{
+			name: "two",
+			results: []crawler.Result{
+				result(entrypoint, "", "/link"),
+			},
+			want: xmlHeader +
+				"<url><loc>http://test</loc></url>\n" +
+				"<url><loc>http://test/link</loc></url>\n" +
+				xmlFooter,
+		},
+		{
+			name: "escaping",
+			results: []crawler.Result{
+				withQuery,
+			},
+			want: xmlHeader +
+				"<url><loc>http://test</loc></url>\n" +
+				"<url><loc>http://test/a?b=1&amp;c=&lt;d&gt;</loc></url>\n" +
+				xmlFooter,
+		},
+		{
+			name: "lastmod",
+			results: []crawler.Result{
+				page("", crawler.Page{}),
+				result(entrypoint, "", "/modified"),
+				page("/modified", crawler.Page{LastModified: lastModified}),
+			},
+			want: xmlHeader +
+				"<url><loc>http://test</loc></url>\n" +
+				"<url><loc>http://test/modified</loc><lastmod>2020-03-04T04:06:07Z</lastmod></url>\n" +
+				xmlFooter,
+		},
+		{
+			name: "noindex",
+			results: []crawler.Result{
+				result(entrypoint, "", "/noindex"),
+				page("/noindex", crawler.Page{NoIndex: true}),
+			},
+			want: xmlHeader +
+				"<url><loc>http://test</loc></url>\n" +
+				xmlFooter,
+		},
+		{
+			name: "failures",
+			results: []crawler.Result{
+				result(entrypoint, "", "/missing"),
+				page("/missing", crawler.Page{StatusCode: 404, Err: errors.New("not found")}),
+				result(entrypoint, "", "/unreachable"),
+				page("/unreachable", crawler.Page{Err: errors.New("connection refused")}),
+			},
+			want: xmlHeader +
+				"<url><loc>http://test</loc></url>\n" +
+				xmlFooter,
+		},
+		{
+			name: "external",
+			results: []crawler.Result{
+				externalLink,
+				external,
+			},
+			want: xmlHeader +
+				"<url><loc>http://test</loc></url>\n" +
+				xmlFooter,
+		},
+	}
+
+	for _, c := range cases {
+		testFormatter(t, c, crawler.FormatAsXMLSitemap)
+	}
+}
+
+func TestXMLSitemapFormatterSplitsSitemaps(t *testing.T) {
+	entrypoint := url.URL{Scheme: "https", Host: "test"}
+	results := []crawler.Result{
+		result(entrypoint, "", "/1"),
+		result(entrypoint, "", "/2"),
+		result(entrypoint, "", "/3"),
+		result(entrypoint, "", "/4"),
+	}
+	entry := func(path string) string {
+		return fmt.Sprintf("<url><loc>https://test%s</loc></url>\n", path)
+	}
+
+	type tcase struct {
+		name      string
+		formatter crawler.XMLSitemapFormatter
+		want      string
+		wantFiles map[string]string
+	}
+
+	cases := []tcase{
+		{
+			name:      "MaxURLs",
+			formatter: crawler.XMLSitemapFormatter{MaxURLs: 2},
+			want: xmlIndexHeader +
+				"<sitemap><loc>https://test/sitemap-1.xml</loc></sitemap>\n" +
+				"<sitemap><loc>https://test/sitemap-2.xml</loc></sitemap>\n" +
+				"<sitemap><loc>https://test/sitemap-3.xml</loc></sitemap>\n" +
+				xmlIndexFooter,
+			wantFiles: map[string]string{
+				"sitemap-1.xml": xmlHeader + entry("") + entry("/1") + xmlFooter,
+				"sitemap-2.xml": xmlHeader + entry("/2") + entry("/3") + xmlFooter,
+				"sitemap-3.xml": xmlHeader + entry("/4") + xmlFooter,
+			},
+		},
+		{
+			name: "MaxBytes",
+			formatter: crawler.XMLSitemapFormatter{
+				MaxBytes: len(xmlHeader + entry("/1") + entry("/2") + entry("/3") + xmlFooter),
+				IndexURL: url.URL{Scheme: "http", Host: "sitemaps", Path: "/test/"},
+			},
+			want: xmlIndexHeader +
+				"<sitemap><loc>http://sitemaps/test/sitemap-1.xml</loc></sitemap>\n" +
+				"<sitemap><loc>http://sitemaps/test/sitemap-2.xml</loc></sitemap>\n" +
+				xmlIndexFooter,
+			wantFiles: map[string]string{
+				"sitemap-1.xml": xmlHeader + entry("") + entry("/1") + entry("/2") + xmlFooter,
+				"sitemap-2.xml": xmlHeader + entry("/3") + entry("/4") + xmlFooter,
+			},
+		},
+		{
+			name:      "NotRequired",
+			formatter: crawler.XMLSitemapFormatter{MaxURLs: 5},
+			want: xmlHeader +
+				entry("") + entry("/1") + entry("/2") + entry("/3") + entry("/4") +
+				xmlFooter,
+			wantFiles: map[string]string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			files := map[string]*fakeFile{}
+			c.formatter.Create = func(name string) (io.WriteCloser, error) {
+				if _, ok := files[name]; ok {
+					t.Fatalf("sitemap[%s] created twice", name)
+				}
+				files[name] = &fakeFile{}
+				return files[name], nil
+			}
+
+			testFormatter(t, FormatterTestCase{
+				name:    "Index",
+				results: results,
+				want:    c.want,
+			}, c.formatter.Format)
+
+			if len(files) != len(c.wantFiles) {
+				t.Fatalf("want %d sitemaps, got %d", len(c.wantFiles), len(files))
+			}
+			for name, want := range c.wantFiles {
+				file, ok := files[name]
+				if !ok {
+					t.Fatalf("sitemap[%s] not created", name)
+				}
+				if !file.closed {
+					t.Errorf("sitemap[%s] not closed", name)
+				}
+				if got := file.String(); got != want {
+					t.Errorf("sitemap[%s]: want:[%s] != got[%s]", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestXMLSitemapFormatterFails(t *testing.T) {
+	entrypoint := url.URL{Scheme: "http", Host: "test"}
+	results := []crawler.Result{
+		result(entrypoint, "", "/1"),
+		result(entrypoint, "", "/2"),
+	}
+
+	type tcase struct {
+		name      string
+		formatter crawler.XMLSitemapFormatter
+		writer    io.Writer
+	}
+
+	cases := []tcase{
+		{
+			name:      "OnWriteError",
+			formatter: crawler.XMLSitemapFormatter{},
+			writer:    &explodingWriter{failOnCall: 1},
+		},
+		{
+			name:      "OnSplitWithoutCreate",
+			formatter: crawler.XMLSitemapFormatter{MaxURLs: 2},
+			writer:    &bytes.Buffer{},
+		},
+		{
+			name: "OnCreateError",
+			formatter: crawler.XMLSitemapFormatter{
+				MaxURLs: 2,
+				Create: func(string) (io.WriteCloser, error) {
+					return nil, errors.New("create error")
+				},
+			},
+			writer: &bytes.Buffer{},
+		},
+		{
+			name: "OnSitemapWriteError",
+			formatter: crawler.XMLSitemapFormatter{
+				MaxURLs: 2,
+				Create: func(string) (io.WriteCloser, error) {
+					return &fakeFile{writeErr: errors.New("write error")}, nil
+				},
+			},
+			writer: &bytes.Buffer{},
+		},
+		{
+			name: "OnSitemapCloseError",
+			formatter: crawler.XMLSitemapFormatter{
+				MaxURLs: 2,
+				Create: func(string) (io.WriteCloser, error) {
+					return &fakeFile{closeErr: errors.New("close error")}, nil
+				},
+			},
+			writer: &bytes.Buffer{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := make(chan crawler.Result, len(results))
+			for _, r := range results {
+				res <- r
+			}
+			close(res)
+
+			if err := c.formatter.Format(res, c.writer); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+type fakeFile struct {
+	bytes.Buffer
+	closed   bool
+	writeErr error
+	closeErr error
+}
+
+func (f *fakeFile) Write(d []byte) (int, error) {
+	if f.writeErr != nil {
+		return 0, f.writeErr
+	}
+	return f.Buffer.Write(d)
+}
+
+func (f *fakeFile) Close() error {
+	f.closed = true
+	return f.closeErr
+}
@@ -1,8 +1,15 @@
 module github.com/katcipis/crawler
 
+go 1.27.1
+
+require golang.org/x/net v0.0.0-20190213061140-3a22650c66bd
+
 require (
+	github.com/golang/protobuf v1.2.0 // indirect
 	github.com/google/pprof v0.0.0-20190208070709-b421f19a5c07 // indirect
 	golang.org/x/arch v0.0.0-20181203225421-5a4828bb7045 // indirect
-	golang.org/x/net v0.0.0-20190213061140-3a22650c66bd
+	golang.org/x/sync v0.0.0-20181108010431-42b317875d0f // indirect
+	golang.org/x/text v0.3.0 // indirect
 	golang.org/x/tools v0.0.0-20190221000707-a754db16a40a // indirect
+	google.golang.org/appengine v1.4.0 // indirect
 )
@@ -1,33 +1,201 @@
-// Package parser provides functions to parse HTML content
+// Package parser provides functions to parse HTML, robots.txt and sitemaps content
 package parser
 
 import (
 	"fmt"
 	"io"
 	"net/url"
+	"strings"
+	"unicode"
 
 	"golang.org/x/net/html"
 )
 
-// ExtractLinks will return a list of links extracted
-// from the given HTML body. If the content is not valid
+// LinkKind classifies what a link points to
+type LinkKind string
+
+const (
+	// PageLink is a link to another document, like <a href>
+	// or <iframe src>, that can be crawled.
+	PageLink LinkKind = "page"
+	// AssetLink is a link to a resource used by the document,
+	// like <img src> or <script src>.
+	AssetLink LinkKind = "asset"
+	// SitemapLink is a link to a sitemap, like the ones on a
+	// sitemap index or on the Sitemap lines of a robots.txt.
+	SitemapLink LinkKind = "sitemap"
+	// RedirectLink is a redirect from an URL to another one,
+	// like a 301 response with a Location header.
+	RedirectLink LinkKind = "redirect"
+)
+
+// Link is a link found on a HTML document
+type Link struct {
+	// URL is the URL of the link as found on the document
+	URL url.URL
+	// Kind is the kind of the link
+	Kind LinkKind
+	// Tag is the HTML element where the link was found, like "a"
+	Tag string
+	// Attr is the attribute where the link was found, like "href"
+	Attr string
+	// Rel are the lowercased values of the rel attribute, if any
+	Rel []string
+}
+
+// Page has all the information extracted from a HTML document
+type Page struct {
+	// Base is the URL of the first <base href> of the document,
+	// nil if the document has no base URL. It may be relative.
+	Base *url.URL
+	// Canonical is the URL of the first <link rel="canonical">
+	// of the document, nil if there is none. It may be relative.
+	Canonical *url.URL
+	// Links are all the links found on the document, in order
+	Links []Link
+	// Robots are the directives from <meta name="robots"> elements
+	Robots RobotsDirectives
+	// Title is the text of the <title> of the document
+	Title string
+	// Description is the content of the first <meta name="description">
+	Description string
+	// H1s are the texts of all the <h1> headings of the document, in order
+	H1s []string
+	// Lang is the lang attribute of the <html> element
+	Lang string
+	// Alternates are the translations of the document, from the
+	// <link rel="alternate" hreflang> elements. Their URLs may be relative.
+	Alternates []Alternate
+	// WordCount is the amount of words of the text of the document body,
+	// not counting the contents of scripts, styles and templates.
+	WordCount int
+}
+
+// Alternate is a translation of a HTML document, like the ones from
+// <link rel="alternate" hreflang="en" href="https://example.com/en">
+type Alternate struct {
+	// Lang is the language of the translation, like "en" or "x-default"
+	Lang string
+	// URL is the URL of the translation as found on the document
+	URL url.URL
+}
+
+// RobotsDirectives are the page level robots directives found on
+// <meta name="robots"> elements or X-Robots-Tag headers:
+//
+// https://developers.google.com/search/docs/crawling-indexing/robots-meta-tag
+type RobotsDirectives struct {
+	// NoIndex asks for the page to not be indexed
+	NoIndex bool
+	// NoFollow asks for the links of the page to not be followed
+	NoFollow bool
+}
+
+// Merge returns the union of both directives
+func (d RobotsDirectives) Merge(other RobotsDirectives) RobotsDirectives {
+	return RobotsDirectives{
+		NoIndex:  d.NoIndex || other.NoIndex,
+		NoFollow: d.NoFollow || other.NoFollow,
+	}
+}
+
+// ParseRobotsTag parses the value of a X-Robots-Tag header.
+// The value may be restricted to a user agent, like "otherbot: noindex",
+// and directives restricted to other user agents are ignored.
+// Only the product token of the given useragent is used to match.
+func ParseRobotsTag(useragent string, value string) RobotsDirectives {
+	if i := strings.Index(value, ":"); i >= 0 {
+		prefix := strings.ToLower(strings.TrimSpace(value[:i]))
+		if !strings.Contains(prefix, ",") && !robotsTagDirectivesWithValue[prefix] {
+			if prefix != productToken(useragent) {
+				return RobotsDirectives{}
+			}
+			value = value[i+1:]
+		}
+	}
+	return parseRobotsDirectives(value)
+}
+
+// robotsTagDirectivesWithValue are directives with values, like
+// "max-snippet: 20", that should not be mistaken by user agents.
+var robotsTagDirectivesWithValue = map[string]bool{
+	"unavailable_after": true,
+	"max-snippet":       true,
+	"max-image-preview": true,
+	"max-video-preview": true,
+}
+
+// ParsePage will parse the given HTML body returning all the
+// information extracted from it. If the content is not valid
 // HTML an error is returned instead.
-func ExtractLinks(htmlbody io.Reader) ([]url.URL, error) {
+//
+// Page links are extracted from <a href>, <area href>, <iframe src>,
+// <frame src>, <link href> with rel next, prev or HTML alternates and
+// <form action> of GET forms. Asset links are extracted from
+// <img src>, <img srcset>, <script src> and <link href> with
+// rel stylesheet or alternates of other types, like RSS feeds.
+//
+// Links are returned as found on the document, it is up to the
+// caller to resolve them against the document base URL.
+//
+// The metadata of the document, like its title, description, headings
+// and translations, is extracted too. Texts have their whitespace
+// collapsed, like browsers render them.
+func ParsePage(htmlbody io.Reader) (Page, error) {
 	doc, err := html.Parse(htmlbody)
 
 	if err != nil {
-		return nil, fmt.Errorf("parser.ExtractLinks: %s", err)
+		return Page{}, fmt.Errorf("parser.ParsePage: %s", err)
 	}
 
-	urls := []url.URL{}
+	return parseDocument(doc), nil
+}
+
+// parseDocument extracts the links and metadata
+// of the given parsed HTML document.
+func parseDocument(doc *html.Node) Page {
+	page := Page{Links: []Link{}, H1s: []string{}, Alternates: []Alternate{}}
+	hasTitle := false
+	hasDescription := false
 
 	var visit func(n *html.Node)
 
 	visit = func(n *html.Node) {
-		if isLink(n) {
-			extractedURL, ok := extractURL(n)
-			if ok {
-				urls = append(urls, extractedURL)
+		if n.Type == html.ElementNode {
+			if n.Data == "base" && page.Base == nil {
+				page.Base = extractURL(n, "href")
+			}
+			if isCanonical(n) && page.Canonical == nil {
+				page.Canonical = extractURL(n, "href")
+			}
+			if isRobotsMeta(n) {
+				directives := parseRobotsDirectives(attrValue(n, "content"))
+				page.Robots = page.Robots.Merge(directives)
+			}
+			page.Links = append(page.Links, extractLinks(n)...)
+
+			// WHY: Elements with a namespace are from SVG or MathML,
+			//      which have their own <title>, that is not the page title.
+			if n.Namespace == "" {
+				switch {
+				case n.Data == "html" && page.Lang == "":
+					page.Lang = strings.TrimSpace(attrValue(n, "lang"))
+				case n.Data == "title" && !hasTitle:
+					hasTitle = true
+					page.Title = textContent(n)
+				case n.Data == "h1":
+					page.H1s = append(page.H1s, textContent(n))
+				case isDescriptionMeta(n) && !hasDescription:
+					hasDescription = true
+					page.Description = collapseSpaces(attrValue(n, "content"))
+				case isAlternate(n):
+					if alternate, ok := extractAlternate(n); ok {
+						page.Alternates = append(page.Alternates, alternate)
+					}
+				}
+			}
+			if n.Data == "body" {
+				page.WordCount += countWords(n)
 			}
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -37,27 +205,317 @@ func ExtractLinks(htmlbody io.Reader) ([]url.URL, error) {
 
 	visit(doc)
 
+	return page
+}
+
+// ExtractLinks will return a list of page links extracted
+// from the given HTML body. If the content is not valid
+// HTML an error is returned instead.
+//
+// Use ParsePage to get all the links with their kinds.
+func ExtractLinks(htmlbody io.Reader) ([]url.URL, error) {
+	doc, err := html.Parse(htmlbody)
+	if err != nil {
+		return nil, fmt.Errorf("parser.ExtractLinks: %s", err)
+	}
+
+	urls := []url.URL{}
+	for _, link := range parseDocument(doc).Links {
+		if link.Kind == PageLink {
+			urls = append(urls, link.URL)
+		}
+	}
+
 	return urls, nil
 }
 
-func extractURL(linkNode *html.Node) (url.URL, bool) {
-	for _, attr := range linkNode.Attr {
-		if attr.Key == "href" {
-			u, err := url.Parse(attr.Val)
-			if err != nil {
-				return url.URL{}, false
+func isRobotsMeta(n *html.Node) bool {
+	return n.Data == "meta" && strings.ToLower(attrValue(n, "name")) == "robots"
+}
+
+func parseRobotsDirectives(content string) RobotsDirectives {
+	directives := RobotsDirectives{}
+
+	for _, directive := range strings.Split(content, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			directives.NoIndex = true
+		case "nofollow":
+			directives.NoFollow = true
+		case "none":
+			directives.NoIndex = true
+			directives.NoFollow = true
+		}
+	}
+
+	return directives
+}
+
+func isDescriptionMeta(n *html.Node) bool {
+	return n.Data == "meta" && strings.ToLower(attrValue(n, "name")) == "description"
+}
+
+func isAlternate(n *html.Node) bool {
+	return n.Data == "link" && hasAnyRel(relValues(n), "alternate") && attrValue(n, "hreflang") != ""
+}
+
+func extractAlternate(n *html.Node) (Alternate, bool) {
+	u, ok := parseURL(attrValue(n, "href"))
+	if !ok {
+		return Alternate{}, false
+	}
+	return Alternate{Lang: strings.TrimSpace(attrValue(n, "hreflang")), URL: u}, true
+}
+
+// textContent returns the text inside the given
+// node, with its whitespace collapsed.
+func textContent(n *html.Node) string {
+	var text strings.Builder
+
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(n)
+
+	return collapseSpaces(text.String())
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// countWords counts the words of the text inside the given node,
+// ignoring elements whose contents are not rendered as text.
+func countWords(n *html.Node) int {
+	if n.Type == html.ElementNode && textlessElements[n.Data] {
+		return 0
+	}
+	if n.Type == html.TextNode {
+		count := 0
+		for _, field := range strings.Fields(n.Data) {
+			// WHY: Punctuation alone, like the dot after a link, is not a word
+			if strings.IndexFunc(field, isWordRune) >= 0 {
+				count++
 			}
-			// WHY: Got some empty URLs being parsed on wikipedia.org
-			if u.String() == "" {
-				return url.URL{}, false
+		}
+		return count
+	}
+
+	count := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		count += countWords(c)
+	}
+	return count
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// textlessElements are elements whose contents are not rendered as text
+var textlessElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"template": true,
+	"noscript": true,
+}
+
+func isCanonical(n *html.Node) bool {
+	return n.Data == "link" && hasAnyRel(relValues(n), "canonical")
+}
+
+func extractURL(n *html.Node, attr string) *url.URL {
+	u, ok := parseURL(attrValue(n, attr))
+	if !ok {
+		return nil
+	}
+	return &u
+}
+
+func extractLinks(n *html.Node) []Link {
+	rel := relValues(n)
+
+	switch n.Data {
+	case "a", "area":
+		return extractLink(n, PageLink, "href", rel)
+	case "iframe", "frame":
+		return extractLink(n, PageLink, "src", rel)
+	case "form":
+		method := strings.ToLower(attrValue(n, "method"))
+		if method == "" || method == "get" {
+			return extractLink(n, PageLink, "action", rel)
+		}
+	case "link":
+		switch {
+		// WHY: Alternate stylesheets have both rels
+		case hasAnyRel(rel, "stylesheet"):
+			return extractLink(n, AssetLink, "href", rel)
+		case hasAnyRel(rel, "next", "prev"):
+			return extractLink(n, PageLink, "href", rel)
+		case hasAnyRel(rel, "alternate"):
+			if isHTMLAlternate(n) {
+				return extractLink(n, PageLink, "href", rel)
 			}
-			return *u, true
+			return extractLink(n, AssetLink, "href", rel)
 		}
+	case "script":
+		return extractLink(n, AssetLink, "src", rel)
+	case "img":
+		links := extractLink(n, AssetLink, "src", rel)
+		return append(links, extractSrcset(n, rel)...)
 	}
+	return nil
+}
 
-	return url.URL{}, false
+// isHTMLAlternate returns true if the given <link rel=alternate> is
+// another HTML version of the page, like a translation, instead of its
+// content on another format, like a RSS feed. Alternates with no type
+// are assumed to be HTML.
+func isHTMLAlternate(n *html.Node) bool {
+	mediaType := strings.SplitN(attrValue(n, "type"), ";", 2)[0]
+	switch strings.ToLower(strings.TrimSpace(mediaType)) {
+	case "", "text/html", "application/xhtml+xml":
+		return true
+	}
+	return false
 }
 
-func isLink(n *html.Node) bool {
-	return n.Type == html.ElementNode && n.Data == "a"
+func extractLink(n *html.Node, kind LinkKind, attr string, rel []string) []Link {
+	for _, a := range n.Attr {
+		if a.Key != attr {
+			continue
+		}
+		u, ok := parseURL(a.Val)
+		if !ok {
+			return nil
+		}
+		return []Link{{
+			URL:  u,
+			Kind: kind,
+			Tag:  n.Data,
+			Attr: attr,
+			Rel:  rel,
+		}}
+	}
+	return nil
+}
+
+// extractSrcset extracts all the image candidates URLs
+// from a srcset, like "small.jpg 1x, large.jpg 2x".
+func extractSrcset(n *html.Node, rel []string) []Link {
+	links := []Link{}
+
+	for _, rawurl := range srcsetURLs(attrValue(n, "srcset")) {
+		u, ok := parseURL(rawurl)
+		if !ok {
+			continue
+		}
+		links = append(links, Link{
+			URL:  u,
+			Kind: AssetLink,
+			Tag:  n.Data,
+			Attr: "srcset",
+			Rel:  rel,
+		})
+	}
+
+	return links
+}
+
+// srcsetURLs returns the URLs of the image candidates of a srcset,
+// parsed as the HTML spec does. The URL of each candidate ends on
+// whitespace, so it may have commas, like data URIs, and its
+// descriptors end on a comma outside of parentheses.
+//
+// https://html.spec.whatwg.org/multipage/images.html#parse-a-srcset-attribute
+func srcsetURLs(srcset string) []string {
+	urls := []string{}
+
+	for {
+		srcset = strings.TrimLeft(srcset, srcsetSeparators)
+		if srcset == "" {
+			return urls
+		}
+
+		end := strings.IndexAny(srcset, srcsetSpaces)
+		if end < 0 {
+			end = len(srcset)
+		}
+		rawurl := srcset[:end]
+		srcset = srcset[end:]
+
+		// WHY: An URL ending in commas has no descriptors,
+		//      the commas separate it from the next candidate.
+		if trimmed := strings.TrimRight(rawurl, ","); trimmed != rawurl {
+			urls = append(urls, trimmed)
+			continue
+		}
+		urls = append(urls, rawurl)
+		srcset = skipSrcsetDescriptors(srcset)
+	}
+}
+
+// skipSrcsetDescriptors returns the srcset after the
+// descriptors of a candidate and the comma ending them.
+func skipSrcsetDescriptors(srcset string) string {
+	inParens := false
+
+	for i, c := range srcset {
+		switch {
+		case c == '(':
+			inParens = true
+		case c == ')':
+			inParens = false
+		case c == ',' && !inParens:
+			return srcset[i+1:]
+		}
+	}
+	return ""
+}
+
+// srcsetSpaces are the ASCII whitespace of the HTML spec
+const srcsetSpaces = " \t\n\f\r"
+
+const srcsetSeparators = srcsetSpaces + ","
+
+func parseURL(rawurl string) (url.URL, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawurl))
+	if err != nil {
+		return url.URL{}, false
+	}
+	// WHY: Got some empty URLs being parsed on wikipedia.org
+	if u.String() == "" {
+		return url.URL{}, false
+	}
+	return *u, true
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func relValues(n *html.Node) []string {
+	return strings.Fields(strings.ToLower(attrValue(n, "rel")))
+}
+
+func hasAnyRel(rel []string, values ...string) bool {
+	for _, r := range rel {
+		for _, v := range values {
+			if r == v {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -3,6 +3,7 @@ package parser_test
 import (
 	"errors"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -128,11 +129,447 @@ func TestExtractLinks(t *testing.T) {
 	}
 }
 
+func TestExtractLinksIgnoresAssets(t *testing.T) {
+	const body = `
+		<img src="/image.png">
+		<a href="/page"></a>
+		<script src="/script.js"></script>
+		<iframe src="/frame"></iframe>
+	`
+
+	gotURLs, err := parser.ExtractLinks(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := urlsAsStrings(gotURLs)
+	want := []string{"/page", "/frame"}
+
+	if strings.Join(want, ",") != strings.Join(got, ",") {
+		t.Fatalf("want '%s' != got '%s'", want, got)
+	}
+}
+
+func TestParsePageLinks(t *testing.T) {
+	type tcase struct {
+		name string
+		html string
+		want []parser.Link
+	}
+
+	cases := []tcase{
+		{
+			name: "empty",
+			html: "",
+			want: []parser.Link{},
+		},
+		{
+			name: "anchor",
+			html: `<a href="/page" rel="NoFollow external"></a>`,
+			want: []parser.Link{
+				link("/page", parser.PageLink, "a", "href", "nofollow", "external"),
+			},
+		},
+		{
+			name: "pages",
+			html: `
+				<map><area href="/area"></map>
+				<iframe src="/iframe"></iframe>
+				<form action="/search"></form>
+				<form action="/get" method="GET"></form>
+				<form action="/post" method="post"></form>
+			`,
+			want: []parser.Link{
+				link("/area", parser.PageLink, "area", "href"),
+				link("/iframe", parser.PageLink, "iframe", "src"),
+				link("/search", parser.PageLink, "form", "action"),
+				link("/get", parser.PageLink, "form", "action"),
+			},
+		},
+		{
+			name: "frames",
+			html: `
+				<html>
+					<frameset>
+						<frame src="/frame1">
+						<frame src="/frame2">
+					</frameset>
+				</html>
+			`,
+			want: []parser.Link{
+				link("/frame1", parser.PageLink, "frame", "src"),
+				link("/frame2", parser.PageLink, "frame", "src"),
+			},
+		},
+		{
+			name: "linkRels",
+			html: `
+				<head>
+					<link rel="alternate" hreflang="pt" href="/pt">
+					<link rel="next" href="/page/2">
+					<link rel="prev" href="/page/0">
+					<link rel="stylesheet" href="/style.css">
+					<link rel="icon" href="/favicon.ico">
+					<link rel="alternate" href="/mobile">
+					<link rel="alternate" type="text/html; charset=utf-8" href="/print">
+					<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+					<link rel="alternate stylesheet" href="/dark.css">
+				</head>
+			`,
+			want: []parser.Link{
+				link("/pt", parser.PageLink, "link", "href", "alternate"),
+				link("/page/2", parser.PageLink, "link", "href", "next"),
+				link("/page/0", parser.PageLink, "link", "href", "prev"),
+				link("/style.css", parser.AssetLink, "link", "href", "stylesheet"),
+				link("/mobile", parser.PageLink, "link", "href", "alternate"),
+				link("/print", parser.PageLink, "link", "href", "alternate"),
+				link("/feed.xml", parser.AssetLink, "link", "href", "alternate"),
+				link("/dark.css", parser.AssetLink, "link", "href", "alternate", "stylesheet"),
+			},
+		},
+		{
+			name: "assets",
+			html: `
+				<script src="/script.js"></script>
+				<script>var inline = true;</script>
+				<img src="/image.png" srcset="/small.png 1x, /large.png 2x,,/huge.png">
+			`,
+			want: []parser.Link{
+				link("/script.js", parser.AssetLink, "script", "src"),
+				link("/image.png", parser.AssetLink, "img", "src"),
+				link("/small.png", parser.AssetLink, "img", "srcset"),
+				link("/large.png", parser.AssetLink, "img", "srcset"),
+				link("/huge.png", parser.AssetLink, "img", "srcset"),
+			},
+		},
+		{
+			name: "srcsetCandidates",
+			html: `
+				<img srcset="
+					data:image/png;base64,iVBORw0KGgo= 1x,
+					/image,v2.png 2x,/comma.png,
+					/parens.png 100w (a, b),
+					/last.png"
+				>
+			`,
+			want: []parser.Link{
+				link("data:image/png;base64,iVBORw0KGgo=", parser.AssetLink, "img", "srcset"),
+				link("/image,v2.png", parser.AssetLink, "img", "srcset"),
+				link("/comma.png", parser.AssetLink, "img", "srcset"),
+				link("/parens.png", parser.AssetLink, "img", "srcset"),
+				link("/last.png", parser.AssetLink, "img", "srcset"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			page, err := parser.ParsePage(strings.NewReader(c.html))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if len(c.want) != len(page.Links) {
+				t.Fatalf("want '%+v' != got '%+v'", c.want, page.Links)
+			}
+
+			for i, want := range c.want {
+				got := page.Links[i]
+				if want.URL.String() != got.URL.String() ||
+					want.Kind != got.Kind ||
+					want.Tag != got.Tag ||
+					want.Attr != got.Attr ||
+					strings.Join(want.Rel, " ") != strings.Join(got.Rel, " ") {
+					t.Errorf("want[%+v] != got[%+v] at index[%d]", want, got, i)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePageBase(t *testing.T) {
+	type tcase struct {
+		name string
+		html string
+		want string
+	}
+
+	cases := []tcase{
+		{
+			name: "noBase",
+			html: `<a href="/test"></a>`,
+		},
+		{
+			name: "baseWithoutHref",
+			html: `<head><base target="_blank"></head>`,
+		},
+		{
+			name: "absoluteBase",
+			html: `<head><base href="https://example.com/docs/"></head>`,
+			want: "https://example.com/docs/",
+		},
+		{
+			name: "relativeBase",
+			html: `<head><base href="/docs/"></head>`,
+			want: "/docs/",
+		},
+		{
+			name: "firstBaseWins",
+			html: `<head><base href="/first/"><base href="/second/"></head>`,
+			want: "/first/",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			page, err := parser.ParsePage(strings.NewReader(c.html))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if c.want == "" {
+				if page.Base != nil {
+					t.Fatalf("expected no base, got[%s]", page.Base)
+				}
+				return
+			}
+
+			if page.Base == nil {
+				t.Fatalf("expected base[%s], got none", c.want)
+			}
+
+			if got := page.Base.String(); got != c.want {
+				t.Fatalf("want base[%s] != got[%s]", c.want, got)
+			}
+		})
+	}
+}
+
+func TestParsePageCanonical(t *testing.T) {
+	const body = `
+		<head>
+			<link rel="alternate" href="/alternate">
+			<link rel="Canonical" href="/canonical">
+			<link rel="canonical" href="/other">
+		</head>
+	`
+
+	page, err := parser.ParsePage(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if page.Canonical == nil {
+		t.Fatal("expected canonical URL, got none")
+	}
+
+	const want = "/canonical"
+	if got := page.Canonical.String(); got != want {
+		t.Fatalf("want canonical[%s] != got[%s]", want, got)
+	}
+}
+
+func TestParsePageRobots(t *testing.T) {
+	type tcase struct {
+		name string
+		html string
+		want parser.RobotsDirectives
+	}
+
+	cases := []tcase{
+		{
+			name: "noMeta",
+			html: `<head><meta name="description" content="noindex"></head>`,
+		},
+		{
+			name: "noindex",
+			html: `<head><meta name="robots" content="noindex"></head>`,
+			want: parser.RobotsDirectives{NoIndex: true},
+		},
+		{
+			name: "nofollow",
+			html: `<head><meta name="ROBOTS" content="NoFollow"></head>`,
+			want: parser.RobotsDirectives{NoFollow: true},
+		},
+		{
+			name: "both",
+			html: `<head><meta name="robots" content="noindex, nofollow"></head>`,
+			want: parser.RobotsDirectives{NoIndex: true, NoFollow: true},
+		},
+		{
+			name: "none",
+			html: `<head><meta name="robots" content="none"></head>`,
+			want: parser.RobotsDirectives{NoIndex: true, NoFollow: true},
+		},
+		{
+			name: "multipleMetas",
+			html: `
+				<head>
+					<meta name="robots" content="noindex">
+					<meta name="robots" content="max-snippet:20, nofollow">
+				</head>
+			`,
+			want: parser.RobotsDirectives{NoIndex: true, NoFollow: true},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			page, err := parser.ParsePage(strings.NewReader(c.html))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if c.want != page.Robots {
+				t.Fatalf("want[%+v] != got[%+v]", c.want, page.Robots)
+			}
+		})
+	}
+}
+
+func TestParsePageMetadata(t *testing.T) {
+	const body = `
+		<html lang="pt-BR">
+		<head>
+			<title>
+				Home   of the
+				Crawler
+			</title>
+			<title>Other title</title>
+			<meta name="Description" content=" A  concurrent crawler ">
+			<meta name="description" content="other description">
+			<link rel="alternate" hreflang="en" href="/en">
+			<link rel="alternate" hreflang="x-default" href="https://example.com/">
+			<link rel="alternate" href="/feed.xml">
+		</head>
+		<body>
+			<svg><title>Icon</title></svg>
+			<h1>Welcome to <b>the</b> crawler</h1>
+			<p>It crawls sites, <a href="/a">fast</a>.</p>
+			<script>var ignored = "some words";</script>
+			<style>p { color: red; }</style>
+			<h1>News</h1>
+		</body>
+		</html>
+	`
+
+	page, err := parser.ParsePage(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := "Home of the Crawler"; page.Title != want {
+		t.Errorf("want title[%s] != got[%s]", want, page.Title)
+	}
+	if want := "A concurrent crawler"; page.Description != want {
+		t.Errorf("want description[%s] != got[%s]", want, page.Description)
+	}
+	if want := "pt-BR"; page.Lang != want {
+		t.Errorf("want lang[%s] != got[%s]", want, page.Lang)
+	}
+	if want := []string{"Welcome to the crawler", "News"}; !reflect.DeepEqual(want, page.H1s) {
+		t.Errorf("want h1s%q != got%q", want, page.H1s)
+	}
+
+	wantAlternates := []parser.Alternate{
+		{Lang: "en", URL: url.URL{Path: "/en"}},
+		{Lang: "x-default", URL: url.URL{Scheme: "https", Host: "example.com", Path: "/"}},
+	}
+	if !reflect.DeepEqual(wantAlternates, page.Alternates) {
+		t.Errorf("want alternates[%+v] != got[%+v]", wantAlternates, page.Alternates)
+	}
+
+	// WHY: Icon, Welcome to the crawler, It crawls sites, fast., News
+	const wantWords = 10
+	if page.WordCount != wantWords {
+		t.Errorf("want word count[%d] != got[%d]", wantWords, page.WordCount)
+	}
+}
+
+func TestParsePageWithoutMetadata(t *testing.T) {
+	page, err := parser.ParsePage(strings.NewReader(`<p>no metadata</p>`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := parser.Page{
+		Links:      []parser.Link{},
+		H1s:        []string{},
+		Alternates: []parser.Alternate{},
+		WordCount:  2,
+	}
+	if !reflect.DeepEqual(want, page) {
+		t.Fatalf("want[%+v] != got[%+v]", want, page)
+	}
+}
+
+func TestParseRobotsTag(t *testing.T) {
+	type tcase struct {
+		name  string
+		value string
+		want  parser.RobotsDirectives
+	}
+
+	const useragent = "crawler/1.0"
+
+	cases := []tcase{
+		{
+			name:  "empty",
+			value: "",
+		},
+		{
+			name:  "all",
+			value: "all",
+		},
+		{
+			name:  "noindex",
+			value: "noindex",
+			want:  parser.RobotsDirectives{NoIndex: true},
+		},
+		{
+			name:  "multiple",
+			value: "noindex, nofollow",
+			want:  parser.RobotsDirectives{NoIndex: true, NoFollow: true},
+		},
+		{
+			name:  "directiveWithValue",
+			value: "unavailable_after: 25 Jun 2010 15:00:00 PST",
+		},
+		{
+			name:  "directiveWithValueAndNoIndex",
+			value: "max-snippet: 20, noindex",
+			want:  parser.RobotsDirectives{NoIndex: true},
+		},
+		{
+			name:  "sameUserAgent",
+			value: "Crawler: nofollow",
+			want:  parser.RobotsDirectives{NoFollow: true},
+		},
+		{
+			name:  "otherUserAgent",
+			value: "otherbot: noindex, nofollow",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := parser.ParseRobotsTag(useragent, c.value)
+			if c.want != got {
+				t.Fatalf("want[%+v] != got[%+v]", c.want, got)
+			}
+		})
+	}
+}
+
 func TestExtractLinksFailsOnReadError(t *testing.T) {
 	res, err := parser.ExtractLinks(&explodingReader{})
 	if err == nil {
 		t.Fatalf("expected error, instead got valid result: %v", res)
 	}
+
+	const want = "parser.ExtractLinks: explodingReader error"
+	if err.Error() != want {
+		t.Fatalf("want error[%s] != got[%s]", want, err)
+	}
 }
 
 type explodingReader struct{}
@@ -141,6 +578,20 @@ func (*explodingReader) Read([]byte) (int, error) {
 	return 0, errors.New("explodingReader error")
 }
 
+func link(rawurl string, kind parser.LinkKind, tag string, attr string, rel ...string) parser.Link {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		panic(err)
+	}
+	return parser.Link{
+		URL:  *u,
+		Kind: kind,
+		Tag:  tag,
+		Attr: attr,
+		Rel:  rel,
+	}
+}
+
 func urlsAsStrings(urls []url.URL) []string {
 	urlsStr := make([]string, len(urls))
 	for i, url := range urls {
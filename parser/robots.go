@@ -0,0 +1,306 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots represents the rules parsed from a robots.txt file,
+// following the Robots Exclusion Protocol:
+//
+// https://www.rfc-editor.org/rfc/rfc9309.html
+//
+// The zero value of Robots allows everything.
+type Robots struct {
+	groups   []robotsGroup
+	sitemaps []url.URL
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	matcher *regexp.Regexp
+}
+
+// maxRobotsLineSize is the size of the longest robots.txt line
+// that is parsed, longer lines are ignored as malformed lines.
+const maxRobotsLineSize = 64 * 1024
+
+// ParseRobots will parse the given robots.txt body.
+// Unknown directives and malformed lines are ignored, as
+// recommended by the specification, so an error is only
+// returned when it is not possible to read the body.
+func ParseRobots(body io.Reader) (Robots, error) {
+	robots := Robots{}
+	reader := bufio.NewReaderSize(body, maxRobotsLineSize)
+
+	var group *robotsGroup
+	groupHasRules := false
+
+	for {
+		line, err := readRobotsLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Robots{}, fmt.Errorf("parser.ParseRobots: %s", err)
+		}
+
+		key, val, ok := parseRobotsLine(line)
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "user-agent":
+			{
+				if group == nil || groupHasRules {
+					robots.groups = append(robots.groups, robotsGroup{})
+					group = &robots.groups[len(robots.groups)-1]
+					groupHasRules = false
+				}
+				group.agents = append(group.agents, strings.ToLower(val))
+			}
+		case "allow", "disallow":
+			{
+				if group == nil {
+					continue
+				}
+				groupHasRules = true
+				// WHY: An empty disallow means everything is allowed
+				if val == "" {
+					continue
+				}
+				group.rules = append(group.rules, newRobotsRule(key == "allow", val))
+			}
+		case "crawl-delay":
+			{
+				if group == nil {
+					continue
+				}
+				groupHasRules = true
+				secs, err := strconv.ParseFloat(val, 64)
+				if err != nil || secs < 0 {
+					continue
+				}
+				group.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		case "sitemap":
+			{
+				// WHY: Sitemaps are not part of any group and
+				//      their URLs must be absolute.
+				u, err := url.Parse(val)
+				if err != nil || !u.IsAbs() {
+					continue
+				}
+				robots.sitemaps = append(robots.sitemaps, *u)
+			}
+		}
+	}
+
+	return robots, nil
+}
+
+// readRobotsLine reads the next line of the robots.txt body.
+// Lines longer than maxRobotsLineSize are read until their end
+// and returned empty, so they are ignored as malformed lines.
+func readRobotsLine(reader *bufio.Reader) (string, error) {
+	line, tooLong, err := reader.ReadLine()
+	if err != nil {
+		return "", err
+	}
+
+	for tooLong {
+		line = nil
+		_, tooLong, err = reader.ReadLine()
+		// WHY: The body may end in the middle of a long line
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return string(line), nil
+}
+
+// Sitemaps returns the URLs of the Sitemap lines, in order
+func (r Robots) Sitemaps() []url.URL {
+	return r.sitemaps
+}
+
+// Allowed returns true if the given useragent is allowed to fetch
+// the given URL. Only the product token of the useragent is used
+// to match the robots.txt groups, so "crawler/1.0" matches
+// the "crawler" group. If no group matches the useragent the
+// rules from the "*" group are used.
+//
+// When multiple rules match the URL the most specific one (longest)
+// wins, if an allow and a disallow rule are equally specific the
+// allow rule wins.
+func (r Robots) Allowed(useragent string, u url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	path = normalizeRobotsPath(path)
+
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allowed := true
+	matchedLen := -1
+
+	for _, rule := range r.rules(useragent) {
+		if !rule.matcher.MatchString(path) {
+			continue
+		}
+		ruleLen := len(rule.pattern)
+		if ruleLen > matchedLen || (ruleLen == matchedLen && rule.allow) {
+			allowed = rule.allow
+			matchedLen = ruleLen
+		}
+	}
+
+	return allowed
+}
+
+// CrawlDelay returns the Crawl-delay directive that applies to
+// the given useragent, if there is one. Crawl-delay is not part of
+// RFC 9309 but it is widely used to ask crawlers to wait a minimum
+// amount of time between requests.
+func (r Robots) CrawlDelay(useragent string) (time.Duration, bool) {
+	found := false
+	delay := time.Duration(0)
+
+	for _, group := range r.matchGroups(useragent) {
+		if group.crawlDelay > delay {
+			delay = group.crawlDelay
+			found = true
+		}
+	}
+
+	return delay, found
+}
+
+func (r Robots) rules(useragent string) []robotsRule {
+	rules := []robotsRule{}
+	for _, group := range r.matchGroups(useragent) {
+		rules = append(rules, group.rules...)
+	}
+	return rules
+}
+
+func (r Robots) matchGroups(useragent string) []robotsGroup {
+	token := productToken(useragent)
+	matched := []robotsGroup{}
+	fallback := []robotsGroup{}
+
+	for _, group := range r.groups {
+		for _, agent := range group.agents {
+			if agent == token {
+				matched = append(matched, group)
+				break
+			}
+			if agent == "*" {
+				fallback = append(fallback, group)
+				break
+			}
+		}
+	}
+
+	if len(matched) > 0 {
+		return matched
+	}
+	return fallback
+}
+
+func productToken(useragent string) string {
+	token := strings.SplitN(useragent, "/", 2)[0]
+	return strings.ToLower(strings.TrimSpace(token))
+}
+
+func parseRobotsLine(line string) (string, string, bool) {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	key := strings.ToLower(strings.TrimSpace(parts[0]))
+	val := strings.TrimSpace(parts[1])
+	return key, val, true
+}
+
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	pattern = normalizeRobotsPath(pattern)
+	anchored := strings.HasSuffix(pattern, "$")
+	expr := strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(expr, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	expr = "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+
+	return robotsRule{
+		allow:   allow,
+		pattern: pattern,
+		matcher: regexp.MustCompile(expr),
+	}
+}
+
+// normalizeRobotsPath percent-encodes the octets that are not
+// US-ASCII and uppercases the hex digits of percent-encoded octets,
+// so rules and paths are compared the same way, as RFC 9309 asks:
+// "/café" matches "/caf%C3%A9" and "/caf%c3%a9".
+func normalizeRobotsPath(path string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c >= 0x80:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0x0F])
+		case c == '%' && i+2 < len(path) && isHex(path[i+1]) && isHex(path[i+2]):
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(path[i+1 : i+3]))
+			i += 2
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
@@ -0,0 +1,314 @@
+package parser_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/katcipis/crawler/parser"
+)
+
+func TestRobotsAllowed(t *testing.T) {
+	type tcase struct {
+		name      string
+		robots    string
+		useragent string
+		allowed   []string
+		disallow  []string
+	}
+
+	cases := []tcase{
+		{
+			name:      "empty",
+			robots:    "",
+			useragent: "crawler",
+			allowed:   []string{"/", "/test", "/test?q=1"},
+		},
+		{
+			name: "disallowAll",
+			robots: `
+				User-agent: *
+				Disallow: /
+			`,
+			useragent: "crawler",
+			allowed:   []string{"/robots.txt"},
+			disallow:  []string{"/", "/test", "/test/nested.html"},
+		},
+		{
+			name: "emptyDisallowAllowsAll",
+			robots: `
+				User-agent: *
+				Disallow:
+			`,
+			useragent: "crawler",
+			allowed:   []string{"/", "/test"},
+		},
+		{
+			name: "longestMatchWins",
+			robots: `
+				User-agent: *
+				Disallow: /private
+				Allow: /private/public
+			`,
+			useragent: "crawler",
+			allowed:   []string{"/", "/public", "/private/public", "/private/public/page.html"},
+			disallow:  []string{"/private", "/private/", "/private/secret.html", "/privateer"},
+		},
+		{
+			name: "allowWinsOnTie",
+			robots: `
+				User-agent: *
+				Disallow: /page
+				Allow: /page
+			`,
+			useragent: "crawler",
+			allowed:   []string{"/page"},
+		},
+		{
+			name: "wildcards",
+			robots: `
+				User-agent: *
+				Disallow: /*.pdf$
+				Disallow: /*/drafts/
+				Disallow: /search?*q=
+			`,
+			useragent: "crawler",
+			allowed:   []string{"/file.pdf.html", "/drafts/", "/search", "/search?page=1"},
+			disallow:  []string{"/file.pdf", "/docs/file.pdf", "/blog/drafts/post", "/search?q=go", "/search?page=1&q=go"},
+		},
+		{
+			name: "percentEncoding",
+			robots: `
+				User-agent: *
+				Disallow: /café
+				Disallow: /ツ/%e3%83%84
+				Disallow: /search?q=é
+			`,
+			useragent: "crawler",
+			allowed:   []string{"/cafe", "/%E3%83%84", "/search?q=e"},
+			disallow:  []string{"/café", "/caf%C3%A9", "/caf%c3%a9/menu", "/ツ/ツ", "/%E3%83%84/%E3%83%84", "/search?q=%C3%A9"},
+		},
+		{
+			name: "specificUserAgentGroup",
+			robots: `
+				User-agent: *
+				Disallow: /
+
+				User-agent: Crawler
+				Disallow: /private
+			`,
+			useragent: "crawler/1.0",
+			allowed:   []string{"/", "/public"},
+			disallow:  []string{"/private"},
+		},
+		{
+			name: "fallbackUserAgentGroup",
+			robots: `
+				User-agent: otherbot
+				Disallow: /
+
+				User-agent: *
+				Disallow: /private
+			`,
+			useragent: "crawler/1.0",
+			allowed:   []string{"/", "/public"},
+			disallow:  []string{"/private"},
+		},
+		{
+			name: "groupWithMultipleUserAgents",
+			robots: `
+				User-agent: otherbot
+				User-agent: crawler
+				Disallow: /private # comments are ignored
+			`,
+			useragent: "crawler",
+			allowed:   []string{"/", "/public"},
+			disallow:  []string{"/private"},
+		},
+		{
+			name: "ignoresRulesWithoutUserAgent",
+			robots: `
+				Disallow: /
+				invalid line
+				Unknown: directive
+			`,
+			useragent: "crawler",
+			allowed:   []string{"/", "/public"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			robots, err := parser.ParseRobots(strings.NewReader(c.robots))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			for _, path := range c.allowed {
+				if !robots.Allowed(c.useragent, parseURL(t, path)) {
+					t.Errorf("expected path[%s] to be allowed", path)
+				}
+			}
+
+			for _, path := range c.disallow {
+				if robots.Allowed(c.useragent, parseURL(t, path)) {
+					t.Errorf("expected path[%s] to be disallowed", path)
+				}
+			}
+		})
+	}
+}
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	type tcase struct {
+		name      string
+		robots    string
+		useragent string
+		want      time.Duration
+		wantFound bool
+	}
+
+	cases := []tcase{
+		{
+			name:      "empty",
+			robots:    "",
+			useragent: "crawler",
+		},
+		{
+			name: "seconds",
+			robots: `
+				User-agent: *
+				Crawl-delay: 10
+			`,
+			useragent: "crawler",
+			want:      10 * time.Second,
+			wantFound: true,
+		},
+		{
+			name: "fraction",
+			robots: `
+				User-agent: *
+				Crawl-delay: 0.5
+			`,
+			useragent: "crawler",
+			want:      500 * time.Millisecond,
+			wantFound: true,
+		},
+		{
+			name: "specificUserAgent",
+			robots: `
+				User-agent: *
+				Crawl-delay: 10
+
+				User-agent: crawler
+				Crawl-delay: 2
+				Disallow: /private
+			`,
+			useragent: "crawler/1.0",
+			want:      2 * time.Second,
+			wantFound: true,
+		},
+		{
+			name: "otherUserAgent",
+			robots: `
+				User-agent: otherbot
+				Crawl-delay: 10
+			`,
+			useragent: "crawler",
+		},
+		{
+			name: "invalid",
+			robots: `
+				User-agent: *
+				Crawl-delay: soon
+			`,
+			useragent: "crawler",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			robots, err := parser.ParseRobots(strings.NewReader(c.robots))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			got, found := robots.CrawlDelay(c.useragent)
+			if found != c.wantFound {
+				t.Fatalf("want found[%t] != got found[%t]", c.wantFound, found)
+			}
+			if got != c.want {
+				t.Fatalf("want delay[%s] != got delay[%s]", c.want, got)
+			}
+		})
+	}
+}
+
+func TestRobotsSitemaps(t *testing.T) {
+	robots, err := parser.ParseRobots(strings.NewReader(`
+		Sitemap: https://example.com/sitemap.xml
+		User-agent: *
+		Disallow: /private
+		sitemap: https://cdn.example.com/sitemap-2.xml.gz # comment
+		Sitemap: /relative.xml
+		Sitemap:
+	`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{
+		"https://example.com/sitemap.xml",
+		"https://cdn.example.com/sitemap-2.xml.gz",
+	}
+	got := robots.Sitemaps()
+	if len(got) != len(want) {
+		t.Fatalf("want sitemaps %v, got %v", want, got)
+	}
+	for i, u := range got {
+		if u.String() != want[i] {
+			t.Fatalf("want sitemaps %v, got %v", want, got)
+		}
+	}
+
+	if !robots.Allowed("crawler", parseURL(t, "https://example.com/sitemap.xml")) {
+		t.Fatal("sitemap lines must not end the group of rules")
+	}
+}
+
+func TestParseRobotsIgnoresLongLines(t *testing.T) {
+	robots, err := parser.ParseRobots(strings.NewReader(
+		"User-agent: *\n" +
+			"# " + strings.Repeat("a", 100*1024) + "\n" +
+			"Disallow: /" + strings.Repeat("b", 100*1024) + "\n" +
+			"Disallow: /private\n",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if robots.Allowed("crawler", parseURL(t, "https://example.com/private")) {
+		t.Fatal("rules after long lines must be parsed")
+	}
+	if !robots.Allowed("crawler", parseURL(t, "https://example.com/"+strings.Repeat("b", 100*1024))) {
+		t.Fatal("rules in long lines must be ignored")
+	}
+}
+
+func TestParseRobotsFailsOnReadError(t *testing.T) {
+	_, err := parser.ParseRobots(&explodingReader{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func parseURL(t *testing.T, rawurl string) url.URL {
+	t.Helper()
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		t.Fatalf("error[%s] parsing url[%s]", err, rawurl)
+	}
+	return *u
+}
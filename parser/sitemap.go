@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// MaxSitemapSize is the maximum size of an uncompressed sitemap
+const MaxSitemapSize = 50 * 1024 * 1024
+
+// Sitemap has the URLs found on a XML sitemap or sitemap index,
+// following this specification:
+//
+// https://www.sitemaps.org/protocol.html
+type Sitemap struct {
+	// URLs are the <loc> of the <url> elements of a sitemap
+	URLs []url.URL
+	// Sitemaps are the <loc> of the <sitemap> elements of a sitemap index
+	Sitemaps []url.URL
+}
+
+type xmlSitemap struct {
+	URLs     []xmlSitemapLoc `xml:"url"`
+	Sitemaps []xmlSitemapLoc `xml:"sitemap"`
+}
+
+type xmlSitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// ParseSitemap will parse the given XML sitemap or sitemap index body,
+// which may be gzipped. Locations that are not absolute URLs are ignored.
+// An error is returned if the body is not a valid XML document or
+// if it is bigger than MaxSitemapSize, after being uncompressed.
+func ParseSitemap(body io.Reader) (Sitemap, error) {
+	r := bufio.NewReader(body)
+
+	// WHY: Gzipped sitemaps are usually served without a
+	//      Content-Encoding, so they are detected by their header.
+	if header, err := r.Peek(2); err == nil && header[0] == 0x1f && header[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return Sitemap{}, fmt.Errorf("parser.ParseSitemap: %s", err)
+		}
+		defer gz.Close()
+		body = gz
+	} else {
+		body = r
+	}
+
+	limited := &io.LimitedReader{R: body, N: MaxSitemapSize + 1}
+
+	parsed := xmlSitemap{}
+	if err := xml.NewDecoder(limited).Decode(&parsed); err != nil {
+		if limited.N <= 0 {
+			return Sitemap{}, fmt.Errorf("parser.ParseSitemap: sitemap bigger than %d bytes", MaxSitemapSize)
+		}
+		return Sitemap{}, fmt.Errorf("parser.ParseSitemap: %s", err)
+	}
+
+	return Sitemap{
+		URLs:     parseLocs(parsed.URLs),
+		Sitemaps: parseLocs(parsed.Sitemaps),
+	}, nil
+}
+
+func parseLocs(locs []xmlSitemapLoc) []url.URL {
+	urls := []url.URL{}
+	for _, loc := range locs {
+		u, err := url.Parse(strings.TrimSpace(loc.Loc))
+		if err != nil || !u.IsAbs() {
+			continue
+		}
+		urls = append(urls, *u)
+	}
+	return urls
+}
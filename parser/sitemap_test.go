@@ -0,0 +1,139 @@
+package parser_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/katcipis/crawler/parser"
+)
+
+func TestParseSitemap(t *testing.T) {
+	type tcase struct {
+		name         string
+		sitemap      string
+		wantURLs     []string
+		wantSitemaps []string
+	}
+
+	cases := []tcase{
+		{
+			name: "urlset",
+			sitemap: `<?xml version="1.0" encoding="UTF-8"?>
+				<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+					<url><loc>https://example.com/</loc><lastmod>2019-01-01</lastmod></url>
+					<url><loc>
+						https://example.com/a?q=1&amp;p=2
+					</loc></url>
+				</urlset>`,
+			wantURLs: []string{"https://example.com/", "https://example.com/a?q=1&p=2"},
+		},
+		{
+			name: "sitemapIndex",
+			sitemap: `<?xml version="1.0" encoding="UTF-8"?>
+				<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+					<sitemap><loc>https://example.com/sitemap-1.xml</loc></sitemap>
+					<sitemap><loc>https://example.com/sitemap-2.xml.gz</loc></sitemap>
+				</sitemapindex>`,
+			wantSitemaps: []string{
+				"https://example.com/sitemap-1.xml",
+				"https://example.com/sitemap-2.xml.gz",
+			},
+		},
+		{
+			name: "ignoresRelativeAndEmptyLocs",
+			sitemap: `<urlset>
+					<url><loc>/relative</loc></url>
+					<url><loc></loc></url>
+					<url></url>
+					<url><loc>https://example.com/ok</loc></url>
+				</urlset>`,
+			wantURLs: []string{"https://example.com/ok"},
+		},
+		{
+			name:    "empty",
+			sitemap: `<urlset></urlset>`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sitemap, err := parser.ParseSitemap(strings.NewReader(c.sitemap))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			checkSitemap(t, sitemap, c.wantURLs, c.wantSitemaps)
+
+			gzipped, err := parser.ParseSitemap(gzipReader(t, c.sitemap))
+			if err != nil {
+				t.Fatalf("unexpected error on gzipped sitemap: %s", err)
+			}
+			checkSitemap(t, gzipped, c.wantURLs, c.wantSitemaps)
+		})
+	}
+}
+
+func TestParseSitemapFailures(t *testing.T) {
+	type tcase struct {
+		name string
+		body io.Reader
+	}
+
+	cases := []tcase{
+		{name: "empty", body: strings.NewReader("")},
+		{name: "invalidXML", body: strings.NewReader("<urlset><url>")},
+		{name: "invalidGzip", body: bytes.NewReader([]byte{0x1f, 0x8b, 0x00})},
+		{name: "readError", body: &explodingReader{}},
+		{
+			name: "tooBig",
+			body: io.MultiReader(
+				strings.NewReader("<urlset><!--"),
+				strings.NewReader(strings.Repeat(" ", parser.MaxSitemapSize)),
+				strings.NewReader("--></urlset>"),
+			),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := parser.ParseSitemap(c.body); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func checkSitemap(t *testing.T, sitemap parser.Sitemap, wantURLs []string, wantSitemaps []string) {
+	t.Helper()
+
+	check := func(name string, want []string, got []url.URL) {
+		if len(want) != len(got) {
+			t.Fatalf("want %s %v, got %v", name, want, got)
+		}
+		for i, u := range got {
+			if u.String() != want[i] {
+				t.Fatalf("want %s %v, got %v", name, want, got)
+			}
+		}
+	}
+
+	check("urls", wantURLs, sitemap.URLs)
+	check("sitemaps", wantSitemaps, sitemap.Sitemaps)
+}
+
+func gzipReader(t *testing.T, content string) io.Reader {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("unexpected error gzipping: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error gzipping: %s", err)
+	}
+	return buf
+}